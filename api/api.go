@@ -2,25 +2,59 @@ package api
 
 import (
 	"context"
+	"embed"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/BurntSushi/toml"
+	"github.com/e9ctrl/vd/info"
 	"github.com/e9ctrl/vd/log"
+	"github.com/e9ctrl/vd/metrics"
 	"github.com/go-chi/chi/v5"
-	"github.com/jwalton/gchalk"
 )
 
+// Error returned when access level of the parameter does not allow the operation
+var ErrAccessDenied = errors.New("access denied")
+
+// Query parameter that lets the request bypass access levels, e.g. web UI uses it to simulate read only values
+const forceQuery = "force"
+
+// Single page web UI served under /ui/
+//
+//go:embed ui
+var uiFiles embed.FS
+
 // Interface provides methods to control parameters and commands settings via HTTP server.
 type Device interface {
 	GetParameter(param string) (any, error)
 	SetParameter(param string, val any) error
+	Access(param string) string
 	GetCommandDelay(commandName string) (time.Duration, error)
 	SetCommandDelay(commandName string, val string) error
 	GetMismatch() []byte
 	SetMismatch(mismatch string) error
 	Trigger(param string) error
+	Clients() []info.Client
+	Disconnect(id uint64) error
+	Metrics() *metrics.Metrics
+	Parameters() []info.Parameter
+	Commands() []info.Command
+	Subscribe() (<-chan info.Traffic, func())
+	History(filter info.HistoryFilter) []info.HistoryEntry
+	ClearHistory()
+	Errors() (info.ErrorQueue, error)
+	ClearErrors() error
+	Snapshot() info.State
+	Restore(state info.State) error
+	Presets() []string
+	ApplyPreset(name string) error
+	Export() ([]byte, error)
 }
 
 // Struct that keeps Device interface.
@@ -59,7 +93,7 @@ func (a *Api) Serve(ctx context.Context, addr string) error {
 		shutdownError <- nil
 	}()
 
-	fmt.Println("HTTP API listening on ", gchalk.BrightMagenta("http://"+srv.Addr))
+	log.INF("HTTP API listening", "addr", "http://"+srv.Addr)
 
 	err := srv.ListenAndServe()
 	if !errors.Is(err, http.ErrServerClosed) {
@@ -71,15 +105,23 @@ func (a *Api) Serve(ctx context.Context, addr string) error {
 		return err
 	}
 
-	fmt.Println("server HTTP stopped")
+	log.INF("server HTTP stopped")
 	return nil
 }
 
 func (a *Api) routes() http.Handler {
 	r := chi.NewRouter()
 
+	ui, _ := fs.Sub(uiFiles, "ui")
+
 	r.Route("/", func(r chi.Router) {
+		r.Get("/", http.RedirectHandler("/ui/", http.StatusMovedPermanently).ServeHTTP)
+		r.Get("/ui/*", http.StripPrefix("/ui/", http.FileServer(http.FS(ui))).ServeHTTP)
+		// short paths of the first version of the API, parameters named like the
+		// endpoints below (api, ui, delay, mismatch, trigger) can be shadowed here
+		// and are always reachable under /api/parameters
 		r.Get("/{param}", a.getParameter)
+		r.Post("/{param}", a.setParameterJSON)
 		r.Post("/{param}/{value}", a.setParameter)
 		r.Get("/delay/{command}", a.getCommandDelay)
 		r.Post("/delay/{command}/{value}", a.setCommandDelay)
@@ -88,6 +130,33 @@ func (a *Api) routes() http.Handler {
 		r.Post("/trigger/{param}", a.trigger)
 	})
 
+	// all endpoints have their own namespace, so they never shadow parameters
+	r.Route("/api", func(r chi.Router) {
+		r.Get("/parameters", a.getParameters)
+		r.Get("/parameters/{param}", a.getParameter)
+		r.Post("/parameters/{param}", a.setParameterJSON)
+		r.Post("/parameters/{param}/{value}", a.setParameter)
+		r.Get("/commands", a.getCommands)
+		r.Get("/traffic", a.traffic)
+		r.Get("/delay/{command}", a.getCommandDelay)
+		r.Post("/delay/{command}/{value}", a.setCommandDelay)
+		r.Get("/mismatch", a.getMismatch)
+		r.Post("/mismatch/{value}", a.setMismatch)
+		r.Post("/trigger/{param}", a.trigger)
+		r.Get("/clients", a.getClients)
+		r.Delete("/clients/{id}", a.disconnectClient)
+		r.Get("/metrics", a.metrics)
+		r.Get("/history", a.getHistory)
+		r.Delete("/history", a.clearHistory)
+		r.Get("/errors", a.getErrors)
+		r.Delete("/errors", a.clearErrors)
+		r.Get("/snapshot", a.getSnapshot)
+		r.Post("/snapshot", a.restoreSnapshot)
+		r.Get("/presets", a.getPresets)
+		r.Post("/presets/{name}", a.applyPreset)
+		r.Get("/export", a.export)
+	})
+
 	return r
 }
 func (a *Api) getMismatch(w http.ResponseWriter, r *http.Request) {
@@ -106,19 +175,51 @@ func (a *Api) setMismatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.API("set mismatch to", value)
+	log.API("set mismatch", "value", value)
 	w.Write([]byte("Mismatch set successfully"))
 }
 
+// Check if the request can read or write the parameter, ro parameters cannot be written
+// and wo parameters cannot be read unless the request is forced
+func (a *Api) checkAccess(r *http.Request, param string, write bool) error {
+	if forced(r) {
+		return nil
+	}
+	access := a.d.Access(param)
+	if (access == info.AccessReadOnly && write) || (access == info.AccessWriteOnly && !write) {
+		op := "read"
+		if write {
+			op = "write"
+		}
+		return fmt.Errorf("%w: cannot %s %s parameter %s without %s=true", ErrAccessDenied, op, access, param, forceQuery)
+	}
+	return nil
+}
+
+func forced(r *http.Request) bool {
+	force, _ := strconv.ParseBool(r.URL.Query().Get(forceQuery))
+	return force
+}
+
 func (a *Api) getParameter(w http.ResponseWriter, r *http.Request) {
 	param := chi.URLParam(r, "param")
 
+	if err := a.checkAccess(r, param, false); err != nil {
+		errorHandler(w, err)
+		return
+	}
 	value, err := a.d.GetParameter(param)
 	if err != nil {
 		errorHandler(w, err)
 		return
 	}
-	log.API("get", param)
+	log.API("get parameter", "param", param)
+	// arrays are returned as JSON lists
+	if elems, isArray := value.([]any); isArray {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(elems)
+		return
+	}
 	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte(fmt.Sprintf("%v", value)))
 }
@@ -127,12 +228,45 @@ func (a *Api) setParameter(w http.ResponseWriter, r *http.Request) {
 	param := chi.URLParam(r, "param")
 	value := chi.URLParam(r, "value")
 
+	if err := a.checkAccess(r, param, true); err != nil {
+		errorHandler(w, err)
+		return
+	}
 	err := a.d.SetParameter(param, value)
 	if err != nil {
 		errorHandler(w, err)
 		return
 	}
-	log.API("set", param, "to", value)
+	log.API("set parameter", "param", param, "value", value)
+	w.Write([]byte("Parameter set successfully"))
+}
+
+// Sets parameter to value from JSON body, it is used mainly to set arrays
+func (a *Api) setParameterJSON(w http.ResponseWriter, r *http.Request) {
+	param := chi.URLParam(r, "param")
+	if err := a.checkAccess(r, param, true); err != nil {
+		errorHandler(w, err)
+		return
+	}
+
+	var value any
+	dec := json.NewDecoder(r.Body)
+	// keep numbers as they were written, parameter decides about their type
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		errorHandler(w, err)
+		return
+	}
+	if n, ok := value.(json.Number); ok {
+		value = n.String()
+	}
+
+	err := a.d.SetParameter(param, value)
+	if err != nil {
+		errorHandler(w, err)
+		return
+	}
+	log.API("set parameter", "param", param, "value", value)
 	w.Write([]byte("Parameter set successfully"))
 }
 
@@ -145,7 +279,7 @@ func (a *Api) getCommandDelay(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.API("get delay of", commandName)
+	log.API("get delay", log.CommandKey, commandName)
 	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte(del.String()))
 }
@@ -160,7 +294,7 @@ func (a *Api) setCommandDelay(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.API("set delay of", commandName, "to", value)
+	log.API("set delay", log.CommandKey, commandName, "value", value)
 	w.Write([]byte("Delay set successfully"))
 }
 
@@ -173,11 +307,250 @@ func (a *Api) trigger(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.API("triggered parameter", param)
+	log.API("triggered command", log.CommandKey, param)
 	w.Write([]byte("Parameter triggered successfully"))
 }
 
+func (a *Api) getClients(w http.ResponseWriter, r *http.Request) {
+	clients := a.d.Clients()
+
+	log.API("get clients")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(clients)
+}
+
+func (a *Api) disconnectClient(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		errorHandler(w, err)
+		return
+	}
+
+	err = a.d.Disconnect(id)
+	if err != nil {
+		errorHandler(w, err)
+		return
+	}
+
+	log.API("disconnected client", log.ClientKey, id)
+	w.Write([]byte("Client disconnected successfully"))
+}
+
+func (a *Api) getParameters(w http.ResponseWriter, r *http.Request) {
+	params := a.d.Parameters()
+	// values of write only parameters are not revealed
+	for i, p := range params {
+		if p.Access == info.AccessWriteOnly && !forced(r) {
+			params[i].Value = nil
+		}
+	}
+
+	log.API("get parameters")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(params)
+}
+
+func (a *Api) getCommands(w http.ResponseWriter, r *http.Request) {
+	cmds := a.d.Commands()
+
+	log.API("get commands")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cmds)
+}
+
+// Streams traffic of the device as server-sent events until client disconnects
+func (a *Api) traffic(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// stream is long living, server write timeout cannot be applied
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		errorHandler(w, err)
+		return
+	}
+
+	ch, unsubscribe := a.d.Subscribe()
+	defer unsubscribe()
+
+	log.API("traffic subscribed")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case t := <-ch:
+			data, err := json.Marshal(t)
+			if err != nil {
+				log.ERR(err.Error())
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Returns requests received by the device, query parameters:
+// command - name of the matched command, since - RFC3339 time or duration before now, limit - number of the newest entries
+func (a *Api) getHistory(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseHistoryFilter(r)
+	if err != nil {
+		errorHandler(w, err)
+		return
+	}
+
+	entries := a.d.History(filter)
+
+	log.API("get history", "entries", len(entries))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (a *Api) clearHistory(w http.ResponseWriter, r *http.Request) {
+	a.d.ClearHistory()
+
+	log.API("cleared history")
+	w.Write([]byte("History cleared successfully"))
+}
+
+// Returns errors in the error queue of the device, the oldest first, together with the status register
+func (a *Api) getErrors(w http.ResponseWriter, r *http.Request) {
+	info, err := a.d.Errors()
+	if err != nil {
+		errorHandler(w, err)
+		return
+	}
+
+	log.API("get errors", "errors", len(info.Errors))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+func (a *Api) clearErrors(w http.ResponseWriter, r *http.Request) {
+	if err := a.d.ClearErrors(); err != nil {
+		errorHandler(w, err)
+		return
+	}
+
+	log.API("cleared errors")
+	w.Write([]byte("Errors cleared successfully"))
+}
+
+func parseHistoryFilter(r *http.Request) (info.HistoryFilter, error) {
+	q := r.URL.Query()
+	filter := info.HistoryFilter{Command: q.Get("command")}
+
+	if since := q.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			d, durErr := time.ParseDuration(since)
+			if durErr != nil {
+				return filter, fmt.Errorf("since is neither RFC3339 time nor duration: %s", since)
+			}
+			t = time.Now().Add(-d)
+		}
+		filter.Since = t
+	}
+
+	if limit := q.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l < 0 {
+			return filter, fmt.Errorf("wrong limit: %s", limit)
+		}
+		filter.Limit = l
+	}
+
+	return filter, nil
+}
+
+// Returns state of the device as JSON, or as TOML when format=toml query parameter is given
+func (a *Api) getSnapshot(w http.ResponseWriter, r *http.Request) {
+	state := a.d.Snapshot()
+
+	log.API("get snapshot")
+	if r.URL.Query().Get("format") == "toml" {
+		w.Header().Set("Content-Type", "application/toml")
+		toml.NewEncoder(w).Encode(state)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// Restores state of the device from JSON body, or TOML body when content type contains toml
+func (a *Api) restoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	var state info.State
+	var err error
+	if strings.Contains(r.Header.Get("Content-Type"), "toml") {
+		_, err = toml.NewDecoder(r.Body).Decode(&state)
+	} else {
+		dec := json.NewDecoder(r.Body)
+		// keep numbers as they were written, parameter decides about their type
+		dec.UseNumber()
+		err = dec.Decode(&state)
+	}
+	if err != nil {
+		errorHandler(w, err)
+		return
+	}
+
+	err = a.d.Restore(state)
+	if err != nil {
+		errorHandler(w, err)
+		return
+	}
+
+	log.API("restored snapshot")
+	w.Write([]byte("Snapshot restored successfully"))
+}
+
+func (a *Api) getPresets(w http.ResponseWriter, r *http.Request) {
+	presets := a.d.Presets()
+
+	log.API("get presets")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(presets)
+}
+
+func (a *Api) applyPreset(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	err := a.d.ApplyPreset(name)
+	if err != nil {
+		errorHandler(w, err)
+		return
+	}
+
+	log.API("applied preset", "preset", name)
+	w.Write([]byte("Preset applied successfully"))
+}
+
+// Returns vdfile with the current state of the device
+func (a *Api) export(w http.ResponseWriter, r *http.Request) {
+	data, err := a.d.Export()
+	if err != nil {
+		errorHandler(w, err)
+		return
+	}
+
+	log.API("exported vdfile")
+	w.Header().Set("Content-Type", "application/toml")
+	w.Write(data)
+}
+
+func (a *Api) metrics(w http.ResponseWriter, r *http.Request) {
+	a.d.Metrics().Handler().ServeHTTP(w, r)
+}
+
 func errorHandler(w http.ResponseWriter, err error) {
-	w.WriteHeader(http.StatusInternalServerError)
+	if errors.Is(err, ErrAccessDenied) {
+		w.WriteHeader(http.StatusForbidden)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
 	fmt.Fprintf(w, "Error: %s", err)
 }
@@ -1,11 +1,17 @@
 package api
 
 import (
+	"bufio"
+	"encoding/json"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/e9ctrl/vd/device"
+	"github.com/e9ctrl/vd/server"
 	"github.com/e9ctrl/vd/vdfile"
+	"github.com/google/go-cmp/cmp"
 )
 
 // path to vdfile used in tests
@@ -317,3 +323,749 @@ func TestSetCommandDelay(t *testing.T) {
 		})
 	}
 }
+
+func TestClients(t *testing.T) {
+	t.Parallel()
+	vdfile, err := vdfile.ReadVDFileFromConfig(vdfileTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev, err := device.NewDevice(vdfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connectedAt := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	dev.Connected(&server.Client{ID: 1, RemoteAddr: "127.0.0.1:5000", ConnectedAt: connectedAt})
+
+	a := &Api{
+		d: dev,
+	}
+
+	ts := newTestServer(t, a.routes())
+
+	defer ts.Close()
+
+	expected := `[{"id":1,"remote_addr":"127.0.0.1:5000","connected_at":"2023-10-01T12:00:00Z"}]` + "\n"
+	code, _, body := ts.get(t, "/api/clients")
+	if code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			code, http.StatusOK)
+	}
+	if string(body) != expected {
+		t.Errorf("handler returned unexpected body: got\n %s want\n %v",
+			body, expected)
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		exp     string
+		expCode int
+	}{
+		{"disconnect unknown client", "2", "Error: client not found: 2", http.StatusInternalServerError},
+		{"disconnect wrong id", "test", `Error: strconv.ParseUint: parsing "test": invalid syntax`, http.StatusInternalServerError},
+		{"disconnect client", "1", "Client disconnected successfully", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.delete(t, "/api/clients/"+tt.id)
+			if code != tt.expCode {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					code, tt.expCode)
+			}
+			if string(body) != tt.exp {
+				t.Errorf("handler returned unexpected body: got\n %s want\n %v",
+					body, tt.exp)
+			}
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+	vdfile, err := vdfile.ReadVDFileFromConfig(vdfileTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev, err := device.NewDevice(vdfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev.Handle(nil, []byte("CUR?\r\n"))
+	dev.Handle(nil, []byte("CUR 20\r\n"))
+	dev.Handle(nil, []byte("TEST?\r\n"))
+
+	a := &Api{
+		d: dev,
+	}
+
+	ts := newTestServer(t, a.routes())
+
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/api/metrics")
+	if code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			code, http.StatusOK)
+	}
+
+	exp := []string{
+		`vd_requests_total{command="get_current"} 1`,
+		`vd_requests_total{command="set_current"} 1`,
+		`vd_mismatches_total{reason="unknown_command"} 1`,
+		`vd_parameter_value{param="current"} 20`,
+		`vd_parameter_value{param="psi"} 3.3`,
+	}
+	for _, line := range exp {
+		if !strings.Contains(string(body), line) {
+			t.Errorf("metrics do not contain %s", line)
+		}
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	t.Parallel()
+	vdfile, err := vdfile.ReadVDFileFromConfig(vdfileTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev, err := device.NewDevice(vdfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Api{
+		d: dev,
+	}
+
+	ts := newTestServer(t, a.routes())
+
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/api/parameters")
+	if code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", code, http.StatusOK)
+	}
+	var params []device.ParameterInfo
+	if err := json.Unmarshal(body, &params); err != nil {
+		t.Fatal(err)
+	}
+	if len(params) != len(vdfileTest.Params) {
+		t.Fatalf("exp %d parameters got %d", len(vdfileTest.Params), len(params))
+	}
+	for _, p := range params {
+		if p.Name == "mode" {
+			if p.Typ != "string" || p.Value != "NORM" || strings.Join(p.Opts, "|") != "NORM|SING|BURS|DCYC" {
+				t.Errorf("unexpected mode parameter: %+v", p)
+			}
+		}
+	}
+
+	code, _, body = ts.get(t, "/api/commands")
+	if code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", code, http.StatusOK)
+	}
+	var cmds []device.CommandInfo
+	if err := json.Unmarshal(body, &cmds); err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != len(vdfileTest.Commands) {
+		t.Fatalf("exp %d commands got %d", len(vdfileTest.Commands), len(cmds))
+	}
+	for _, c := range cmds {
+		if c.Name == "get_psi" && (c.Req != "PSI?" || c.Res != "PSI {%3.2f:psi}" || c.Dly != "3s") {
+			t.Errorf("unexpected get_psi command: %+v", c)
+		}
+	}
+}
+
+func TestUI(t *testing.T) {
+	t.Parallel()
+	vdfile, err := vdfile.ReadVDFileFromConfig(vdfileTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev, err := device.NewDevice(vdfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Api{
+		d: dev,
+	}
+
+	ts := newTestServer(t, a.routes())
+
+	defer ts.Close()
+
+	// client follows redirect from / to /ui/
+	code, header, body := ts.get(t, "/")
+	if code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", code, http.StatusOK)
+	}
+	if !strings.HasPrefix(header.Get("Content-Type"), "text/html") {
+		t.Errorf("exp html content type got: %s", header.Get("Content-Type"))
+	}
+	if !strings.Contains(string(body), "<title>vd</title>") {
+		t.Error("index page not served")
+	}
+}
+
+func TestTraffic(t *testing.T) {
+	t.Parallel()
+	vdfile, err := vdfile.ReadVDFileFromConfig(vdfileTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev, err := device.NewDevice(vdfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Api{
+		d: dev,
+	}
+
+	ts := newTestServer(t, a.routes())
+
+	defer ts.Close()
+
+	rs, err := ts.Client().Get(ts.URL + "/api/traffic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	if rs.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("exp event stream content type got: %s", rs.Header.Get("Content-Type"))
+	}
+
+	// headers are sent after subscription is done
+	dev.Handle(nil, []byte("CUR?\r\n"))
+
+	exp := []device.Traffic{
+		{Direction: device.DirectionRX, Data: "CUR?\r\n", Hex: "43 55 52 3f 0d 0a"},
+		{Direction: device.DirectionTX, Command: "get_current", Data: "CUR 300\r\n", Hex: "43 55 52 20 33 30 30 0d 0a"},
+	}
+	scanner := bufio.NewScanner(rs.Body)
+	for _, e := range exp {
+		var line string
+		for line == "" && scanner.Scan() {
+			line = scanner.Text()
+		}
+		data, found := strings.CutPrefix(line, "data: ")
+		if !found {
+			t.Fatalf("exp data line got: %s", line)
+		}
+		var got device.Traffic
+		if err := json.Unmarshal([]byte(data), &got); err != nil {
+			t.Fatal(err)
+		}
+		got.Time = e.Time
+		if got != e {
+			t.Errorf("exp event: %+v got: %+v", e, got)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+	vdfile, err := vdfile.ReadVDFileFromConfig(vdfileTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev, err := device.NewDevice(vdfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev.Handle(nil, []byte("CUR?\r\n"))
+	dev.Handle(nil, []byte("CUR 20\r\n"))
+	dev.Handle(nil, []byte("CUR?\r\n"))
+
+	a := &Api{
+		d: dev,
+	}
+
+	ts := newTestServer(t, a.routes())
+
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		query   string
+		exp     []string
+		expCode int
+	}{
+		{"all", "", []string{"CUR?", "CUR 20", "CUR?"}, http.StatusOK},
+		{"command", "?command=set_current", []string{"CUR 20"}, http.StatusOK},
+		{"limit", "?command=get_current&limit=1", []string{"CUR?"}, http.StatusOK},
+		{"since duration", "?since=1h", []string{"CUR?", "CUR 20", "CUR?"}, http.StatusOK},
+		{"since time", "?since=" + time.Now().Add(time.Hour).Format(time.RFC3339), []string{}, http.StatusOK},
+		{"wrong since", "?since=yesterday", nil, http.StatusInternalServerError},
+		{"wrong limit", "?limit=-1", nil, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, "/api/history"+tt.query)
+			if code != tt.expCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v", code, tt.expCode)
+			}
+			if tt.exp == nil {
+				return
+			}
+
+			var entries []device.HistoryEntry
+			if err := json.Unmarshal(body, &entries); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, e := range entries {
+				got = append(got, e.Raw)
+			}
+			if diff := cmp.Diff(tt.exp, got); diff != "" {
+				t.Errorf("unexpected history (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	code, _, body := ts.delete(t, "/api/history")
+	if code != http.StatusOK || string(body) != "History cleared successfully" {
+		t.Errorf("unexpected clear response %d %s", code, body)
+	}
+	if entries := dev.History(device.HistoryFilter{}); len(entries) != 0 {
+		t.Errorf("exp empty history got %+v", entries)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	t.Parallel()
+	config := vdfileTest
+	config.Errors = &vdfile.ConfigErrors{
+		UnknownCommand: &vdfile.DeviceError{Code: 1, Msg: "unknown command", Status: 4},
+	}
+	vd, err := vdfile.ReadVDFileFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev, err := device.NewDevice(vd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev.Handle(nil, []byte("TEST?\r\n"))
+
+	a := &Api{
+		d: dev,
+	}
+
+	ts := newTestServer(t, a.routes())
+
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/api/errors")
+	if code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", code, http.StatusOK)
+	}
+	var info device.ErrorQueueInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		t.Fatal(err)
+	}
+	exp := device.ErrorQueueInfo{Errors: []vdfile.DeviceError{*config.Errors.UnknownCommand}, Status: 4}
+	if diff := cmp.Diff(exp, info); diff != "" {
+		t.Errorf("unexpected errors (-want +got):\n%s", diff)
+	}
+
+	code, _, body = ts.delete(t, "/api/errors")
+	if code != http.StatusOK || string(body) != "Errors cleared successfully" {
+		t.Errorf("unexpected clear response %d %s", code, body)
+	}
+	if info, _ := dev.Errors(); len(info.Errors) != 0 {
+		t.Errorf("exp empty error queue got %+v", info)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+	vdfile, err := vdfile.ReadVDFileFromConfig(vdfileTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev, err := device.NewDevice(vdfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Api{
+		d: dev,
+	}
+
+	ts := newTestServer(t, a.routes())
+
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/api/snapshot")
+	if code != http.StatusOK || header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response %d %s", code, header.Get("Content-Type"))
+	}
+	for _, exp := range []string{`"current":300`, `"get_psi":"3s"`, `"mismatch":"Wrong query"`} {
+		if !strings.Contains(string(body), exp) {
+			t.Errorf("exp %s in snapshot got %s", exp, body)
+		}
+	}
+	snapshot := string(body)
+
+	code, header, body = ts.get(t, "/api/snapshot?format=toml")
+	if code != http.StatusOK || header.Get("Content-Type") != "application/toml" {
+		t.Fatalf("unexpected response %d %s", code, header.Get("Content-Type"))
+	}
+	for _, exp := range []string{`mismatch = "Wrong query"`, "[params]", "current = 300", "[delays]", `get_psi = "3s"`} {
+		if !strings.Contains(string(body), exp) {
+			t.Errorf("exp %s in snapshot got %s", exp, body)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		content     string
+		exp         string
+		expCode     int
+		expCurrent  int64
+	}{
+		{"restore json", "application/json", `{"params":{"current":20,"psi":4.5},"mismatch":"err"}`, "Snapshot restored successfully", http.StatusOK, 20},
+		{"restore toml", "application/toml", "[params]\ncurrent = 30\n", "Snapshot restored successfully", http.StatusOK, 30},
+		{"invalid value", "application/json", `{"params":{"current":1,"psi":"high"}}`, "Error: parameter psi: received param type that cannot be converted to float", http.StatusInternalServerError, 30},
+		{"invalid json", "application/json", `{"params":`, "Error: unexpected EOF", http.StatusInternalServerError, 30},
+		{"restore snapshot", "application/json", snapshot, "Snapshot restored successfully", http.StatusOK, 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.post(t, "/api/snapshot", tt.contentType, tt.content)
+			if code != tt.expCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", code, tt.expCode)
+			}
+			if string(body) != tt.exp {
+				t.Errorf("handler returned unexpected body: got\n %s want\n %v", body, tt.exp)
+			}
+			if v, _ := dev.GetParameter("current"); v != tt.expCurrent {
+				t.Errorf("exp current %d got %v", tt.expCurrent, v)
+			}
+		})
+	}
+}
+
+func TestPresets(t *testing.T) {
+	t.Parallel()
+	config := vdfileTest
+	mismatch := "ERR"
+	config.Presets = map[string]vdfile.State{
+		"fault": {Params: map[string]any{"current": int64(0)}, Mismatch: &mismatch},
+		"idle":  {Delays: map[string]string{"get_psi": "0s"}},
+	}
+	vdfile, err := vdfile.ReadVDFileFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev, err := device.NewDevice(vdfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Api{
+		d: dev,
+	}
+
+	ts := newTestServer(t, a.routes())
+
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/api/presets")
+	if code != http.StatusOK || string(body) != `["fault","idle"]`+"\n" {
+		t.Errorf("unexpected presets %d %s", code, body)
+	}
+
+	code, _, body = ts.set(t, "/api/presets/fault")
+	if code != http.StatusOK || string(body) != "Preset applied successfully" {
+		t.Errorf("unexpected response %d %s", code, body)
+	}
+	if v, _ := dev.GetParameter("current"); v != int64(0) {
+		t.Errorf("exp current 0 got %v", v)
+	}
+	if mis := dev.GetMismatch(); string(mis) != "ERR" {
+		t.Errorf("exp mismatch ERR got %s", mis)
+	}
+
+	code, _, body = ts.set(t, "/api/presets/missing")
+	if code != http.StatusInternalServerError || string(body) != "Error: preset not found: missing" {
+		t.Errorf("unexpected response %d %s", code, body)
+	}
+}
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+	vdfile, err := vdfile.ReadVDFileFromConfig(vdfileTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev, err := device.NewDevice(vdfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev.SetParameter("current", "42")
+
+	a := &Api{
+		d: dev,
+	}
+
+	ts := newTestServer(t, a.routes())
+
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/api/export")
+	if code != http.StatusOK || header.Get("Content-Type") != "application/toml" {
+		t.Fatalf("unexpected response %d %s", code, header.Get("Content-Type"))
+	}
+	for _, exp := range []string{`mismatch = "Wrong query"`, "name = \"current\"\n  typ = \"int\"\n  val = 42", "name = \"get_psi\"\n  req = \"PSI?\"\n  res = \"PSI {%3.2f:psi}\"\n  dly = \"3s\""} {
+		if !strings.Contains(string(body), exp) {
+			t.Errorf("exp %q in export got:\n%s", exp, body)
+		}
+	}
+}
+
+func TestArrayParameter(t *testing.T) {
+	t.Parallel()
+	vd, err := vdfile.ReadVDFileFromBytes([]byte(`
+interm = "LF"
+outterm = "LF"
+
+[[parameter]]
+  name = "wave"
+  typ = "float64[]"
+  val = [1.5, 2.5, 3.5]
+  max_len = 4
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev, err := device.NewDevice(vd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Api{
+		d: dev,
+	}
+
+	ts := newTestServer(t, a.routes())
+
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/wave")
+	if code != http.StatusOK || header.Get("Content-Type") != "application/json" || string(body) != "[1.5,2.5,3.5]\n" {
+		t.Fatalf("unexpected response %d %s %s", code, header.Get("Content-Type"), body)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		content string
+		exp     string
+		expCode int
+		expWave string
+	}{
+		{"set json array", "/wave", `[1, 2.25]`, "Parameter set successfully", http.StatusOK, "[1,2.25]\n"},
+		{"too many elements", "/wave", `[1, 2, 3, 4, 5]`, "Error: wrong number of array elements: 5", http.StatusInternalServerError, "[1,2.25]\n"},
+		{"invalid element", "/wave", `[1, "x"]`, "Error: element 1: received param type that cannot be converted to float", http.StatusInternalServerError, "[1,2.25]\n"},
+		{"set element", "/wave[1]", `7.5`, "Parameter set successfully", http.StatusOK, "[1,7.5]\n"},
+		{"element outside array", "/wave[2]", `7.5`, "Error: index outside array: 2", http.StatusInternalServerError, "[1,7.5]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.post(t, tt.path, "application/json", tt.content)
+			if code != tt.expCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", code, tt.expCode)
+			}
+			if string(body) != tt.exp {
+				t.Errorf("handler returned unexpected body: got\n %s want\n %v", body, tt.exp)
+			}
+			if _, _, body := ts.get(t, "/wave"); string(body) != tt.expWave {
+				t.Errorf("exp wave %s got %s", tt.expWave, body)
+			}
+		})
+	}
+
+	code, _, body = ts.get(t, "/wave[1]")
+	if code != http.StatusOK || string(body) != "7.5" {
+		t.Errorf("exp element 7.5 got %d %s", code, body)
+	}
+}
+
+func TestParameterAccess(t *testing.T) {
+	t.Parallel()
+	vd, err := vdfile.ReadVDFileFromBytes([]byte(`
+interm = "LF"
+outterm = "LF"
+
+[[parameter]]
+  name = "temp"
+  typ = "float64"
+  val = 21.5
+  access = "ro"
+
+[[parameter]]
+  name = "password"
+  typ = "string"
+  val = "secret"
+  access = "wo"
+
+[[parameter]]
+  name = "serial"
+  typ = "string"
+  val = "SN01"
+  access = "api-only"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev, err := device.NewDevice(vd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Api{
+		d: dev,
+	}
+
+	ts := newTestServer(t, a.routes())
+
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		expCode int
+		exp     string
+	}{
+		{"read read only", http.MethodGet, "/temp", http.StatusOK, "21.5"},
+		{"write read only", http.MethodPost, "/temp/30", http.StatusForbidden, "Error: access denied: cannot write ro parameter temp without force=true"},
+		{"forced write read only", http.MethodPost, "/temp/30?force=true", http.StatusOK, "Parameter set successfully"},
+		{"read forced value", http.MethodGet, "/temp", http.StatusOK, "30"},
+		{"write write only", http.MethodPost, "/password/admin", http.StatusOK, "Parameter set successfully"},
+		{"read write only", http.MethodGet, "/password", http.StatusForbidden, "Error: access denied: cannot read wo parameter password without force=true"},
+		{"forced read write only", http.MethodGet, "/password?force=true", http.StatusOK, "admin"},
+		{"read api only", http.MethodGet, "/serial", http.StatusOK, "SN01"},
+		{"write api only", http.MethodPost, "/serial/SN02", http.StatusOK, "Parameter set successfully"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var code int
+			var body []byte
+			if tt.method == http.MethodGet {
+				code, _, body = ts.get(t, tt.path)
+			} else {
+				code, _, body = ts.set(t, tt.path)
+			}
+			if code != tt.expCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", code, tt.expCode)
+			}
+			if string(body) != tt.exp {
+				t.Errorf("exp body: %q got %q", tt.exp, body)
+			}
+		})
+	}
+
+	// listing does not reveal write only values unless forced
+	for path, exp := range map[string]any{"/api/parameters": nil, "/api/parameters?force=true": "admin"} {
+		_, _, body := ts.get(t, path)
+		var params []device.ParameterInfo
+		if err := json.Unmarshal(body, &params); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range params {
+			if p.Name == "password" && p.Value != exp {
+				t.Errorf("%s: exp password %v got %v", path, exp, p.Value)
+			}
+		}
+	}
+}
+
+func TestParameterNames(t *testing.T) {
+	t.Parallel()
+	vd, err := vdfile.ReadVDFileFromBytes([]byte(`
+interm = "LF"
+outterm = "LF"
+mismatch = "Wrong query"
+
+[[parameter]]
+  name = "errors"
+  typ = "int"
+  val = 3
+
+[[parameter]]
+  name = "mismatch"
+  typ = "string"
+  val = "none"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev, err := device.NewDevice(vd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Api{
+		d: dev,
+	}
+
+	ts := newTestServer(t, a.routes())
+
+	defer ts.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		exp    string
+	}{
+		{"short path", http.MethodGet, "/errors", "3"},
+		{"set errors", http.MethodPost, "/api/parameters/errors/5", "Parameter set successfully"},
+		{"read errors", http.MethodGet, "/api/parameters/errors", "5"},
+		{"set mismatch", http.MethodPost, "/api/parameters/mismatch/low", "Parameter set successfully"},
+		{"read mismatch", http.MethodGet, "/api/parameters/mismatch", "low"},
+		{"global mismatch", http.MethodGet, "/api/mismatch", "Wrong query"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var code int
+			var body []byte
+			if tt.method == http.MethodGet {
+				code, _, body = ts.get(t, tt.path)
+			} else {
+				code, _, body = ts.set(t, tt.path)
+			}
+			if code != http.StatusOK {
+				t.Errorf("handler returned wrong status code: got %v want %v", code, http.StatusOK)
+			}
+			if strings.TrimSpace(string(body)) != tt.exp {
+				t.Errorf("exp body: %q got %q", tt.exp, body)
+			}
+		})
+	}
+}
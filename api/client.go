@@ -1,15 +1,22 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
+
+	"github.com/e9ctrl/vd/info"
 )
 
 // Structure with client configuration.
 type Client struct {
 	url string
+	// Read write only and change read only parameters
+	Force bool
 }
 
 // Create new Client isntance with configurable HTTP address.
@@ -19,9 +26,17 @@ func NewClient(url string) *Client {
 	}
 }
 
+// Query bypassing access levels of parameters when the client is forced
+func (c *Client) forceQuery() string {
+	if c.Force {
+		return "?" + forceQuery + "=true"
+	}
+	return ""
+}
+
 // Get given parameter name from the simulator server via exposed REST API with HTTP GET query.
 func (c *Client) GetParameter(param string) (string, error) {
-	resp, err := http.Get("http://" + c.url + "/" + param)
+	resp, err := http.Get("http://" + c.url + "/api/parameters/" + param + c.forceQuery())
 	if err != nil {
 		return "", err
 	}
@@ -43,7 +58,7 @@ func (c *Client) GetParameter(param string) (string, error) {
 
 // Set given parameter with a specified value via exposed REST API with HTTP POST query.
 func (c *Client) SetParameter(param, value string) error {
-	resp, err := http.Post("http://"+c.url+"/"+param+"/"+value, "text/plain", nil)
+	resp, err := http.Post("http://"+c.url+"/api/parameters/"+param+"/"+value+c.forceQuery(), "text/plain", nil)
 	if err != nil {
 		return err
 	}
@@ -66,7 +81,7 @@ func (c *Client) SetParameter(param, value string) error {
 
 // Get command delay value via exposed REST API with HTTP Get query.
 func (c *Client) GetCommandDelay(commandName string) (time.Duration, error) {
-	resp, err := http.Get("http://" + c.url + "/delay/" + commandName)
+	resp, err := http.Get("http://" + c.url + "/api/delay/" + commandName)
 	if err != nil {
 		return 0, err
 	}
@@ -89,7 +104,7 @@ func (c *Client) GetCommandDelay(commandName string) (time.Duration, error) {
 
 // Set command delay via exposed REST aPI with HTTP Post query.
 func (c *Client) SetCommandDelay(commandName, value string) error {
-	resp, err := http.Post("http://"+c.url+"/delay/"+commandName+"/"+value, "text/plain", nil)
+	resp, err := http.Post("http://"+c.url+"/api/delay/"+commandName+"/"+value, "text/plain", nil)
 	if err != nil {
 		return err
 	}
@@ -112,7 +127,7 @@ func (c *Client) SetCommandDelay(commandName, value string) error {
 
 // Get mismatch string (message that is returned when ) via exposed REST API with Get query.
 func (c *Client) GetMismatch() (string, error) {
-	resp, err := http.Get("http://" + c.url + "/mismatch")
+	resp, err := http.Get("http://" + c.url + "/api/mismatch")
 	if err != nil {
 		return "", err
 	}
@@ -134,7 +149,7 @@ func (c *Client) GetMismatch() (string, error) {
 
 // Set new mismatch message via exposed REST API with POST query.
 func (c *Client) SetMismatch(value string) error {
-	resp, err := http.Post("http://"+c.url+"/mismatch/"+value, "text/plain", nil)
+	resp, err := http.Post("http://"+c.url+"/api/mismatch/"+value, "text/plain", nil)
 	if err != nil {
 		return err
 	}
@@ -157,10 +172,250 @@ func (c *Client) SetMismatch(value string) error {
 
 // Method to trigger returning parameter value on the TCP server side, uses HTTP Post query.
 func (c *Client) Trigger(param string) error {
-	resp, err := http.Post("http://"+c.url+"/trigger/"+param, "text/plain", nil)
+	resp, err := http.Post("http://"+c.url+"/api/trigger/"+param, "text/plain", nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API error %s", body)
+	}
+	return nil
+}
+
+// Get list of clients connected to the simulator via exposed REST API with HTTP GET query.
+func (c *Client) Clients() ([]info.Client, error) {
+	resp, err := http.Get("http://" + c.url + "/api/clients")
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error %s", body)
+	}
+
+	var clients []info.Client
+	err = json.Unmarshal(body, &clients)
+	return clients, err
+}
+
+// Close connection of the client with given id via exposed REST API with HTTP DELETE query.
+func (c *Client) Disconnect(id uint64) error {
+	req, err := http.NewRequest(http.MethodDelete, "http://"+c.url+"/api/clients/"+strconv.FormatUint(id, 10), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API error %s", body)
+	}
+
+	return nil
+}
+
+// Get requests received by the simulator via exposed REST API with HTTP GET query.
+func (c *Client) History(filter info.HistoryFilter) ([]info.HistoryEntry, error) {
+	q := url.Values{}
+	if filter.Command != "" {
+		q.Set("command", filter.Command)
+	}
+	if !filter.Since.IsZero() {
+		q.Set("since", filter.Since.Format(time.RFC3339Nano))
+	}
+	if filter.Limit > 0 {
+		q.Set("limit", strconv.Itoa(filter.Limit))
+	}
+
+	resp, err := http.Get("http://" + c.url + "/api/history?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error %s", body)
+	}
+
+	var entries []info.HistoryEntry
+	err = json.Unmarshal(body, &entries)
+	return entries, err
+}
+
+// Remove requests from the history of the simulator via exposed REST API with HTTP DELETE query.
+func (c *Client) ClearHistory() error {
+	req, err := http.NewRequest(http.MethodDelete, "http://"+c.url+"/api/history", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API error %s", body)
+	}
+
+	return nil
+}
+
+// Get names of presets defined in the vdfile via exposed REST API with HTTP GET query.
+func (c *Client) Presets() ([]string, error) {
+	resp, err := http.Get("http://" + c.url + "/api/presets")
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error %s", body)
+	}
+
+	var presets []string
+	err = json.Unmarshal(body, &presets)
+	return presets, err
+}
+
+// Apply preset defined in the vdfile via exposed REST API with HTTP POST query.
+func (c *Client) ApplyPreset(name string) error {
+	resp, err := http.Post("http://"+c.url+"/api/presets/"+name, "text/plain", nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API error %s", body)
+	}
+
+	return nil
+}
+
+// Get vdfile with the current state of the simulator via exposed REST API with HTTP GET query.
+func (c *Client) Export() ([]byte, error) {
+	resp, err := http.Get("http://" + c.url + "/api/export")
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error %s", body)
+	}
+
+	return body, nil
+}
+
+// Get errors from the error queue of the simulator via exposed REST API with HTTP GET query.
+func (c *Client) Errors() (info.ErrorQueue, error) {
+	var queue info.ErrorQueue
+	resp, err := http.Get("http://" + c.url + "/api/errors")
+	if err != nil {
+		return queue, err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return queue, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return queue, fmt.Errorf("API error %s", body)
+	}
+
+	err = json.Unmarshal(body, &queue)
+	return queue, err
+}
+
+// Remove errors from the error queue of the simulator via exposed REST API with HTTP DELETE query.
+func (c *Client) ClearErrors() error {
+	req, err := http.NewRequest(http.MethodDelete, "http://"+c.url+"/api/errors", nil)
 	if err != nil {
 		return err
 	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
 	defer func() {
 		_ = resp.Body.Close()
 	}()
@@ -173,5 +428,6 @@ func (c *Client) Trigger(param string) error {
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("API error %s", body)
 	}
+
 	return nil
 }
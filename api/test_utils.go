@@ -4,6 +4,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -43,3 +44,33 @@ func (ts *testServer) set(t *testing.T, urlPath string) (int, http.Header, []byt
 	}
 	return rs.StatusCode, rs.Header, bodyy
 }
+
+func (ts *testServer) delete(t *testing.T, urlPath string) (int, http.Header, []byte) {
+	req, err := http.NewRequest(http.MethodDelete, ts.URL+urlPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, rs.Header, body
+}
+
+func (ts *testServer) post(t *testing.T, urlPath, contentType, content string) (int, http.Header, []byte) {
+	rs, err := ts.Client().Post(ts.URL+urlPath, contentType, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, rs.Header, body
+}
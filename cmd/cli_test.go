@@ -23,6 +23,9 @@ const (
 	API_ADDR = "127.0.0.1:7777"
 )
 
+// device served by the HTTP API used in tests
+var dev *device.StreamDevice
+
 func TestMain(m *testing.M) {
 	config, err := vdfile.DecodeVDFile(FILE)
 	if err != nil {
@@ -40,6 +43,12 @@ func TestMain(m *testing.M) {
 		}
 	}
 	config.Mismatch = "Wrong query"
+	config.Errors = &vdfile.ConfigErrors{
+		UnknownCommand: &vdfile.DeviceError{Code: 1, Msg: "unknown command", Status: 4},
+	}
+	config.Presets = map[string]vdfile.State{
+		"low_ff": {Params: map[string]any{"ff": int64(1)}},
+	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 
@@ -50,14 +59,14 @@ func TestMain(m *testing.M) {
 	}
 
 	// create stream device
-	d, err := device.NewDevice(vdfile)
+	dev, err = device.NewDevice(vdfile)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
 	// create instance of HTTP server
-	a := api.NewHttpApiServer(d)
+	a := api.NewHttpApiServer(dev)
 
 	go func() {
 		// run HTTP server with REST API
@@ -96,7 +105,7 @@ func TestGetMismatch(t *testing.T) {
 		api  string
 	}{
 		{"get mismatch", "Wrong query\n", API_ADDR},
-		{"wrong api addr", `Error: Get "http://127.0.0.1:7878/mismatch": dial tcp 127.0.0.1:7878: connect: connection refused` + "\n", "127.0.0.1:7878"},
+		{"wrong api addr", `Error: Get "http://127.0.0.1:7878/api/mismatch": dial tcp 127.0.0.1:7878: connect: connection refused` + "\n", "127.0.0.1:7878"},
 		{"wrong api addr format", "Error: wrong HTTP address\n", "127.test"},
 	}
 
@@ -121,7 +130,7 @@ func TestGetParameter(t *testing.T) {
 	}{
 		{"get version", "version", "version 1.0\n", API_ADDR},
 		{"get mode", "mode", "NORM\n", API_ADDR},
-		{"wrong api addr", "version", `Error: Get "http://127.0.0.1:7878/version": dial tcp 127.0.0.1:7878: connect: connection refused` + "\n", "127.0.0.1:7878"},
+		{"wrong api addr", "version", `Error: Get "http://127.0.0.1:7878/api/parameters/version": dial tcp 127.0.0.1:7878: connect: connection refused` + "\n", "127.0.0.1:7878"},
 		{"wrong api addr format", "version", "Error: wrong HTTP address\n", "127.test"},
 		{"wrong cmd", "test", "Error: API error Error: parameter not found: test\n", API_ADDR},
 	}
@@ -146,7 +155,7 @@ func TestGetDelay(t *testing.T) {
 		api   string
 	}{
 		{"get_psi delay", "get_psi", "3s\n", API_ADDR},
-		{"wrong api addr", "get_psi", `Error: Get "http://127.0.0.1:7878/delay/get_psi": dial tcp 127.0.0.1:7878: connect: connection refused` + "\n", "127.0.0.1:7878"},
+		{"wrong api addr", "get_psi", `Error: Get "http://127.0.0.1:7878/api/delay/get_psi": dial tcp 127.0.0.1:7878: connect: connection refused` + "\n", "127.0.0.1:7878"},
 		{"wrong api addr format", "get_psi", "Error: wrong HTTP address\n", "127.test"},
 		{"wrong cmd", "get_test", "Error: API error Error: command not found: get_test\n", API_ADDR},
 	}
@@ -171,7 +180,7 @@ func TestTrigger(t *testing.T) {
 		api   string
 	}{
 		{"trig get_current", "get_current", "Error: API error Error: no client available\n", API_ADDR},
-		{"wrong api addr", "get_psi", `Error: Post "http://127.0.0.1:7878/trigger/get_psi": dial tcp 127.0.0.1:7878: connect: connection refused` + "\n", "127.0.0.1:7878"},
+		{"wrong api addr", "get_psi", `Error: Post "http://127.0.0.1:7878/api/trigger/get_psi": dial tcp 127.0.0.1:7878: connect: connection refused` + "\n", "127.0.0.1:7878"},
 		{"wrong api addr format", "get_psi", "Error: wrong HTTP address\n", "127.test"},
 		{"wrong cmd", "get_test", "Error: API error Error: command not found: get_test\n", API_ADDR},
 	}
@@ -210,7 +219,7 @@ func TestSetParameterWrong(t *testing.T) {
 		exp   string
 		api   string
 	}{
-		{"wrong api addr", "current 30", `Error: Post "http://127.0.0.1:7878/current/30": dial tcp 127.0.0.1:7878: connect: connection refused` + "\n", "127.0.0.1:7878"},
+		{"wrong api addr", "current 30", `Error: Post "http://127.0.0.1:7878/api/parameters/current/30": dial tcp 127.0.0.1:7878: connect: connection refused` + "\n", "127.0.0.1:7878"},
 		{"wrong api addr format", "current 30", "Error: wrong HTTP address\n", "127.test"},
 		{"wrong set value", "current test", "Error: API error Error: received param type that cannot be converted to int\n", API_ADDR},
 		{"wrong param", "test 20", "Error: API error Error: parameter not found: test\n", API_ADDR},
@@ -258,7 +267,7 @@ func TestSetMismatchWrong(t *testing.T) {
 		exp   string
 		api   string
 	}{
-		{"wrong api addr", "error", `Error: Post "http://127.0.0.1:7878/mismatch/error": dial tcp 127.0.0.1:7878: connect: connection refused` + "\n", "127.0.0.1:7878"},
+		{"wrong api addr", "error", `Error: Post "http://127.0.0.1:7878/api/mismatch/error": dial tcp 127.0.0.1:7878: connect: connection refused` + "\n", "127.0.0.1:7878"},
 		{"wrong api addr format", "error", "Error: wrong HTTP address\n", "127.test"},
 		{"too long message", mis, "Error: API error Error: new mismatch message exceeded 255 characters limit: " + mis + "\n", API_ADDR},
 	}
@@ -298,7 +307,7 @@ func TestSetDelayWrong(t *testing.T) {
 		exp   string
 		api   string
 	}{
-		{"wrong api addr", "get_psi 10s", `Error: Post "http://127.0.0.1:7878/delay/get_psi/10s": dial tcp 127.0.0.1:7878: connect: connection refused` + "\n", "127.0.0.1:7878"},
+		{"wrong api addr", "get_psi 10s", `Error: Post "http://127.0.0.1:7878/api/delay/get_psi/10s": dial tcp 127.0.0.1:7878: connect: connection refused` + "\n", "127.0.0.1:7878"},
 		{"wrong api addr format", "get_psi 10s", "Error: wrong HTTP address\n", "127.test"},
 		{"wrong set value", "get_psi test", "Error: API error Error: time: invalid duration \"test\"\n", API_ADDR},
 		{"wrong cmd", "get_test 10s", "Error: API error Error: command not found: get_test\n", API_ADDR},
@@ -343,3 +352,130 @@ func TestCLIEnvVars(t *testing.T) {
 		})
 	}
 }
+
+func TestHistory(t *testing.T) {
+	dev.ClearHistory()
+	dev.Handle(nil, []byte("CUR?\r\n"))
+	dev.Handle(nil, []byte("PSI 3.46\r\n"))
+	dev.Handle(nil, []byte("TEST?\r\n"))
+
+	tests := []struct {
+		name string
+		args string
+		exp  []string
+	}{
+		{"all requests", "history", []string{
+			"\t0\tGetParam\tget_current\tCUR?",
+			"\t0\tSetParam\tset_psi\tPSI 3.46\tpsi=3.46",
+			"\t0\tMismatch\t\tTEST?\t\tcommand not found",
+		}},
+		{"command", "history --command set_psi --since 1m", []string{"\t0\tSetParam\tset_psi\tPSI 3.46\tpsi=3.46"}},
+		{"limit", "history --command  --limit 1", []string{"\t0\tMismatch\t\tTEST?\t\tcommand not found"}},
+		{"wrong since", "history --since yesterday", []string{"Error: wrong since duration"}},
+		{"clear", "history clear", []string{"OK"}},
+		{"empty after clear", "history --since 1m --limit 0", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := append(strings.Split(tt.args, " "), "--apiAddr", API_ADDR)
+			res := execute(in)
+			lines := strings.Split(strings.TrimSuffix(res, "\n"), "\n")
+			if res == "" {
+				lines = []string{}
+			}
+			if len(lines) != len(tt.exp) {
+				t.Fatalf("exp %d lines got %q", len(tt.exp), res)
+			}
+			for i, exp := range tt.exp {
+				if !strings.HasSuffix(lines[i], exp) {
+					t.Errorf("exp line ending with %q got %q", exp, lines[i])
+				}
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	dev.ClearErrors()
+	dev.Handle(nil, []byte("TEST?\r\n"))
+
+	tests := []struct {
+		name string
+		args string
+		exp  string
+	}{
+		{"errors", "errors", "status\t4\n1\tunknown command\n"},
+		{"clear", "errors clear", "OK\n"},
+		{"empty after clear", "errors", "status\t0\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := append(strings.Split(tt.args, " "), "--apiAddr", API_ADDR)
+			res := execute(in)
+			if res != tt.exp {
+				t.Errorf("exp %q got %q", tt.exp, res)
+			}
+		})
+	}
+}
+
+func TestPreset(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		exp  string
+	}{
+		{"list presets", "preset list", "low_ff\n"},
+		{"apply preset", "preset apply low_ff", "OK\n"},
+		{"apply unknown preset", "preset apply missing", "Error: API error Error: preset not found: missing\n"},
+		{"wrong api addr format", "preset list --apiAddr 127.test", "Error: wrong HTTP address\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := append(strings.Split(tt.args, " "), "--apiAddr", API_ADDR)
+			if strings.Contains(tt.args, "--apiAddr") {
+				in = strings.Split(tt.args, " ")
+			}
+			res := execute(in)
+			if res != tt.exp {
+				t.Errorf("exp value: %s got %s\n", tt.exp, res)
+			}
+		})
+	}
+
+	if v, _ := dev.GetParameter("ff"); v != int64(1) {
+		t.Errorf("exp ff 1 after preset got %v", v)
+	}
+}
+
+func TestExport(t *testing.T) {
+	execute([]string{"set", "mismatch", "Wrong query", "--apiAddr", API_ADDR})
+	execute([]string{"set", "delay", "get_mode", "5s", "--apiAddr", API_ADDR})
+
+	path := t.TempDir() + "/vdfile"
+	res := execute([]string{"export", "--out", path, "--apiAddr", API_ADDR})
+	if res != "OK\n" {
+		t.Fatalf("exp OK got %s", res)
+	}
+
+	config, err := vdfile.DecodeVDFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Mismatch != "Wrong query" {
+		t.Errorf("exp mismatch Wrong query got %s", config.Mismatch)
+	}
+	for _, c := range config.Commands {
+		if c.Name == "get_mode" && c.Dly != "5s" {
+			t.Errorf("exp delay of get_mode 5s got %s", c.Dly)
+		}
+	}
+
+	res = execute([]string{"export", "--out", "", "--apiAddr", API_ADDR})
+	if !strings.HasPrefix(res, "interm = ") {
+		t.Errorf("exp vdfile on standard output got %s", res)
+	}
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/e9ctrl/vd/api"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var clientsCmd = &cobra.Command{
+	Use:   "clients",
+	Args:  cobra.NoArgs,
+	Short: "Command to list clients connected to the simulator",
+	Long: `This command lists clients connected to the TCP server of the simulator.
+It communicates with REST API of the simulator and using HTTP GET it reads list of clients.
+Examples:
+	vd clients
+	vd clients --apiAddr 127.0.0.1:7070
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !verifyIPAddr(apiAddr) {
+			return fmt.Errorf("wrong HTTP address")
+		}
+
+		c := api.NewClient(apiAddr)
+		clients, err := c.Clients()
+		if err != nil {
+			return err
+		}
+
+		for _, cl := range clients {
+			fmt.Fprintf(cmd.OutOrStdout(), "%d\t%s\t%s\n", cl.ID, cl.RemoteAddr, cl.ConnectedAt.Format(time.RFC3339))
+		}
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(clientsCmd)
+	clientsCmd.PersistentFlags().StringVarP(&apiAddr, "apiAddr", "a", "127.0.0.1:8080", "VD HTTP API address")
+	// Binds viper apiAddr flag to cobra apiAddr pflag
+	viper.BindPFlag("apiAddr", clientsCmd.PersistentFlags().Lookup("apiAddr"))
+	// Binds viper apiAddr flag to VD_API_ADDR environment variable
+	viper.BindEnv("apiAddr", "VD_API_ADDR")
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/e9ctrl/vd/console"
+	"github.com/e9ctrl/vd/vdfile"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	consoleAddr    string
+	consoleVDFile  string
+	consoleInterm  string
+	consoleOutterm string
+)
+
+var consoleCmd = &cobra.Command{
+	Use:   "console",
+	Args:  cobra.NoArgs,
+	Short: "Interactive console to communicate with the simulator or a real device",
+	Long: `This command opens TCP connection and sends every typed line with the in terminator appended.
+Replies are printed with terminators and non-printable characters escaped, together with their hex representation.
+When vdfile is given, its terminators are used and TAB completes requests defined in [[command]] entries.
+Terminators can also be set directly, e.g. to talk to a real device without vdfile.
+Examples:
+	vd console --vdfile vdfile.toml
+	vd console --vdfile vdfile.toml --addr 192.168.1.20:4001
+	vd console --addr 192.168.1.20:4001 --interm "CR LF" --outterm "CR LF"
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			interm, outterm []byte
+			patterns        []string
+		)
+
+		if consoleVDFile != "" {
+			vdfile, err := vdfile.ReadVDFile(consoleVDFile)
+			if err != nil {
+				return err
+			}
+			interm, outterm = vdfile.InTerminator, vdfile.OutTerminator
+			for _, c := range vdfile.Commands {
+				patterns = append(patterns, string(c.Req))
+			}
+		}
+		if cmd.Flags().Changed("interm") {
+			interm = vdfile.ParseTerminator(consoleInterm)
+		}
+		if cmd.Flags().Changed("outterm") {
+			outterm = vdfile.ParseTerminator(consoleOutterm)
+		}
+
+		conn, err := net.DialTimeout("tcp", consoleAddr, 5*time.Second)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		fmt.Fprintf(cmd.OutOrStdout(), "connected to %s, press Ctrl-D to exit\n", consoleAddr)
+		return console.New(conn, interm, outterm, patterns).Run(os.Stdin, cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(consoleCmd)
+	consoleCmd.Flags().StringVar(&consoleAddr, "addr", "127.0.0.1:9999", "TCP address of the simulator or device")
+	consoleCmd.Flags().StringVar(&consoleVDFile, "vdfile", "", "vdfile with terminators and requests used for completion")
+	consoleCmd.Flags().StringVar(&consoleInterm, "interm", "", "in terminator appended to requests, overrides vdfile")
+	consoleCmd.Flags().StringVar(&consoleOutterm, "outterm", "", "out terminator splitting replies, overrides vdfile")
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/e9ctrl/vd/api"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var disconnectCmd = &cobra.Command{
+	Use:   "disconnect [client id]",
+	Args:  cobra.ExactArgs(1),
+	Short: "Command to close connection of the specified client",
+	Long: `This command closes the TCP connection of the client with the given id.
+Ids of connected clients are listed by the clients command.
+Examples:
+	vd disconnect 2
+	vd disconnect 2 --apiAddr 127.0.0.1:7070
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !verifyIPAddr(apiAddr) {
+			return fmt.Errorf("wrong HTTP address")
+		}
+
+		id, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("wrong client id")
+		}
+
+		c := api.NewClient(apiAddr)
+		err = c.Disconnect(id)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprint(cmd.OutOrStdout(), "OK\n")
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(disconnectCmd)
+	disconnectCmd.PersistentFlags().StringVarP(&apiAddr, "apiAddr", "a", "127.0.0.1:8080", "VD HTTP API address")
+	// Binds viper apiAddr flag to cobra apiAddr pflag
+	viper.BindPFlag("apiAddr", disconnectCmd.PersistentFlags().Lookup("apiAddr"))
+	// Binds viper apiAddr flag to VD_API_ADDR environment variable
+	viper.BindEnv("apiAddr", "VD_API_ADDR")
+}
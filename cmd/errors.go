@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/e9ctrl/vd/api"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var errorsCmd = &cobra.Command{
+	Use:   "errors",
+	Args:  cobra.NoArgs,
+	Short: "Command to list errors in the error queue of the simulator",
+	Long: `This command prints the status register and lists errors in the error queue of the simulator, the oldest first.
+Every line contains code and message of the error. The device needs errors section in the vdfile or scpi profile.
+It communicates with REST API of the simulator and using HTTP GET it reads the errors.
+Examples:
+	vd errors
+	vd errors --apiAddr 127.0.0.1:7070
+	vd errors clear
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !verifyIPAddr(apiAddr) {
+			return fmt.Errorf("wrong HTTP address")
+		}
+
+		c := api.NewClient(apiAddr)
+		info, err := c.Errors()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "status\t%d\n", info.Status)
+		for _, e := range info.Errors {
+			fmt.Fprintf(cmd.OutOrStdout(), "%d\t%s\n", e.Code, e.Msg)
+		}
+		return nil
+	},
+}
+
+var errorsClearCmd = &cobra.Command{
+	Use:   "clear",
+	Args:  cobra.NoArgs,
+	Short: "Command to remove all errors from the error queue",
+	Long: `This command removes all errors from the error queue of the simulator and clears the status register using HTTP DELETE.
+Examples:
+	vd errors clear
+	vd errors clear --apiAddr 127.0.0.1:7070
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !verifyIPAddr(apiAddr) {
+			return fmt.Errorf("wrong HTTP address")
+		}
+
+		c := api.NewClient(apiAddr)
+		if err := c.ClearErrors(); err != nil {
+			return err
+		}
+
+		fmt.Fprint(cmd.OutOrStdout(), "OK\n")
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(errorsCmd)
+	errorsCmd.AddCommand(errorsClearCmd)
+	errorsCmd.PersistentFlags().StringVarP(&apiAddr, "apiAddr", "a", "127.0.0.1:8080", "VD HTTP API address")
+	// Binds viper apiAddr flag to cobra apiAddr pflag
+	viper.BindPFlag("apiAddr", errorsCmd.PersistentFlags().Lookup("apiAddr"))
+	// Binds viper apiAddr flag to VD_API_ADDR environment variable
+	viper.BindEnv("apiAddr", "VD_API_ADDR")
+}
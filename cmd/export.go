@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/e9ctrl/vd/api"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var exportOut string
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Args:  cobra.NoArgs,
+	Short: "Command to write the current state of the simulator as vdfile",
+	Long: `This command produces vdfile with current values of parameters, delays of commands and mismatch.
+Definitions and order of parameters and commands are kept as in the vdfile the simulator was started with.
+It communicates with REST API of the simulator and using HTTP GET it reads the vdfile.
+Examples:
+	vd export
+	vd export --out vdfile.toml
+	vd export --out vdfile.toml --apiAddr 127.0.0.1:7070
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !verifyIPAddr(apiAddr) {
+			return fmt.Errorf("wrong HTTP address")
+		}
+
+		c := api.NewClient(apiAddr)
+		data, err := c.Export()
+		if err != nil {
+			return err
+		}
+
+		if exportOut == "" {
+			_, err = cmd.OutOrStdout().Write(data)
+			return err
+		}
+
+		if err := os.WriteFile(exportOut, data, 0666); err != nil {
+			return err
+		}
+		fmt.Fprint(cmd.OutOrStdout(), "OK\n")
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVarP(&exportOut, "out", "o", "", "path of the written vdfile, standard output when empty")
+	exportCmd.PersistentFlags().StringVarP(&apiAddr, "apiAddr", "a", "127.0.0.1:8080", "VD HTTP API address")
+	// Binds viper apiAddr flag to cobra apiAddr pflag
+	viper.BindPFlag("apiAddr", exportCmd.PersistentFlags().Lookup("apiAddr"))
+	// Binds viper apiAddr flag to VD_API_ADDR environment variable
+	viper.BindEnv("apiAddr", "VD_API_ADDR")
+}
@@ -25,6 +25,7 @@ Examples:
 		}
 
 		c := api.NewClient(apiAddr)
+		c.Force = force
 		res, err := c.GetParameter(args[0])
 		if err != nil {
 			return err
@@ -43,4 +44,5 @@ func init() {
 	viper.BindPFlag("apiAddr", getCmd.PersistentFlags().Lookup("apiAddr"))
 	// Binds viper apiAddr flag to VD_API_ADDR environment variable
 	viper.BindEnv("apiAddr", "VD_API_ADDR")
+	getCmd.Flags().BoolVar(&force, "force", false, "ignore access level of the parameter")
 }
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/e9ctrl/vd/api"
+	"github.com/e9ctrl/vd/device"
+	"github.com/e9ctrl/vd/log"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	historyCommand string
+	historySince   string
+	historyLimit   int
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Args:  cobra.NoArgs,
+	Short: "Command to list requests received by the simulator",
+	Long: `This command lists requests received by the simulator, the oldest first.
+Every line contains time, client id, type of the request, matched command, the request itself, set values and error.
+It communicates with REST API of the simulator and using HTTP GET it reads the history.
+Examples:
+	vd history
+	vd history --command set_current --since 5m
+	vd history --limit 10 --apiAddr 127.0.0.1:7070
+	vd history clear
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !verifyIPAddr(apiAddr) {
+			return fmt.Errorf("wrong HTTP address")
+		}
+
+		filter := device.HistoryFilter{
+			Command: historyCommand,
+			Limit:   historyLimit,
+		}
+		if historySince != "" {
+			d, err := time.ParseDuration(historySince)
+			if err != nil {
+				return fmt.Errorf("wrong since duration")
+			}
+			filter.Since = time.Now().Add(-d)
+		}
+
+		c := api.NewClient(apiAddr)
+		entries, err := c.History(filter)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			fmt.Fprintln(cmd.OutOrStdout(), formatHistoryEntry(e))
+		}
+		return nil
+	},
+}
+
+var historyClearCmd = &cobra.Command{
+	Use:   "clear",
+	Args:  cobra.NoArgs,
+	Short: "Command to remove all requests from the history",
+	Long: `This command removes all requests from the history of the simulator using HTTP DELETE.
+Examples:
+	vd history clear
+	vd history clear --apiAddr 127.0.0.1:7070
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !verifyIPAddr(apiAddr) {
+			return fmt.Errorf("wrong HTTP address")
+		}
+
+		c := api.NewClient(apiAddr)
+		if err := c.ClearHistory(); err != nil {
+			return err
+		}
+
+		fmt.Fprint(cmd.OutOrStdout(), "OK\n")
+		return nil
+	},
+}
+
+// single line with all fields of the entry separated by tabs
+func formatHistoryEntry(e device.HistoryEntry) string {
+	names := make([]string, 0, len(e.Values))
+	for name := range e.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = fmt.Sprintf("%s=%v", name, e.Values[name])
+	}
+
+	fields := []string{
+		e.Time.Local().Format("15:04:05.000"),
+		fmt.Sprint(e.Client),
+		e.Typ,
+		e.Command,
+		log.Escape([]byte(e.Raw)),
+		strings.Join(values, " "),
+		e.Error,
+	}
+	return strings.TrimRight(strings.Join(fields, "\t"), "\t")
+}
+
+func init() {
+	RootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyClearCmd)
+	historyCmd.Flags().StringVarP(&historyCommand, "command", "c", "", "show only requests of the command")
+	historyCmd.Flags().StringVarP(&historySince, "since", "s", "", "show only requests received within the duration, e.g. 5m")
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "l", 0, "show only the given number of the newest requests")
+	historyCmd.PersistentFlags().StringVarP(&apiAddr, "apiAddr", "a", "127.0.0.1:8080", "VD HTTP API address")
+	// Binds viper apiAddr flag to cobra apiAddr pflag
+	viper.BindPFlag("apiAddr", historyCmd.PersistentFlags().Lookup("apiAddr"))
+	// Binds viper apiAddr flag to VD_API_ADDR environment variable
+	viper.BindEnv("apiAddr", "VD_API_ADDR")
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/e9ctrl/vd/api"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var presetCmd = &cobra.Command{
+	Use:   "preset",
+	Short: "Commands to list and apply presets defined in the vdfile",
+	Long: `Presets are named states of the device declared in the vdfile as [preset.name] tables.
+Applying a preset sets listed parameters, delays and mismatch at once.
+Examples:
+	vd preset list
+	vd preset apply fault_condition
+`,
+}
+
+var presetListCmd = &cobra.Command{
+	Use:   "list",
+	Args:  cobra.NoArgs,
+	Short: "Command to list presets defined in the vdfile",
+	Long: `This command lists names of presets defined in the vdfile of the simulator.
+It communicates with REST API of the simulator and using HTTP GET it reads the presets.
+Examples:
+	vd preset list
+	vd preset list --apiAddr 127.0.0.1:7070
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !verifyIPAddr(apiAddr) {
+			return fmt.Errorf("wrong HTTP address")
+		}
+
+		c := api.NewClient(apiAddr)
+		presets, err := c.Presets()
+		if err != nil {
+			return err
+		}
+
+		for _, p := range presets {
+			fmt.Fprintln(cmd.OutOrStdout(), p)
+		}
+		return nil
+	},
+}
+
+var presetApplyCmd = &cobra.Command{
+	Use:   "apply [preset name]",
+	Args:  cobra.ExactArgs(1),
+	Short: "Command to apply preset defined in the vdfile",
+	Long: `This command applies the preset with the given name. Either all its values are applied or none of them.
+It communicates with REST API of the simulator using HTTP POST.
+Examples:
+	vd preset apply fault_condition
+	vd preset apply fault_condition --apiAddr 127.0.0.1:7070
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !verifyIPAddr(apiAddr) {
+			return fmt.Errorf("wrong HTTP address")
+		}
+
+		c := api.NewClient(apiAddr)
+		err := c.ApplyPreset(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprint(cmd.OutOrStdout(), "OK\n")
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(presetCmd)
+	presetCmd.AddCommand(presetListCmd)
+	presetCmd.AddCommand(presetApplyCmd)
+	presetCmd.PersistentFlags().StringVarP(&apiAddr, "apiAddr", "a", "127.0.0.1:8080", "VD HTTP API address")
+	// Binds viper apiAddr flag to cobra apiAddr pflag
+	viper.BindPFlag("apiAddr", presetCmd.PersistentFlags().Lookup("apiAddr"))
+	// Binds viper apiAddr flag to VD_API_ADDR environment variable
+	viper.BindEnv("apiAddr", "VD_API_ADDR")
+}
@@ -7,9 +7,11 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/e9ctrl/vd/api"
 	"github.com/e9ctrl/vd/device"
+	"github.com/e9ctrl/vd/log"
 	"github.com/e9ctrl/vd/server"
 	"github.com/e9ctrl/vd/vdfile"
 	"github.com/jwalton/gchalk"
@@ -20,6 +22,9 @@ import (
 var cfgFile string
 var apiAddr string
 
+// get and set ignore access levels of parameters
+var force bool
+
 var version = "0.0.1"
 var longVersion = "0.0.1"
 
@@ -50,11 +55,31 @@ To run simulator create .toml file with device description and run it using foll
 
 	vd vdfile.toml
 	vd vdfile.toml --listenAddr 127.0.0.1:6666
+	vd vdfile.toml --log-format json --log-file vd.log
+	vd vdfile.toml --state-file vd.state
 
 By default, vd is listenning on 127.0.0.1:9999.`,
 	Run: func(cmd *cobra.Command, args []string) {
 
 		fmt.Printf(banner, version, website)
+		level, err := log.ParseLevel(viper.GetString("log-level"))
+		if err != nil {
+			fmt.Printf("Wrong log level %v", err)
+			os.Exit(1)
+		}
+
+		err = log.Setup(log.Options{
+			Level:      level,
+			Format:     viper.GetString("log-format"),
+			File:       viper.GetString("log-file"),
+			MaxSize:    viper.GetInt("log-max-size"),
+			MaxBackups: viper.GetInt("log-max-backups"),
+		})
+		if err != nil {
+			fmt.Printf("Logger setup failed %v", err)
+			os.Exit(1)
+		}
+
 		// parse config file
 		vdfile, err := vdfile.ReadVDFile(args[0])
 		if err != nil {
@@ -72,6 +97,15 @@ By default, vd is listenning on 127.0.0.1:9999.`,
 			os.Exit(1)
 		}
 
+		// restore parameter values saved by the previous run and keep saving them
+		if stateFile := viper.GetString("state-file"); stateFile != "" {
+			if err := str.LoadState(stateFile); err != nil {
+				log.ERR("state file not fully loaded", "file", stateFile, "err", err)
+			}
+			stopPersist := str.PersistState(stateFile, viper.GetDuration("state-save-interval"))
+			defer stopPersist()
+		}
+
 		ip := viper.GetString("listenAddr")
 		if !verifyIPAddr(ip) {
 			fmt.Println("Wrong TCP address")
@@ -88,6 +122,7 @@ By default, vd is listenning on 127.0.0.1:9999.`,
 		// run TCP simulator server
 		go srv.Start()
 		fmt.Println("vd running on ", gchalk.BrightYellow(ip))
+		log.INF("vd running", "addr", ip)
 
 		addr := viper.GetString("httpListenAddr")
 		if !verifyIPAddr(addr) {
@@ -109,7 +144,7 @@ By default, vd is listenning on 127.0.0.1:9999.`,
 
 		<-ctx.Done()
 		srv.Stop()
-		fmt.Println("vd stopped")
+		log.INF("vd stopped")
 	},
 }
 
@@ -150,6 +185,36 @@ func init() {
 	// Set default flag in viper cause the default one from cobra is not used
 	viper.SetDefault("listenAddr", "127.0.0.1:9999")
 
+	RootCmd.Flags().String("log-level", "debug", "Minimal level of logged messages (debug, info, warn, error), traffic is logged with debug level")
+	viper.BindPFlag("log-level", RootCmd.Flags().Lookup("log-level"))
+	viper.BindEnv("log-level", "VD_LOG_LEVEL")
+	viper.SetDefault("log-level", "debug")
+
+	RootCmd.Flags().String("log-format", log.FormatConsole, "Format of log messages (console, text, json)")
+	viper.BindPFlag("log-format", RootCmd.Flags().Lookup("log-format"))
+	viper.BindEnv("log-format", "VD_LOG_FORMAT")
+	viper.SetDefault("log-format", log.FormatConsole)
+
+	RootCmd.Flags().String("log-file", "", "Write logs to the file instead of standard output, the file is rotated")
+	viper.BindPFlag("log-file", RootCmd.Flags().Lookup("log-file"))
+	viper.BindEnv("log-file", "VD_LOG_FILE")
+
+	RootCmd.Flags().Int("log-max-size", 100, "Size in megabytes after which log file is rotated")
+	viper.BindPFlag("log-max-size", RootCmd.Flags().Lookup("log-max-size"))
+	viper.SetDefault("log-max-size", 100)
+
+	RootCmd.Flags().Int("log-max-backups", 5, "Number of rotated log files to keep")
+	viper.BindPFlag("log-max-backups", RootCmd.Flags().Lookup("log-max-backups"))
+	viper.SetDefault("log-max-backups", 5)
+
+	RootCmd.Flags().String("state-file", "", "Save parameter values to the file on change and load them on startup")
+	viper.BindPFlag("state-file", RootCmd.Flags().Lookup("state-file"))
+	viper.BindEnv("state-file", "VD_STATE_FILE")
+
+	RootCmd.Flags().Duration("state-save-interval", time.Second, "Changes of parameters within the interval are saved to the state file at once")
+	viper.BindPFlag("state-save-interval", RootCmd.Flags().Lookup("state-save-interval"))
+	viper.SetDefault("state-save-interval", time.Second)
+
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	RootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
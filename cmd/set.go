@@ -26,6 +26,7 @@ Examples:
 		}
 
 		c := api.NewClient(apiAddr)
+		c.Force = force
 		err := c.SetParameter(args[0], args[1])
 		if err != nil {
 			return err
@@ -43,4 +44,5 @@ func init() {
 	viper.BindPFlag("apiAddr", setCmd.PersistentFlags().Lookup("apiAddr"))
 	// Binds viper apiAddr flag to VD_API_ADDR environment variable
 	viper.BindEnv("apiAddr", "VD_API_ADDR")
+	setCmd.Flags().BoolVar(&force, "force", false, "ignore access level of the parameter")
 }
@@ -37,5 +37,5 @@ Examples:
 }
 
 func init() {
-	getCmd.AddCommand(setMismatchCmd)
+	setCmd.AddCommand(setMismatchCmd)
 }
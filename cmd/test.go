@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/e9ctrl/vd/log"
+	"github.com/e9ctrl/vd/scenario"
+	"github.com/e9ctrl/vd/vdfile"
+
+	"github.com/jwalton/gchalk"
+	"github.com/spf13/cobra"
+)
+
+var (
+	testVDFile  string
+	testJUnit   string
+	testVerbose bool
+)
+
+var testCmd = &cobra.Command{
+	Use:   "test [scenario]",
+	Args:  cobra.ExactArgs(1),
+	Short: "Command to run test scenario against the simulator",
+	Long: `This command starts the simulator from vdfile in-process on a random port and runs steps of the scenario as its client.
+Steps send requests and check replies, set and assert parameters, wait and trigger commands.
+Result of every step is printed, optionally it is also written as JUnit XML report.
+Examples:
+	vd test scenario.toml
+	vd test scenario.toml --vdfile vdfile --junit report.xml
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// failing steps are not usage errors
+		cmd.SilenceUsage = true
+
+		level := slog.LevelError
+		if testVerbose {
+			level = slog.LevelDebug
+		}
+		if err := log.Setup(log.Options{Level: level}); err != nil {
+			return err
+		}
+
+		sc, err := scenario.Load(args[0])
+		if err != nil {
+			return err
+		}
+		if testVDFile != "" {
+			sc.VDFile = testVDFile
+		}
+		if sc.VDFile == "" {
+			return fmt.Errorf("vdfile not given in scenario nor with --vdfile flag")
+		}
+
+		vd, err := vdfile.ReadVDFile(sc.VDFile)
+		if err != nil {
+			return err
+		}
+
+		results, err := sc.Run(vd)
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		failed := 0
+		for _, r := range results {
+			if r.Passed() {
+				fmt.Fprintf(out, "%s %s (%s)\n", gchalk.BrightGreen("PASS"), r.Name, r.Duration.Round(time.Microsecond))
+				continue
+			}
+			failed++
+			fmt.Fprintf(out, "%s %s: %v\n", gchalk.BrightRed("FAIL"), r.Name, r.Err)
+		}
+
+		if testJUnit != "" {
+			f, err := os.Create(testJUnit)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if err := scenario.WriteJUnit(f, sc.Name, results); err != nil {
+				return err
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d of %d steps failed", failed, len(results))
+		}
+		fmt.Fprintf(out, "all %d steps passed\n", len(results))
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(testCmd)
+	testCmd.Flags().StringVar(&testVDFile, "vdfile", "", "vdfile of the simulator, overrides the one from scenario")
+	testCmd.Flags().StringVar(&testJUnit, "junit", "", "path of JUnit XML report")
+	testCmd.Flags().BoolVarP(&testVerbose, "verbose", "v", false, "print logs of the simulator")
+}
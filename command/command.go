@@ -8,5 +8,18 @@ type Command struct {
 	Name string
 	Req  []byte
 	Res  []byte
-	Dly  time.Duration
+	// Response sent as separate lines, used instead of Res when res is a list
+	Lines [][]byte
+	Dly   time.Duration
+	// Delay between lines of multi-line response
+	LineDly time.Duration
+	// Received request is sent back before the response
+	Echo bool
+	// Responses sent when set of the parameter fails, mismatch is used when empty
+	OnInvalid    []byte
+	OnOutOfRange []byte
+	OnTypeError  []byte
+	// Terminators of the request and response lines, global ones are used when nil
+	InTerminator  []byte
+	OutTerminator []byte
 }
@@ -0,0 +1,223 @@
+package console
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/e9ctrl/vd/log"
+	"golang.org/x/term"
+)
+
+const (
+	prompt = "> "
+	// time after which reply without out terminator is printed
+	idleFlush = 100 * time.Millisecond
+)
+
+// Interactive console sending lines typed by the user to the connection
+type Console struct {
+	conn     net.Conn
+	interm   []byte
+	outterm  []byte
+	patterns []string
+	mu       sync.Mutex
+	out      io.Writer
+}
+
+// Create console, interm is appended to every sent line and outterm splits replies,
+// patterns are requests offered by tab-completion
+func New(conn net.Conn, interm, outterm []byte, patterns []string) *Console {
+	p := make([]string, len(patterns))
+	copy(p, patterns)
+	sort.Strings(p)
+	return &Console{
+		conn:     conn,
+		interm:   interm,
+		outterm:  outterm,
+		patterns: p,
+	}
+}
+
+// Run console until input is closed, line editing and completion are enabled when in is a terminal
+func (c *Console) Run(in io.Reader, out io.Writer) error {
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		state, err := term.MakeRaw(int(f.Fd()))
+		if err != nil {
+			return err
+		}
+		defer term.Restore(int(f.Fd()), state)
+
+		t := term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{in, out}, prompt)
+		t.AutoCompleteCallback = c.complete
+		c.out = t
+		return c.loop(t.ReadLine)
+	}
+
+	c.out = out
+	scanner := bufio.NewScanner(in)
+	return c.loop(func() (string, error) {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", io.EOF
+		}
+		return scanner.Text(), nil
+	})
+}
+
+func (c *Console) loop(readLine func() (string, error)) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.receive()
+	}()
+
+	for {
+		line, err := readLine()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			continue
+		}
+
+		msg := append([]byte(line), c.interm...)
+		c.print("-->", msg)
+		if _, err := c.conn.Write(msg); err != nil {
+			return err
+		}
+	}
+
+	// give the device a moment to answer the last request before closing
+	time.Sleep(2 * idleFlush)
+	c.conn.Close()
+	<-done
+	return nil
+}
+
+func (c *Console) receive() {
+	var pending []byte
+	buf := make([]byte, 1024)
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(idleFlush))
+		n, err := c.conn.Read(buf)
+		pending = append(pending, buf[:n]...)
+		pending = c.printReplies(pending)
+
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			if len(pending) > 0 {
+				c.print("<--", pending)
+				pending = nil
+			}
+			continue
+		}
+		if err != nil {
+			if len(pending) > 0 {
+				c.print("<--", pending)
+			}
+			if !errors.Is(err, net.ErrClosed) {
+				c.printf("connection closed: %v\n", err)
+			}
+			return
+		}
+	}
+}
+
+// print complete replies and return what is left after the last out terminator
+func (c *Console) printReplies(data []byte) []byte {
+	if len(c.outterm) == 0 {
+		return data
+	}
+	for {
+		i := bytes.Index(data, c.outterm)
+		if i < 0 {
+			return data
+		}
+		end := i + len(c.outterm)
+		c.print("<--", data[:end])
+		data = data[end:]
+	}
+}
+
+func (c *Console) print(direction string, msg []byte) {
+	c.printf("%s %s  [%s]\n", direction, log.Escape(msg), log.Hex(msg))
+}
+
+func (c *Console) printf(format string, args ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(c.out, format, args...)
+}
+
+// Method to complete the line with literal part of matching request patterns
+func (c *Console) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	matches := c.Complete(line[:pos])
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+
+	prefixes := make([]string, len(matches))
+	for i, m := range matches {
+		prefixes[i] = literal(m)
+	}
+	common := commonPrefix(prefixes)
+	if len(common) > pos {
+		return common + line[pos:], len(common), true
+	}
+
+	if len(matches) > 1 {
+		c.printf("%s\n", strings.Join(matches, "  "))
+	}
+	return "", 0, false
+}
+
+// Request patterns starting with the given input
+func (c *Console) Complete(input string) []string {
+	var matches []string
+	for _, p := range c.patterns {
+		lit := literal(p)
+		if strings.HasPrefix(lit, input) || strings.HasPrefix(input, lit) && lit != p {
+			matches = append(matches, p)
+		}
+	}
+	return matches
+}
+
+// part of the pattern before the first placeholder
+func literal(pattern string) string {
+	if i := strings.Index(pattern, "{"); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+func commonPrefix(strs []string) string {
+	prefix := strs[0]
+	for _, s := range strs[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
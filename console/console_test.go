@@ -0,0 +1,127 @@
+package console
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestComplete(t *testing.T) {
+	t.Parallel()
+	c := New(nil, nil, nil, []string{"CUR?", "CUR {%d:current}", "PSI?", "VER?", "ch1 get"})
+
+	tests := []struct {
+		name  string
+		input string
+		exp   []string
+	}{
+		{"empty input", "", []string{"CUR {%d:current}", "CUR?", "PSI?", "VER?", "ch1 get"}},
+		{"common prefix", "CU", []string{"CUR {%d:current}", "CUR?"}},
+		{"single match", "P", []string{"PSI?"}},
+		{"typing placeholder", "CUR 30", []string{"CUR {%d:current}"}},
+		{"full request", "VER?", []string{"VER?"}},
+		{"no match", "XYZ", nil},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.exp, c.Complete(tt.input)); diff != "" {
+				t.Errorf("unexpected completion (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCompleteKey(t *testing.T) {
+	t.Parallel()
+	c := New(nil, nil, nil, []string{"CUR?", "CUR {%d:current}", "PSI?"})
+	c.out = &bytes.Buffer{}
+
+	line, pos, ok := c.complete("P", 1, '\t')
+	if !ok || line != "PSI?" || pos != 4 {
+		t.Errorf("exp PSI? completed, got %q %d %v", line, pos, ok)
+	}
+
+	line, pos, ok = c.complete("C", 1, '\t')
+	if !ok || line != "CUR" || pos != 3 {
+		t.Errorf("exp CUR completed, got %q %d %v", line, pos, ok)
+	}
+
+	if _, _, ok = c.complete("CUR", 3, '\t'); ok {
+		t.Errorf("exp no completion for ambiguous input")
+	}
+	if !strings.Contains(c.out.(*bytes.Buffer).String(), "CUR {%d:current}  CUR?") {
+		t.Errorf("exp candidates printed, got %q", c.out.(*bytes.Buffer).String())
+	}
+
+	if _, _, ok = c.complete("P", 1, 'x'); ok {
+		t.Errorf("exp no completion for key other than TAB")
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			req, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch req {
+			case "VER?\r\n":
+				conn.Write([]byte("version 1.0\r\n"))
+			case "SPLIT?\r\n":
+				conn.Write([]byte("first\r\nsec"))
+				conn.Write([]byte("ond\r\n"))
+			default:
+				conn.Write([]byte{0x15})
+			}
+		}
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	in := strings.NewReader("VER?\n\nSPLIT?\nBAD\n")
+	err = New(conn, []byte("\r\n"), []byte("\r\n"), nil).Run(in, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		`--> VER?\r\n  [56 45 52 3f 0d 0a]`,
+		`<-- version 1.0\r\n  [76 65 72 73 69 6f 6e 20 31 2e 30 0d 0a]`,
+		`--> SPLIT?\r\n  [53 50 4c 49 54 3f 0d 0a]`,
+		`<-- first\r\n  [66 69 72 73 74 0d 0a]`,
+		`<-- second\r\n  [73 65 63 6f 6e 64 0d 0a]`,
+		`--> BAD\r\n  [42 41 44 0d 0a]`,
+		`<-- \x15  [15]`,
+	}
+	got := out.String()
+	for _, e := range exp {
+		if !strings.Contains(got, e+"\n") {
+			t.Errorf("exp %q in output, got:\n%s", e, got)
+		}
+	}
+}
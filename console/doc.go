@@ -0,0 +1,2 @@
+// console pkg implements interactive TCP client used to talk to the simulator or a real device
+package console
@@ -0,0 +1,54 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/e9ctrl/vd/vdfile"
+)
+
+// Error returned when parameter access does not allow the operation
+var ErrAccessDenied = errors.New("access denied")
+
+// Check if TCP client can read or write the parameter.
+// Access of HTTP API is checked by its handlers, device methods are not restricted
+// so measured values of read only parameters can be simulated.
+func (s *StreamDevice) checkAccess(name string, write bool) error {
+	s.lock.Lock()
+	access := s.vdfile.Access[baseName(name)]
+	s.lock.Unlock()
+
+	var allowed bool
+	switch access {
+	case "", vdfile.AccessReadWrite:
+		allowed = true
+	case vdfile.AccessReadOnly:
+		allowed = !write
+	case vdfile.AccessWriteOnly:
+		allowed = write
+	}
+
+	if !allowed {
+		op := "read"
+		if write {
+			op = "write"
+		}
+		return fmt.Errorf("%w: client cannot %s %s parameter %s", ErrAccessDenied, op, access, name)
+	}
+	return nil
+}
+
+// Access level of the parameter or of the array the element belongs to, rw when it is not restricted
+func (s *StreamDevice) Access(name string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.access(baseName(name))
+}
+
+// Access level of the parameter as written in the vdfile, it has to be called with the lock held
+func (s *StreamDevice) access(name string) string {
+	if access, exists := s.vdfile.Access[name]; exists {
+		return access
+	}
+	return vdfile.AccessReadWrite
+}
@@ -0,0 +1,108 @@
+package device
+
+import (
+	"errors"
+	"testing"
+)
+
+const accessVDFile = `
+interm = "LF"
+outterm = "LF"
+mismatch = "ERR"
+
+[[parameter]]
+  name = "temp"
+  typ = "float"
+  val = 21.5
+  access = "ro"
+  access_err = "E05 READ ONLY"
+
+[[parameter]]
+  name = "password"
+  typ = "string"
+  val = "secret"
+  access = "wo"
+
+[[parameter]]
+  name = "serial"
+  typ = "string"
+  val = "SN01"
+  access = "api-only"
+
+[[command]]
+  name = "get_temp"
+  req = "TEMP?"
+  res = "TEMP {%.1f:temp}"
+
+[[command]]
+  name = "set_temp"
+  req = "TEMP {%.1f:temp}"
+  res = "OK"
+
+[[command]]
+  name = "get_password"
+  req = "PASS?"
+  res = "PASS {%s:password}"
+
+[[command]]
+  name = "set_password"
+  req = "PASS {%s:password}"
+  res = "OK"
+
+[[command]]
+  name = "get_serial"
+  req = "SN?"
+  res = "SN {%s:serial}"
+`
+
+func TestHandleAccess(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, accessVDFile)
+
+	testHandle(t, d, []handleTest{
+		{"read read only", "TEMP?\n", "TEMP 21.5\n"},
+		{"write read only", "TEMP 30.0\n", "E05 READ ONLY\n"},
+		{"write write only", "PASS admin\n", "OK\n"},
+		{"read write only", "PASS?\n", "ERR\n"},
+		{"read api only", "SN?\n", "ERR\n"},
+	})
+
+	if v, _ := d.GetParameter("temp"); v != 21.5 {
+		t.Errorf("exp temp not changed by client got %v", v)
+	}
+	if v, _ := d.GetParameter("password"); v != "admin" {
+		t.Errorf("exp password changed by client got %v", v)
+	}
+
+	// HTTP API drives the simulation and is not restricted
+	if err := d.SetParameter("temp", "25.0"); err != nil {
+		t.Fatal(err)
+	}
+	if res := d.Handle(nil, []byte("TEMP?\n")); string(res) != "TEMP 25.0\n" {
+		t.Errorf("exp temp set via API got %q", res)
+	}
+	if v, err := d.GetParameter("serial"); err != nil || v != "SN01" {
+		t.Errorf("exp serial readable via API got %v %v", v, err)
+	}
+}
+
+func TestTriggerAccess(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, accessVDFile)
+
+	tests := []struct {
+		cmd    string
+		expErr error
+	}{
+		// readable parameter gets as far as sending, nobody listens here
+		{"get_temp", ErrNoClient},
+		{"get_password", ErrAccessDenied},
+		{"get_serial", ErrAccessDenied},
+	}
+
+	for _, tt := range tests {
+		if err := d.Trigger(tt.cmd); !errors.Is(err, tt.expErr) {
+			t.Errorf("%s: exp error %v got %v", tt.cmd, tt.expErr, err)
+		}
+	}
+}
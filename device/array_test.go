@@ -0,0 +1,80 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const arrayVDFile = `
+interm = "LF"
+outterm = "LF"
+mismatch = "ERR"
+
+[[parameter]]
+  name = "wave"
+  typ = "float64[]"
+  val = [1.5, 2.5, 3.5]
+  max_len = 4
+
+[[parameter]]
+  name = "chan"
+  typ = "int[]"
+  len = 3
+  sep = " "
+  min = 0
+  max = 100
+
+[[command]]
+  name = "get_wave"
+  req = "WAVE?"
+  res = "WAVE {%.1f:wave}"
+
+[[command]]
+  name = "set_wave"
+  req = "WAVE {%f:wave}"
+  res = "OK"
+
+[[command]]
+  name = "get_chan"
+  req = "CHAN?"
+  res = "{%d:chan}"
+
+[[command]]
+  name = "set_chan2"
+  req = "CHAN2 {%d:chan[2]}"
+  res = "CHAN2 {%d:chan[2]}"
+
+[[command]]
+  name = "get_chan4"
+  req = "CHAN4?"
+  res = "{%d:chan[4]}"
+`
+
+func TestHandleArray(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, arrayVDFile)
+
+	testHandle(t, d, []handleTest{
+		{"get array", "WAVE?\n", "WAVE 1.5,2.5,3.5\n"},
+		{"set array", "WAVE 0.5,1.25\n", "OK\n"},
+		{"get changed array", "WAVE?\n", "WAVE 0.5,1.2\n"},
+		{"set too long array", "WAVE 1,2,3,4,5\n", "ERR\n"},
+		{"get zero array", "CHAN?\n", "0 0 0\n"},
+		{"set element", "CHAN2 42\n", "CHAN2 42\n"},
+		{"get array with separator", "CHAN?\n", "0 0 42\n"},
+		{"set element out of range", "CHAN2 101\n", "ERR\n"},
+		{"get element outside array", "CHAN4?\n", "ERR\n"},
+	})
+
+	params := d.Parameters()
+	if diff := cmp.Diff([]any{0.5, 1.25}, params[1].Value); diff != "" || params[1].Typ != "float64[]" {
+		t.Errorf("unexpected wave parameter %+v", params[1])
+	}
+
+	// export keeps arrays
+	config := d.Config()
+	if diff := cmp.Diff([]any{int64(0), int64(0), int64(42)}, config.Params[1].Val); diff != "" {
+		t.Errorf("unexpected exported chan (-want +got):\n%s", diff)
+	}
+}
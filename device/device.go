@@ -3,10 +3,17 @@ package device
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/e9ctrl/vd/info"
 	"github.com/e9ctrl/vd/log"
+	"github.com/e9ctrl/vd/metrics"
+	"github.com/e9ctrl/vd/parameter"
 	"github.com/e9ctrl/vd/protocol"
 	"github.com/e9ctrl/vd/protocol/stream"
 	"github.com/e9ctrl/vd/server"
@@ -21,15 +28,49 @@ var (
 	ErrNoClient = errors.New("no client available")
 	// Error returned by SetMimsatch if new message is too long
 	ErrMismatchTooLong = errors.New("new mismatch message exceeded 255 characters limit")
+	// Error returned by Disconnect when client with given id is not connected
+	ErrClientNotFound = errors.New("client not found")
+	// Error returned when element of the parameter that is not an array is referenced
+	ErrNotArray = errors.New("parameter is not an array")
 )
 
+// State of a single client connection, keeps own copies of session scoped parameters
+type session struct {
+	client *server.Client
+	params map[string]parameter.Parameter
+	// beginning of the request received so far, used by framings other than terminator
+	pending []byte
+	// flushes pending request in idle framing
+	idle *time.Timer
+	m    sync.Mutex
+}
+
+// Description of the parameter exposed via HTTP API
+type ParameterInfo = info.Parameter
+
+// Description of the command exposed via HTTP API
+type CommandInfo = info.Command
+
 // Stream device store the information of a set of parameters
 type StreamDevice struct {
 	server.Handler
-	vdfile    *vdfile.VDFile
-	proto     protocol.Protocol
-	triggered chan []byte
-	lock      sync.RWMutex
+	vdfile      *vdfile.VDFile
+	proto       protocol.Protocol
+	triggered   chan []byte
+	sessions    map[uint64]*session
+	metrics     *metrics.Metrics
+	subscribers map[chan Traffic]struct{}
+	subLock     sync.Mutex
+	history     *history
+	// values of parameters from the vdfile, restored by reset
+	defaults map[string]any
+	// error queue with status register, nil when the device has none
+	errors *errorQueue
+	// enable registers of SCPI profile
+	scpi scpiStatus
+	// signalled when device parameters change
+	changes chan struct{}
+	lock    sync.RWMutex
 }
 
 // Create a new stream device given the virtual device configuration file
@@ -40,13 +81,34 @@ func NewDevice(vdfile *vdfile.VDFile) (*StreamDevice, error) {
 		return nil, err
 	}
 
-	return &StreamDevice{
-		vdfile:    vdfile,
-		triggered: make(chan []byte),
-		proto:     parser,
-	}, nil
+	s := &StreamDevice{
+		vdfile:      vdfile,
+		triggered:   make(chan []byte),
+		proto:       parser,
+		sessions:    make(map[uint64]*session),
+		subscribers: make(map[chan Traffic]struct{}),
+		history:     newHistory(HistorySize),
+		changes:     make(chan struct{}, 1),
+		defaults:    make(map[string]any, len(vdfile.Params)),
+		errors:      newErrorQueue(vdfile.Errors),
+	}
+	for name, param := range vdfile.Params {
+		s.defaults[name] = param.Value()
+	}
+	s.metrics = metrics.New(s.numericParams)
+
+	for name, preset := range vdfile.Presets {
+		if _, _, err := s.validateState(preset); err != nil {
+			return nil, fmt.Errorf("preset %s: %w", name, err)
+		}
+	}
+
+	return s, nil
 }
 
+// Return metrics collected by the device
+func (s *StreamDevice) Metrics() *metrics.Metrics { return s.metrics }
+
 // Return mismatch message together with terminators
 func (s *StreamDevice) Mismatch() (res []byte) {
 	s.lock.Lock()
@@ -56,7 +118,6 @@ func (s *StreamDevice) Mismatch() (res []byte) {
 	if len(mis) != 0 {
 		log.MSM(string(mis))
 		res = append(mis, s.vdfile.OutTerminator...)
-		log.TX(res)
 	}
 	return
 }
@@ -64,20 +125,114 @@ func (s *StreamDevice) Mismatch() (res []byte) {
 // Method that returns channel with value of the parameter
 func (s *StreamDevice) Triggered() chan []byte { return s.triggered }
 
+// Method that fulfills Handler interface, called by TCP server when a new client connects.
+// It creates session with own copies of session scoped parameters.
+func (s *StreamDevice) Connected(client *server.Client) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sess := &session{
+		client: client,
+		params: make(map[string]parameter.Parameter),
+	}
+	for name := range s.vdfile.SessionParams {
+		if param, exists := s.vdfile.Params[name]; exists {
+			sess.params[name] = param.Clone()
+		}
+	}
+	s.sessions[client.ID] = sess
+	log.INF("client connected", log.ClientKey, client.ID, "addr", client.RemoteAddr)
+}
+
+// Method that fulfills Handler interface, called by TCP server when client disconnects.
+// Session state of the client is dropped.
+func (s *StreamDevice) Disconnected(client *server.Client) {
+	s.lock.Lock()
+	if sess, exists := s.sessions[client.ID]; exists {
+		sess.m.Lock()
+		if sess.idle != nil {
+			sess.idle.Stop()
+		}
+		sess.m.Unlock()
+	}
+	delete(s.sessions, client.ID)
+	s.lock.Unlock()
+	log.INF("client disconnected", log.ClientKey, client.ID)
+}
+
+// Return list of connected clients ordered by their id
+func (s *StreamDevice) Clients() []info.Client {
+	s.lock.Lock()
+	clients := make([]info.Client, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		clients = append(clients, info.Client{
+			ID:          sess.client.ID,
+			RemoteAddr:  sess.client.RemoteAddr,
+			ConnectedAt: sess.client.ConnectedAt,
+		})
+	}
+	s.lock.Unlock()
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ID < clients[j].ID
+	})
+	return clients
+}
+
+// Close connection with the client of given id, returns error when client is not connected
+func (s *StreamDevice) Disconnect(id uint64) error {
+	s.lock.Lock()
+	sess, exists := s.sessions[id]
+	s.lock.Unlock()
+	if !exists {
+		return fmt.Errorf("%w: %d", ErrClientNotFound, id)
+	}
+
+	return sess.client.Close()
+}
+
 // Method that fulfills Handler interface that is used by TCP server.
 // It divides bytes into understandable pieces of data and parses it.
-func (s *StreamDevice) Handle(cmd []byte) []byte {
+// Parameters with session scope are read and modified in the session of the given client.
+func (s *StreamDevice) Handle(client *server.Client, cmd []byte) []byte {
 
 	if len(cmd) == 0 {
 		return nil
 	}
 
-	txs, err := s.proto.Decode(cmd)
+	sess := s.session(client)
+	logAttrs := clientAttrs(client)
+	clientID := uint64(0)
+	if client != nil {
+		clientID = client.ID
+	}
+	s.publish(clientID, DirectionRX, "", cmd)
+
+	txs, err := s.decode(sess, cmd)
 	if err != nil {
-		log.ERR(err)
+		log.ERR(err.Error(), logAttrs...)
+		s.metrics.DecodeError()
 		return nil
 	}
 
+	return s.respond(client, sess, txs)
+}
+
+// Process decoded requests and return the reply
+func (s *StreamDevice) respond(client *server.Client, sess *session, txs []protocol.Transaction) []byte {
+	// the rest of the request has not been received yet
+	if len(txs) == 0 {
+		return nil
+	}
+
+	logAttrs := clientAttrs(client)
+	clientID := uint64(0)
+	if client != nil {
+		clientID = client.ID
+	}
+
+	echo := s.echo(client, txs)
+
 	s.lock.Lock()
 	mismatch := s.vdfile.Mismatch
 	s.lock.Unlock()
@@ -87,52 +242,195 @@ func (s *StreamDevice) Handle(cmd []byte) []byte {
 			txs[i].Typ = protocol.TxMismatch
 		}
 
+		var (
+			values map[string]any
+			txErr  error
+		)
+		if tx.CommandName != "" {
+			log.CMD(tx.Typ.String(), append([]any{log.CommandKey, tx.CommandName}, logAttrs...)...)
+			s.metrics.Request(tx.CommandName)
+		} else if reply, isCommon := s.common(sess, tx.Raw, logAttrs); isCommon {
+			txs[i].Typ = protocol.TxReply
+			txs[i].Reply = reply
+		} else {
+			s.reject(metrics.ReasonUnknownCommand)
+			txErr = protocol.ErrCommandNotFound
+		}
+
+		// value of the request could not be parsed by the placeholder of the command
+		if tx.Typ == protocol.TxMismatch && tx.Failure != nil {
+			txErr = fmt.Errorf("%w: %s to %v", protocol.ErrWrongSetVal, tx.Failure.Param, tx.Failure.Value)
+			log.ERR(txErr.Error(), append([]any{log.CommandKey, tx.CommandName}, logAttrs...)...)
+			s.reject(metrics.ReasonInvalidValue)
+		}
+
 		// set the parameter
 		if tx.Typ == protocol.TxSetParam {
+			values = make(map[string]any, len(tx.Payload))
 			for p, v := range tx.Payload {
-				if err := s.SetParameter(p, v); err != nil {
-					log.ERR(err)
+				values[p] = v
+				if err := s.checkAccess(p, true); err != nil {
+					log.ERR(err.Error(), append([]any{log.CommandKey, tx.CommandName}, logAttrs...)...)
+					s.reject(metrics.ReasonAccessDenied)
+					txs[i].Typ = protocol.TxMismatch
+					txs[i].Reply = s.vdfile.AccessErrors[baseName(p)]
+					txErr = err
+					continue
+				}
+				if s.isErrorParam(p) {
+					if err := s.writeErrors(p, v); err != nil {
+						log.ERR(err.Error(), append([]any{log.CommandKey, tx.CommandName}, logAttrs...)...)
+						if errors.Is(err, ErrErrorParamReadOnly) {
+							s.reject(metrics.ReasonAccessDenied)
+						} else {
+							s.reject(metrics.ReasonInvalidValue)
+						}
+						txs[i].Typ = protocol.TxMismatch
+						txErr = err
+					}
+					continue
+				}
+				if err := s.setParameter(sess, p, v); err != nil {
+					log.ERR(err.Error(), append([]any{log.CommandKey, tx.CommandName}, logAttrs...)...)
+					switch {
+					case errors.Is(err, parameter.ErrValOutOfRange):
+						s.reject(metrics.ReasonOutOfRange)
+						txs[i].Reply = s.vdfile.RangeErrors[baseName(p)]
+						txs[i].Failure = &protocol.Failure{Kind: protocol.FailOutOfRange, Param: p, Value: v}
+					case errors.Is(err, parameter.ErrIndexInvalid):
+						s.reject(metrics.ReasonInvalidIndex)
+						txs[i].Reply = s.vdfile.IndexErrors[baseName(p)]
+					default:
+						s.reject(metrics.ReasonInvalidValue)
+						txs[i].Failure = &protocol.Failure{Kind: failureKind(err), Param: p, Value: v}
+					}
 					txs[i].Typ = protocol.TxMismatch
+					txErr = err
+					continue
 				}
+				// keep typed value in the history
+				values[p], _ = s.getParameter(sess, p)
 			}
 		}
 
 		// the following for range code is to ensure the proper type of the parameter value
 		// that needs to be set back to the transaction payload
 		// it is due to fact that proto does not have information about the type of the parameter
+		s.readErrors(txs[i].Payload, tx.Typ == protocol.TxGetParam)
 		for p := range tx.Payload {
-			v, err := s.GetParameter(p)
+			if s.isErrorParam(p) {
+				continue
+			}
+			if tx.Typ == protocol.TxGetParam {
+				if err := s.checkAccess(p, false); err != nil {
+					log.ERR(err.Error(), append([]any{log.CommandKey, tx.CommandName}, logAttrs...)...)
+					s.reject(metrics.ReasonAccessDenied)
+					txs[i].Typ = protocol.TxMismatch
+					txs[i].Reply = s.vdfile.AccessErrors[baseName(p)]
+					txErr = err
+					continue
+				}
+			}
+			v, err := s.getParameter(sess, p)
 			if err != nil {
-				log.ERR(err)
+				log.ERR(err.Error(), append([]any{log.CommandKey, tx.CommandName}, logAttrs...)...)
+				if errors.Is(err, parameter.ErrIndexInvalid) {
+					if tx.Typ == protocol.TxGetParam {
+						s.reject(metrics.ReasonInvalidIndex)
+					}
+					txs[i].Reply = s.vdfile.IndexErrors[baseName(p)]
+				}
 				txs[i].Typ = protocol.TxMismatch
+				txErr = err
 			}
 
 			txs[i].Payload[p] = v
 		}
+		s.record(clientID, txs[i], values, txErr)
 	}
 
-	buf, err := s.proto.Encode(txs)
+	parts, err := s.proto.EncodeParts(txs)
 	if err != nil {
-		log.ERR(err)
+		log.ERR(err.Error(), logAttrs...)
 		return nil
 	}
 
 	//using first command to determine the delay
 	cmdName := txs[0].CommandName
+	var lineDly time.Duration
 	s.lock.Lock()
-	defer s.lock.Unlock()
 	if cmdName != "" && s.vdfile != nil {
 		if cmd, exist := s.vdfile.Commands[cmdName]; exist {
-			s.delayRes(cmd.Dly)
+			s.delayRes(cmd.Dly, append([]any{log.CommandKey, cmdName}, logAttrs...)...)
+			lineDly = cmd.LineDly
+		} else {
+			log.ERR("command not found", append([]any{log.CommandKey, cmdName}, logAttrs...)...)
+		}
+	}
+	s.lock.Unlock()
+
+	// echo that was not written to the client yet goes ahead of the first line of the response
+	if len(echo) > 0 {
+		if len(parts) == 0 {
+			parts = [][]byte{echo}
 		} else {
-			log.ERR("command name %s not found", cmdName)
+			parts[0] = append(echo, parts[0]...)
 		}
 	}
+
+	// lines of multi-line response are sent one by one when there is a delay between them,
+	// the last one is returned as the reply
+	if lineDly > 0 && client != nil && len(parts) > 1 {
+		for _, part := range parts[:len(parts)-1] {
+			s.publish(clientID, DirectionTX, cmdName, part)
+			if _, err := client.Write(part); err != nil {
+				log.ERR("error writing response", append([]any{"err", err}, logAttrs...)...)
+				return nil
+			}
+			s.delayRes(lineDly, append([]any{log.CommandKey, cmdName}, logAttrs...)...)
+		}
+		parts = parts[len(parts)-1:]
+	}
+
+	var buf []byte
+	for _, part := range parts {
+		buf = append(buf, part...)
+	}
+	s.publish(clientID, DirectionTX, cmdName, buf)
 	return buf
 }
 
-// Method to read value of the specified parameter, returns error when parameter not found
+// Method to read value of the specified parameter, returns error when parameter not found.
+// For session scoped parameters it returns the value new sessions start with.
 func (s *StreamDevice) GetParameter(name string) (any, error) {
+	return s.getParameter(nil, name)
+}
+
+// Method to access value of the specified parameter and change it, return error when parameter not found.
+// For session scoped parameters it changes the value new sessions start with.
+func (s *StreamDevice) SetParameter(name string, value any) error {
+	return s.setParameter(nil, name, value)
+}
+
+// Find session of the given client, nil client or unknown client has no session
+func (s *StreamDevice) session(client *server.Client) *session {
+	if client == nil {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.sessions[client.ID]
+}
+
+// Find parameter by name, session copy of the parameter takes precedence over the device one
+func (s *StreamDevice) parameter(sess *session, name string) (parameter.Parameter, error) {
+	if sess != nil {
+		if param, exists := sess.params[name]; exists {
+			return param, nil
+		}
+	}
+
 	s.lock.Lock()
 	param, exists := s.vdfile.Params[name]
 	s.lock.Unlock()
@@ -140,19 +438,102 @@ func (s *StreamDevice) GetParameter(name string) (any, error) {
 		return nil, fmt.Errorf("%w: %s", protocol.ErrParamNotFound, name)
 	}
 
+	return param, nil
+}
+
+// Read value of the parameter or of the array element when name references it, e.g. chan[3]
+func (s *StreamDevice) getParameter(sess *session, name string) (any, error) {
+	name, idx, isElem := splitIndex(name)
+	param, err := s.parameter(sess, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if isElem {
+		arr, err := array(param, name)
+		if err != nil {
+			return nil, err
+		}
+		return arr.Index(idx)
+	}
 	return param.Value(), nil
 }
 
-// Method to access value of the specified parameter and change it, return error when parameter not found
-func (s *StreamDevice) SetParameter(name string, value any) error {
+// Set value of the parameter or of the array element when name references it, e.g. chan[3]
+func (s *StreamDevice) setParameter(sess *session, name string, value any) error {
+	name, idx, isElem := splitIndex(name)
+	param, err := s.parameter(sess, name)
+	if err != nil {
+		return err
+	}
+
+	if isElem {
+		var arr parameter.Indexed
+		if arr, err = array(param, name); err == nil {
+			err = arr.SetIndex(idx, value)
+		}
+	} else {
+		err = param.SetValue(value)
+	}
+	if err != nil {
+		return err
+	}
+	s.notifyChange()
+	return nil
+}
+
+// Return description of all parameters ordered by name
+func (s *StreamDevice) Parameters() []ParameterInfo {
 	s.lock.Lock()
-	param, exists := s.vdfile.Params[name]
-	s.lock.Unlock()
-	if !exists {
-		return fmt.Errorf("%w: %s", protocol.ErrParamNotFound, name)
+	defer s.lock.Unlock()
+
+	params := make([]ParameterInfo, 0, len(s.vdfile.Params))
+	for name, param := range s.vdfile.Params {
+		scope := vdfile.ScopeDevice
+		if s.vdfile.SessionParams[name] {
+			scope = vdfile.ScopeSession
+		}
+		rng := param.Range()
+		params = append(params, ParameterInfo{
+			Name:   name,
+			Typ:    typeName(param),
+			Value:  param.Value(),
+			Opts:   param.Opts(),
+			Scope:  scope,
+			Min:    rng.Min,
+			Max:    rng.Max,
+			Step:   rng.Step,
+			Clamp:  rng.Clamp,
+			Access: s.access(name),
+		})
 	}
 
-	return param.SetValue(value)
+	sort.Slice(params, func(i, j int) bool {
+		return params[i].Name < params[j].Name
+	})
+	return params
+}
+
+// Return description of all commands ordered by name
+func (s *StreamDevice) Commands() []CommandInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	cmds := make([]CommandInfo, 0, len(s.vdfile.Commands))
+	for name, cmd := range s.vdfile.Commands {
+		cmds = append(cmds, CommandInfo{
+			Name:  name,
+			Req:   string(cmd.Req),
+			Res:   string(cmd.Res),
+			Lines: lines(cmd.Lines),
+			Dly:   cmd.Dly.String(),
+		})
+	}
+
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+	return cmds
 }
 
 // Get delay of the specified command, return error when command not found
@@ -201,6 +582,7 @@ func (s *StreamDevice) SetMismatch(value string) error {
 	s.lock.Lock()
 	s.vdfile.Mismatch = []byte(value)
 	s.lock.Unlock()
+	s.proto.SetMismatch([]byte(value))
 	return nil
 }
 
@@ -216,6 +598,10 @@ func (s *StreamDevice) Trigger(cmdName string) error {
 
 	tx := s.proto.Trigger(cmdName)
 	for p := range tx.Payload {
+		// triggered reply goes to TCP clients so it cannot reveal what they cannot read
+		if err := s.checkAccess(p, false); err != nil {
+			return err
+		}
 		v, err := s.GetParameter(p)
 		if err != nil {
 			return err
@@ -234,16 +620,109 @@ func (s *StreamDevice) Trigger(cmdName string) error {
 	default:
 		return ErrNoClient
 	}
+	s.metrics.Trigger(cmdName)
+	s.publish(0, DirectionTX, cmdName, buf)
 
 	return nil
 }
 
 // Method to delay response generation
-func (s *StreamDevice) delayRes(d time.Duration) {
+func (s *StreamDevice) delayRes(d time.Duration, logAttrs ...any) {
 	if d == 0 {
 		return
 	}
 
-	log.DLY("delaying response by", d)
+	log.DLY(d, logAttrs...)
+	s.metrics.Delay(d)
 	time.Sleep(d)
 }
+
+// Convert lines of multi-line response to strings
+func lines(lines [][]byte) []string {
+	var out []string
+	for _, l := range lines {
+		out = append(out, string(l))
+	}
+	return out
+}
+
+// Values of numeric parameters, used by metrics
+func (s *StreamDevice) numericParams() map[string]float64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	values := make(map[string]float64)
+	for name, param := range s.vdfile.Params {
+		rv := reflect.ValueOf(param.Value())
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			values[name] = float64(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			values[name] = float64(rv.Uint())
+		case reflect.Float32, reflect.Float64:
+			values[name] = rv.Float()
+		}
+	}
+	return values
+}
+
+// Fields identifying the client in log messages
+func clientAttrs(client *server.Client) []any {
+	if client == nil {
+		return nil
+	}
+	return []any{log.ClientKey, client.ID}
+}
+
+// Split reference to array element like chan[3] into name of the parameter and index of the element
+func splitIndex(ref string) (name string, idx int, isElem bool) {
+	name, rest, found := strings.Cut(ref, "[")
+	if !found || !strings.HasSuffix(rest, "]") {
+		return ref, 0, false
+	}
+
+	idx, err := strconv.Atoi(strings.TrimSuffix(rest, "]"))
+	if err != nil {
+		return ref, 0, false
+	}
+	return name, idx, true
+}
+
+// Kind of the failure of setting the value that is not out of range
+func failureKind(err error) string {
+	for _, typeErr := range []error{
+		parameter.ErrWrongIntVal,
+		parameter.ErrWrongUintVal,
+		parameter.ErrWrongFloatVal,
+		parameter.ErrWrongBoolVal,
+		parameter.ErrWrongStringVal,
+		parameter.ErrWrongTypeVal,
+	} {
+		if errors.Is(err, typeErr) {
+			return protocol.FailTypeError
+		}
+	}
+	return protocol.FailInvalid
+}
+
+// Name of the parameter without index of the element
+func baseName(ref string) string {
+	name, _, _ := splitIndex(ref)
+	return name
+}
+
+func array(param parameter.Parameter, name string) (parameter.Indexed, error) {
+	arr, ok := param.(parameter.Indexed)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotArray, name)
+	}
+	return arr, nil
+}
+
+// Name of the vdfile type of the parameter
+func typeName(param parameter.Parameter) string {
+	if arr, ok := param.(parameter.Indexed); ok {
+		return arr.Elem().String() + "[]"
+	}
+	return param.Type().String()
+}
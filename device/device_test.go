@@ -11,6 +11,7 @@ import (
 	"github.com/e9ctrl/vd/parameter"
 	"github.com/e9ctrl/vd/protocol"
 	"github.com/e9ctrl/vd/protocol/stream"
+	"github.com/e9ctrl/vd/server"
 	"github.com/e9ctrl/vd/vdfile"
 
 	"testing"
@@ -28,6 +29,40 @@ var myStreamDev = func() *StreamDevice {
 
 var dev = myStreamDev()
 
+// Create device from the vdfile content
+func newTestDevice(t *testing.T, content string) *StreamDevice {
+	t.Helper()
+	vd, err := vdfile.ReadVDFileFromBytes([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDevice(vd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// Request and the reply expected from the device
+type handleTest struct {
+	name string
+	req  string
+	exp  string
+}
+
+// Send requests one after another to the same device and check replies
+func testHandle(t *testing.T, d *StreamDevice, tests []handleTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := d.Handle(nil, []byte(tt.req))
+			if string(res) != tt.exp {
+				t.Errorf("exp resp: %q got: %q", tt.exp, res)
+			}
+		})
+	}
+}
+
 func TestMain(m *testing.M) {
 	params := map[string]parameter.Parameter{}
 	commands := map[string]*command.Command{}
@@ -304,7 +339,7 @@ func TestHandle(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			res := dev.Handle(tt.cmd)
+			res := dev.Handle(nil, tt.cmd)
 			if !bytes.Equal(res, tt.exp) {
 				t.Errorf("%s: exp resp: %[2]s %[2]v got: %[3]s %[3]v\n", tt.name, tt.exp, res)
 			}
@@ -492,6 +527,110 @@ func TestSetCommandDelay(t *testing.T) {
 	}
 }
 
+func TestSessionParameters(t *testing.T) {
+	t.Parallel()
+	vd, err := vdfile.ReadVDFileFromConfig(vdfile.Config{
+		InTerminator:  "CR LF",
+		OutTerminator: "CR LF",
+		Params: []vdfile.ConfigParameter{
+			{Name: "remote", Typ: "string", Val: "LOCAL", Opt: "LOCAL|REMOTE", Scope: vdfile.ScopeSession},
+			{Name: "current", Typ: "int", Val: int64(10)},
+		},
+		Commands: []vdfile.ConfigCommand{
+			{Name: "get_remote", Req: "REM?", Res: "{%s:remote}"},
+			{Name: "set_remote", Req: "REM {%s:remote}", Res: "OK"},
+			{Name: "get_current", Req: "CUR?", Res: "{%d:current}"},
+			{Name: "set_current", Req: "CUR {%d:current}", Res: "OK"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDevice(vd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c1 := &server.Client{ID: 1, RemoteAddr: "127.0.0.1:1000"}
+	c2 := &server.Client{ID: 2, RemoteAddr: "127.0.0.1:2000"}
+	d.Connected(c1)
+	d.Connected(c2)
+
+	tests := []struct {
+		name   string
+		client *server.Client
+		cmd    []byte
+		exp    []byte
+	}{
+		{"set remote first client", c1, []byte("REM REMOTE\r\n"), []byte("OK\r\n")},
+		{"get remote first client", c1, []byte("REM?\r\n"), []byte("REMOTE\r\n")},
+		{"get remote second client", c2, []byte("REM?\r\n"), []byte("LOCAL\r\n")},
+		{"get remote without session", nil, []byte("REM?\r\n"), []byte("LOCAL\r\n")},
+		{"set shared param second client", c2, []byte("CUR 20\r\n"), []byte("OK\r\n")},
+		{"get shared param first client", c1, []byte("CUR?\r\n"), []byte("20\r\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := d.Handle(tt.client, tt.cmd)
+			if !bytes.Equal(res, tt.exp) {
+				t.Errorf("%s: exp resp: %[2]s %[2]v got: %[3]s %[3]v\n", tt.name, tt.exp, res)
+			}
+		})
+	}
+
+	clients := d.Clients()
+	if len(clients) != 2 || clients[0].ID != 1 || clients[1].ID != 2 {
+		t.Fatalf("exp clients with id 1 and 2 got: %v", clients)
+	}
+
+	// session of reconnected client starts with default value
+	d.Disconnected(c1)
+	d.Connected(c1)
+	res := d.Handle(c1, []byte("REM?\r\n"))
+	if !bytes.Equal(res, []byte("LOCAL\r\n")) {
+		t.Errorf("exp resp: LOCAL got: %s", res)
+	}
+
+	if err := d.Disconnect(3); !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("exp err: %v got: %v", ErrClientNotFound, err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+	ch, unsubscribe := dev.Subscribe()
+
+	client := &server.Client{ID: 7}
+	dev.Handle(client, []byte("get ch1 off\r\n"))
+
+	exp := []Traffic{
+		{Client: 7, Direction: DirectionRX, Data: "get ch1 off\r\n", Hex: "67 65 74 20 63 68 31 20 6f 66 66 0d 0a"},
+		{Client: 7, Direction: DirectionTX, Command: "get_offset", Data: "ch1 off 53.4\r\n", Hex: "63 68 31 20 6f 66 66 20 35 33 2e 34 0d 0a"},
+	}
+	for _, e := range exp {
+		// other parallel tests use the same device, skip their traffic
+		for {
+			got := <-ch
+			if got.Client != 7 {
+				continue
+			}
+			got.Time = time.Time{}
+			if got != e {
+				t.Errorf("exp traffic: %+v got: %+v", e, got)
+			}
+			break
+		}
+	}
+
+	unsubscribe()
+	dev.Handle(client, []byte("get ch1 off\r\n"))
+	for len(ch) > 0 {
+		if got := <-ch; got.Client == 7 {
+			t.Errorf("exp no traffic after unsubscribe got: %+v", got)
+		}
+	}
+}
+
 /* Test not to be run in parallel */
 
 func TestMismatch(t *testing.T) {
@@ -550,6 +689,12 @@ func TestSetMismatch(t *testing.T) {
 			if string(got) != tt.expVal {
 				t.Errorf("exp mismatch: %s got: %s", tt.expVal, got)
 			}
+			if tt.expErr == nil && tt.expVal != "" {
+				res := dev.Handle(nil, []byte("unknown\r\n"))
+				if string(res) != tt.expVal+"\r\n" {
+					t.Errorf("exp reply to unknown request: %q got: %q", tt.expVal+"\r\n", res)
+				}
+			}
 		})
 	}
 }
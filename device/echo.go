@@ -0,0 +1,49 @@
+package device
+
+import (
+	"time"
+
+	"github.com/e9ctrl/vd/log"
+	"github.com/e9ctrl/vd/protocol"
+	"github.com/e9ctrl/vd/server"
+)
+
+// Send back received requests of commands with echo. The echo is written to the client
+// right away, before the response is delayed, characters are written one by one when echo_dly is set.
+// Without client the echo is returned to be sent together with the response.
+func (s *StreamDevice) echo(client *server.Client, txs []protocol.Transaction) []byte {
+	s.lock.Lock()
+	var echo []byte
+	for _, tx := range txs {
+		enabled := s.vdfile.Echo
+		if cmd, exists := s.vdfile.Commands[tx.CommandName]; exists {
+			enabled = cmd.Echo
+		}
+		if enabled {
+			echo = append(echo, tx.Raw...)
+			echo = append(echo, tx.Term...)
+		}
+	}
+	dly := s.vdfile.EchoDly
+	s.lock.Unlock()
+
+	if len(echo) == 0 || client == nil {
+		return echo
+	}
+
+	s.publish(client.ID, DirectionTX, "", echo)
+	if dly == 0 {
+		if _, err := client.Write(echo); err != nil {
+			log.ERR("error writing echo", append([]any{"err", err}, clientAttrs(client)...)...)
+		}
+		return nil
+	}
+	for i := range echo {
+		if _, err := client.Write(echo[i : i+1]); err != nil {
+			log.ERR("error writing echo", append([]any{"err", err}, clientAttrs(client)...)...)
+			return nil
+		}
+		time.Sleep(dly)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package device
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/e9ctrl/vd/server"
+)
+
+const echoVDFile = `
+interm = "CR"
+outterm = "CR LF"
+echo = true
+echo_dly = "20ms"
+
+[[parameter]]
+  name = "current"
+  typ = "int"
+  val = 300
+
+[[command]]
+  name = "get_current"
+  req = "CUR?"
+  res = "CUR {%d:current}"
+
+[[command]]
+  name = "set_current"
+  req = "CUR {%d:current}"
+  echo = false
+`
+
+const echoCmdDlyVDFile = `
+interm = "CR"
+outterm = "CR LF"
+echo = true
+
+[[parameter]]
+  name = "current"
+  typ = "int"
+  val = 300
+
+[[command]]
+  name = "get_current"
+  req = "CUR?"
+  res = "CUR {%d:current}"
+  dly = "200ms"
+`
+
+func TestHandleEcho(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, echoVDFile)
+
+	testHandle(t, d, []handleTest{
+		{"echo", "CUR?\r", "CUR?\rCUR 300\r\n"},
+		{"echo without reply", "CUR 20\r", ""},
+		{"echo of mismatch", "TEST?\r", "TEST?\r"},
+		{"echo without terminator", "CUR?", "CUR?CUR 20\r\n"},
+	})
+}
+
+func TestHandleEchoDelay(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, echoVDFile)
+
+	s, err := server.New(d, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	defer s.Stop()
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("CUR?\r")); err != nil {
+		t.Fatal(err)
+	}
+
+	// echoed characters are received one by one before the response
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	start := time.Now()
+	for _, exp := range []string{"C", "U", "R", "?", "\r", "CUR 300\r\n"} {
+		buf := make([]byte, 64)
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != exp {
+			t.Errorf("exp %q got %q", exp, buf[:n])
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("exp characters delayed by 20ms got response after %v", elapsed)
+	}
+}
+
+func TestHandleEchoCommandDelay(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, echoCmdDlyVDFile)
+
+	s, err := server.New(d, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	defer s.Stop()
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	if _, err := conn.Write([]byte("CUR?\r")); err != nil {
+		t.Fatal(err)
+	}
+
+	// echo is not delayed by the command, only the response is
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for _, tt := range []struct {
+		exp     string
+		early   bool
+		elapsed time.Duration
+	}{
+		{"CUR?\r", true, 100 * time.Millisecond},
+		{"CUR 300\r\n", false, 200 * time.Millisecond},
+	} {
+		buf := make([]byte, 64)
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != tt.exp {
+			t.Errorf("exp %q got %q", tt.exp, buf[:n])
+		}
+		elapsed := time.Since(start)
+		if tt.early && elapsed >= tt.elapsed {
+			t.Errorf("exp %q before %v got it after %v", tt.exp, tt.elapsed, elapsed)
+		}
+		if !tt.early && elapsed < tt.elapsed {
+			t.Errorf("exp %q after %v got it after %v", tt.exp, tt.elapsed, elapsed)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/e9ctrl/vd/info"
+	"github.com/e9ctrl/vd/metrics"
+	"github.com/e9ctrl/vd/vdfile"
+)
+
+// Names of placeholders reading and changing the error queue, e.g. res = "ERR {%d:_error},{%s:_error_msg}"
+const (
+	// Code of the oldest error, reading removes it from the queue and writing clears the queue
+	ParamError = "_error"
+	// Message of the error read together with the code
+	ParamErrorMsg = "_error_msg"
+	// Number of errors in the queue
+	ParamErrorCount = "_error_count"
+	// Status register made of status bits of pushed errors, writing sets it
+	ParamStatus = "_status"
+)
+
+var (
+	// Error returned when device has no error queue
+	ErrNoErrorQueue = errors.New("device has no error queue")
+	// Error returned when client writes error queue placeholder that can be only read
+	ErrErrorParamReadOnly = errors.New("error queue placeholder is read only")
+	// Error returned when value written to the status register is not a number
+	ErrWrongStatus = errors.New("status register value is not a number")
+)
+
+// Content of the error queue exposed via HTTP API
+type ErrorQueueInfo = info.ErrorQueue
+
+// Errors reported by the device and its status register
+type errorQueue struct {
+	config *vdfile.ConfigErrors
+	errors []vdfile.DeviceError
+	status int
+	m      sync.Mutex
+}
+
+func newErrorQueue(config *vdfile.ConfigErrors) *errorQueue {
+	if config == nil {
+		return nil
+	}
+	return &errorQueue{config: config}
+}
+
+// Error pushed for the reason of rejecting the request, nil when the reason is not reported
+func (q *errorQueue) forReason(reason string) *vdfile.DeviceError {
+	switch reason {
+	case metrics.ReasonUnknownCommand:
+		return q.config.UnknownCommand
+	case metrics.ReasonInvalidValue:
+		return q.config.InvalidValue
+	case metrics.ReasonOutOfRange:
+		return q.config.OutOfRange
+	case metrics.ReasonAccessDenied:
+		return q.config.AccessDenied
+	case metrics.ReasonInvalidIndex:
+		return q.config.InvalidIndex
+	}
+	return nil
+}
+
+// Add error to the queue, when it is full the last error is replaced with overflow
+func (q *errorQueue) push(e *vdfile.DeviceError) {
+	if e == nil {
+		return
+	}
+
+	q.m.Lock()
+	defer q.m.Unlock()
+	q.status |= e.Status
+	if len(q.errors) < q.config.Size {
+		q.errors = append(q.errors, *e)
+		return
+	}
+	if q.config.Overflow != nil {
+		q.status |= q.config.Overflow.Status
+		q.errors[len(q.errors)-1] = *q.config.Overflow
+	}
+}
+
+// Return the oldest error, it is removed from the queue when remove is set
+func (q *errorQueue) next(remove bool) vdfile.DeviceError {
+	q.m.Lock()
+	defer q.m.Unlock()
+	if len(q.errors) == 0 {
+		return *q.config.NoError
+	}
+	e := q.errors[0]
+	if remove {
+		q.errors = q.errors[1:]
+	}
+	return e
+}
+
+func (q *errorQueue) count() int {
+	q.m.Lock()
+	defer q.m.Unlock()
+	return len(q.errors)
+}
+
+// Remove all errors and clear the status register
+func (q *errorQueue) clear() {
+	q.m.Lock()
+	q.errors = nil
+	q.status = 0
+	q.m.Unlock()
+}
+
+// Return the status register, it is cleared when clear is set
+func (q *errorQueue) readStatus(clear bool) int {
+	q.m.Lock()
+	defer q.m.Unlock()
+	status := q.status
+	if clear {
+		q.status = 0
+	}
+	return status
+}
+
+// Set bits of the status register
+func (q *errorQueue) setStatus(bits int) {
+	q.m.Lock()
+	q.status |= bits
+	q.m.Unlock()
+}
+
+func (q *errorQueue) info() ErrorQueueInfo {
+	q.m.Lock()
+	defer q.m.Unlock()
+	return ErrorQueueInfo{
+		Errors: append([]vdfile.DeviceError{}, q.errors...),
+		Status: q.status,
+	}
+}
+
+// Check if the name is one of the error queue placeholders
+func (s *StreamDevice) isErrorParam(name string) bool {
+	if s.errors == nil {
+		return false
+	}
+	switch name {
+	case ParamError, ParamErrorMsg, ParamErrorCount, ParamStatus:
+		return true
+	}
+	return false
+}
+
+// Fill values of error queue placeholders in the payload, the oldest error
+// is read once for the whole reply and removed from the queue when remove is set
+func (s *StreamDevice) readErrors(payload map[string]any, remove bool) {
+	if s.errors == nil {
+		return
+	}
+	_, code := payload[ParamError]
+	_, msg := payload[ParamErrorMsg]
+	if _, exists := payload[ParamErrorCount]; exists {
+		payload[ParamErrorCount] = s.errors.count()
+	}
+	if _, exists := payload[ParamStatus]; exists {
+		payload[ParamStatus] = s.errors.readStatus(false)
+	}
+	if code || msg {
+		e := s.errors.next(remove)
+		if code {
+			payload[ParamError] = e.Code
+		}
+		if msg {
+			payload[ParamErrorMsg] = e.Msg
+		}
+	}
+}
+
+// Change the error queue with the value received from the client
+func (s *StreamDevice) writeErrors(name string, val any) error {
+	switch name {
+	case ParamError:
+		s.errors.clear()
+	case ParamStatus:
+		status, err := strconv.Atoi(fmt.Sprint(val))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrWrongStatus, val)
+		}
+		s.errors.m.Lock()
+		s.errors.status = status
+		s.errors.m.Unlock()
+	default:
+		return fmt.Errorf("%w: %s", ErrErrorParamReadOnly, name)
+	}
+	return nil
+}
+
+// Push the error of the reason to the error queue, if device has one
+func (s *StreamDevice) pushError(reason string) {
+	if s.errors != nil {
+		s.errors.push(s.errors.forReason(reason))
+	}
+}
+
+// Return errors in the queue and the status register
+func (s *StreamDevice) Errors() (ErrorQueueInfo, error) {
+	if s.errors == nil {
+		return ErrorQueueInfo{}, ErrNoErrorQueue
+	}
+	return s.errors.info(), nil
+}
+
+// Remove all errors from the queue and clear the status register
+func (s *StreamDevice) ClearErrors() error {
+	if s.errors == nil {
+		return ErrNoErrorQueue
+	}
+	s.errors.clear()
+	return nil
+}
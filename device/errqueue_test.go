@@ -0,0 +1,114 @@
+package device
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/e9ctrl/vd/vdfile"
+	"github.com/google/go-cmp/cmp"
+)
+
+const errorsVDFile = `
+interm = "LF"
+outterm = "LF"
+
+[errors]
+  size = 2
+  [errors.unknown_command]
+    code = 1
+    msg = "unknown command"
+    status = 1
+  [errors.out_of_range]
+    code = 2
+    msg = "out of range"
+    status = 2
+  [errors.overflow]
+    code = 9
+    msg = "overflow"
+
+[[parameter]]
+  name = "current"
+  typ = "int"
+  val = 10
+  max = 100
+
+[[command]]
+  name = "set_current"
+  req = "CUR {%d:current}"
+
+[[command]]
+  name = "get_error"
+  req = "ERR?"
+  res = "{%d:_error} {%s:_error_msg}"
+
+[[command]]
+  name = "get_errors"
+  req = "ERRS?"
+  res = "{%d:_error_count} {%d:_status}"
+
+[[command]]
+  name = "set_status"
+  req = "STAT {%d:_status}"
+
+[[command]]
+  name = "clear_errors"
+  req = "CLR {%d:_error}"
+
+[[command]]
+  name = "set_count"
+  req = "CNT {%d:_error_count}"
+`
+
+func TestHandleErrors(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, errorsVDFile)
+
+	// requests are sent one after another to the same device
+	testHandle(t, d, []handleTest{
+		{"empty queue", "ERRS?\n", "0 0\n"},
+		{"no error", "ERR?\n", "0 \n"},
+		{"unknown command", "TEST?\n", ""},
+		{"out of range", "CUR 200\n", ""},
+		{"errors pushed", "ERRS?\n", "2 3\n"},
+		{"overflow", "TEST?\n", ""},
+		{"first error", "ERR?\n", "1 unknown command\n"},
+		{"overflow replaced the last error", "ERR?\n", "9 overflow\n"},
+		{"status kept", "ERRS?\n", "0 3\n"},
+		{"set status", "STAT 0\n", ""},
+		{"read only count", "CNT 0\n", ""},
+		{"status cleared", "ERRS?\n", "0 0\n"},
+		{"clear", "CLR 0\n", ""},
+		{"queue cleared", "ERRS?\n", "0 0\n"},
+	})
+}
+
+func TestErrors(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, errorsVDFile)
+	d.Handle(nil, []byte("TEST?\n"))
+
+	info, err := d.Errors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := ErrorQueueInfo{
+		Errors: []vdfile.DeviceError{{Code: 1, Msg: "unknown command", Status: 1}},
+		Status: 1,
+	}
+	if diff := cmp.Diff(exp, info); diff != "" {
+		t.Errorf("unexpected errors (-want +got):\n%s", diff)
+	}
+
+	if err := d.ClearErrors(); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := d.Errors(); len(info.Errors) != 0 || info.Status != 0 {
+		t.Errorf("exp cleared queue got %+v", info)
+	}
+
+	// device without error queue
+	d = newTestDevice(t, linesVDFile)
+	if _, err := d.Errors(); !errors.Is(err, ErrNoErrorQueue) {
+		t.Errorf("exp %v got %v", ErrNoErrorQueue, err)
+	}
+}
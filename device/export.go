@@ -0,0 +1,180 @@
+package device
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/e9ctrl/vd/parameter"
+	"github.com/e9ctrl/vd/vdfile"
+)
+
+// Return vdfile with current values of parameters, delays and mismatch encoded as TOML
+func (s *StreamDevice) Export() ([]byte, error) {
+	return vdfile.EncodeVDFile(s.Config())
+}
+
+// Return vdfile content with current values of parameters, delays and mismatch.
+// Definitions and order of parameters and commands are kept as in the loaded vdfile.
+func (s *StreamDevice) Config() vdfile.Config {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	config := s.vdfile.Config
+	if len(config.Params) == 0 && len(config.Commands) == 0 {
+		config = s.configFromDefinitions()
+	}
+
+	config.Params = append([]vdfile.ConfigParameter(nil), config.Params...)
+	for i, p := range config.Params {
+		name, _, _, _ := vdfile.ParseFamily(p.Name)
+		if param, exists := s.vdfile.Params[name]; exists {
+			config.Params[i].Val = param.Value()
+		}
+	}
+
+	config.Commands = append([]vdfile.ConfigCommand(nil), config.Commands...)
+	for i, c := range config.Commands {
+		if cmd, exists := s.vdfile.Commands[c.Name]; exists {
+			config.Commands[i].Dly = formatDelay(c.Dly, cmd.Dly)
+		}
+	}
+
+	config.Mismatch = string(s.vdfile.Mismatch)
+	return config
+}
+
+// Build config of device created without one, parameters and commands are ordered by name.
+// It has to be called with the lock held.
+func (s *StreamDevice) configFromDefinitions() vdfile.Config {
+	config := vdfile.Config{
+		InTerminator:  terminatorNames(s.vdfile.InTerminator),
+		OutTerminator: terminatorNames(s.vdfile.OutTerminator),
+		Profile:       s.vdfile.Profile,
+		IDN:           s.vdfile.IDN,
+		Errors:        s.vdfile.Errors,
+		Prompt:        string(s.vdfile.Prompt),
+		Echo:          s.vdfile.Echo,
+		EchoDly:       formatDelay("", s.vdfile.EchoDly),
+		FrameLen:      s.vdfile.FrameLen,
+		FrameGap:      formatDelay("", s.vdfile.FrameGap),
+		Presets:       s.vdfile.Presets,
+	}
+
+	if s.vdfile.Framing != vdfile.FramingTerminator {
+		config.Framing = s.vdfile.Framing
+	}
+	if s.vdfile.FrameRegex != nil {
+		config.FrameRegex = s.vdfile.FrameRegex.String()
+	}
+
+	for name, param := range s.vdfile.Params {
+		p := vdfile.ConfigParameter{
+			Name: name,
+			Typ:  typeName(param),
+			Opt:  strings.Join(param.Opts(), "|"),
+		}
+		if s.vdfile.SessionParams[name] {
+			p.Scope = vdfile.ScopeSession
+		}
+		rng := param.Range()
+		if rng.IntMin != nil {
+			p.Min = *rng.IntMin
+		} else if rng.Min != nil {
+			p.Min = *rng.Min
+		}
+		if rng.IntMax != nil {
+			p.Max = *rng.IntMax
+		} else if rng.Max != nil {
+			p.Max = *rng.Max
+		}
+		if rng.Step != 0 {
+			p.Step = rng.Step
+		}
+		p.Clamp = rng.Clamp
+		p.RangeErr = string(s.vdfile.RangeErrors[name])
+		p.Access = s.vdfile.Access[name]
+		if arr, ok := param.(parameter.Indexed); ok {
+			shape := arr.Shape()
+			if shape.First != 0 {
+				// family has members indexed from the first one
+				p.Name = fmt.Sprintf("%s[%d..%d]", name, shape.First, shape.First+shape.Len-1)
+				p.Typ = arr.Elem().String()
+			} else {
+				p.Len, p.MaxLen = shape.Len, shape.MaxLen
+			}
+			if shape.Sep != parameter.DefaultSeparator {
+				p.Sep = shape.Sep
+			}
+		}
+		p.IndexErr = string(s.vdfile.IndexErrors[name])
+		p.AccessErr = string(s.vdfile.AccessErrors[name])
+		config.Params = append(config.Params, p)
+	}
+	sort.Slice(config.Params, func(i, j int) bool {
+		return config.Params[i].Name < config.Params[j].Name
+	})
+
+	for name, cmd := range s.vdfile.Commands {
+		c := vdfile.ConfigCommand{
+			Name:          name,
+			Req:           string(cmd.Req),
+			LineDly:       formatDelay("", cmd.LineDly),
+			InTerminator:  terminatorNames(cmd.InTerminator),
+			OutTerminator: terminatorNames(cmd.OutTerminator),
+			OnInvalid:     string(cmd.OnInvalid),
+			OnOutOfRange:  string(cmd.OnOutOfRange),
+			OnTypeError:   string(cmd.OnTypeError),
+		}
+		if cmd.Echo != s.vdfile.Echo {
+			echo := cmd.Echo
+			c.Echo = &echo
+		}
+		if len(cmd.Lines) > 0 {
+			c.Res = lines(cmd.Lines)
+		} else if len(cmd.Res) > 0 {
+			c.Res = string(cmd.Res)
+		}
+		config.Commands = append(config.Commands, c)
+	}
+	sort.Slice(config.Commands, func(i, j int) bool {
+		return config.Commands[i].Name < config.Commands[j].Name
+	})
+
+	return config
+}
+
+// Keep delay as it was written when it did not change
+func formatDelay(orig string, d time.Duration) string {
+	if od, err := time.ParseDuration(orig); (err == nil && od == d) || (orig == "" && d == 0) {
+		return orig
+	}
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+var terminatorLookup = map[byte]string{
+	0x00: "NUL", 0x01: "SOH", 0x02: "STX", 0x03: "ETX", 0x04: "EOT",
+	0x05: "ENQ", 0x06: "ACK", 0x07: "BEL", 0x08: "BS", 0x09: "TAB",
+	0x0A: "LF", 0x0B: "VT", 0x0C: "FF", 0x0D: "CR", 0x0E: "SO",
+	0x0F: "SI", 0x10: "DLE", 0x11: "DC1", 0x12: "DC2", 0x13: "DC3",
+	0x14: "DC4", 0x15: "NAK", 0x16: "SYN", 0x17: "ETB", 0x18: "CAN",
+	0x19: "EM", 0x1A: "SUB", 0x1B: "ESC", 0x1C: "FS", 0x1D: "GS",
+	0x1E: "RS", 0x1F: "US", 0x7F: "DEL",
+}
+
+// Inverse of vdfile.ParseTerminator
+func terminatorNames(term []byte) string {
+	names := make([]string, len(term))
+	for i, b := range term {
+		if name, ok := terminatorLookup[b]; ok {
+			names[i] = name
+		} else {
+			names[i] = string(b)
+		}
+	}
+	return strings.Join(names, " ")
+}
@@ -0,0 +1,107 @@
+package device
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/e9ctrl/vd/command"
+	"github.com/e9ctrl/vd/parameter"
+	"github.com/e9ctrl/vd/vdfile"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExportUnchanged(t *testing.T) {
+	t.Parallel()
+	want, err := os.ReadFile("../vdfile/vdfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vd, err := vdfile.ReadVDFileFromBytes(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDevice(vd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := d.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Errorf("unexpected export (-want +got):\n%s", diff)
+	}
+}
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, snapshotVDFile)
+	d.SetParameter("current", "20")
+	d.SetParameter("mode", "SING")
+	d.SetCommandDelay("get_current", "1.5s")
+	d.SetMismatch("ERR")
+
+	config := d.Config()
+	data, err := vdfile.EncodeVDFile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{`interm = "CR LF"`, `mismatch = "ERR"`, `[preset.fault.params]`, `dly = "1.5s"`, `val = 20`, `val = "SING"`, `opt = "NORM|SING"`} {
+		if !strings.Contains(string(data), exp) {
+			t.Errorf("exp %s in export got:\n%s", exp, data)
+		}
+	}
+
+	// exported file recreates the same state
+	vd, err := vdfile.ReadVDFileFromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := NewDevice(vd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(d.Snapshot(), d2.Snapshot()); diff != "" {
+		t.Errorf("exported state differs (-want +got):\n%s", diff)
+	}
+
+	// delays reset to zero are removed
+	d.SetCommandDelay("get_current", "0s")
+	if dly := d.Config().Commands[0].Dly; dly != "" {
+		t.Errorf("exp empty delay got %s", dly)
+	}
+}
+
+func TestExportWithoutConfig(t *testing.T) {
+	t.Parallel()
+	current, _ := parameter.New(int16(5), "", "int16")
+	mode, _ := parameter.New("A", "A|B", "string")
+	vd := &vdfile.VDFile{
+		InTerminator:  []byte("\r\n"),
+		OutTerminator: []byte{0x03},
+		Params:        map[string]parameter.Parameter{"mode": mode, "current": current},
+		SessionParams: map[string]bool{"mode": true},
+		Commands: map[string]*command.Command{
+			"get_current": {Name: "get_current", Req: []byte("CUR?"), Res: []byte("CUR {%d:current}")},
+		},
+	}
+	d, err := NewDevice(vd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := vdfile.Config{
+		InTerminator:  "CR LF",
+		OutTerminator: "ETX",
+		Params: []vdfile.ConfigParameter{
+			{Name: "current", Typ: "int16", Val: int16(5)},
+			{Name: "mode", Typ: "string", Val: "A", Opt: "A|B", Scope: "session"},
+		},
+		Commands: []vdfile.ConfigCommand{{Name: "get_current", Req: "CUR?", Res: "CUR {%d:current}"}},
+	}
+	if diff := cmp.Diff(exp, d.Config()); diff != "" {
+		t.Errorf("unexpected export (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,65 @@
+package device
+
+import (
+	"testing"
+)
+
+const failureVDFile = `
+interm = "LF"
+outterm = "LF"
+mismatch = "ERR"
+
+[[parameter]]
+  name = "mode"
+  typ = "string"
+  val = "NORM"
+  opt = "NORM|SING"
+
+[[parameter]]
+  name = "current"
+  typ = "int8"
+  val = 10
+  min = 0
+  max = 100
+  range_err = "E02 OUT OF RANGE"
+
+[[command]]
+  name = "set_mode"
+  req = "MODE {%s:mode}"
+  res = "MODE {%s:mode}"
+  on_invalid = "E01 {%s:_param} CANNOT BE {%s:_value}, STILL {%s:mode}"
+
+[[command]]
+  name = "set_current"
+  req = "CUR {%d:current}"
+  res = "CUR {%d:current}"
+  on_out_of_range = "E02 {%s:_value} OUTSIDE 0..100"
+  on_type_error = "E03 {%s:_value} IS NOT A NUMBER, STILL {%d:current}"
+
+[[command]]
+  name = "set_limit"
+  req = "LIM {%d:current}mA"
+  on_type_error = "E03 LIMIT {%s:_value}"
+
+[[command]]
+  name = "set_current_hex"
+  req = "HCUR {%x:current}"
+  res = "HCUR {%x:current}"
+`
+
+func TestHandleFailure(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, failureVDFile)
+
+	testHandle(t, d, []handleTest{
+		{"valid option", "MODE SING\n", "MODE SING\n"},
+		{"invalid option", "MODE AUTO\n", "E01 mode CANNOT BE AUTO, STILL SING\n"},
+		{"out of range", "CUR 120\n", "E02 120 OUTSIDE 0..100\n"},
+		{"out of type range", "CUR 300\n", "E02 300 OUTSIDE 0..100\n"},
+		{"type error", "CUR high\n", "E03 high IS NOT A NUMBER, STILL 10\n"},
+		{"type error before literal", "LIM highmA\n", "E03 LIMIT high\n"},
+		{"unknown command", "VOLT high\n", "ERR\n"},
+		{"range error of parameter without template", "HCUR ff\n", "E02 OUT OF RANGE\n"},
+		{"valid current", "CUR 20\n", "CUR 20\n"},
+	})
+}
@@ -0,0 +1,74 @@
+package device
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/e9ctrl/vd/vdfile"
+	"github.com/google/go-cmp/cmp"
+)
+
+const familyVDFile = `
+interm = "LF"
+outterm = "LF"
+mismatch = "ERR"
+
+[[parameter]]
+  name = "voltage[1..4]"
+  typ = "float"
+  val = 0.0
+  max = 1000
+  index_err = "E07 NO SUCH CHANNEL"
+
+[[command]]
+  name = "set_voltage"
+  req = "VSET {%d:#ch},{%.1f:voltage[#ch]}"
+  res = "OK"
+
+[[command]]
+  name = "get_voltage"
+  req = "VMON? {%d:#ch}"
+  res = "VMON {%d:#ch},{%.1f:voltage[#ch]}"
+
+[[command]]
+  name = "get_all"
+  req = "VMON?"
+  res = "{%.1f:voltage}"
+`
+
+func TestHandleFamily(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, familyVDFile)
+
+	testHandle(t, d, []handleTest{
+		{"set first channel", "VSET 1,120.5\n", "OK\n"},
+		{"set last channel", "VSET 4,3.0\n", "OK\n"},
+		{"get channel", "VMON? 1\n", "VMON 1,120.5\n"},
+		{"get other channel", "VMON? 2\n", "VMON 2,0.0\n"},
+		{"get all channels", "VMON?\n", "120.5,0.0,0.0,3.0\n"},
+		{"set channel outside family", "VSET 5,1.0\n", "E07 NO SUCH CHANNEL\n"},
+		{"get channel outside family", "VMON? 0\n", "E07 NO SUCH CHANNEL\n"},
+		{"value out of range", "VSET 2,2000.0\n", "ERR\n"},
+	})
+
+	if v, err := d.GetParameter("voltage[4]"); err != nil || v != 3.0 {
+		t.Errorf("exp voltage[4] 3.0 got %v %v", v, err)
+	}
+
+	history := d.History(HistoryFilter{Command: "set_voltage", Limit: 1})
+	if diff := cmp.Diff(map[string]any{"voltage[2]": "2000.0"}, history[0].Values); diff != "" {
+		t.Errorf("unexpected history values (-want +got):\n%s", diff)
+	}
+
+	// export keeps family declaration
+	data, err := d.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "name = \"voltage[1..4]\"\n  typ = \"float\"\n  val = [120.5, 0.0, 0.0, 3.0]") {
+		t.Errorf("unexpected export:\n%s", data)
+	}
+	if _, err := vdfile.ReadVDFileFromBytes(data); err != nil {
+		t.Errorf("exported vdfile cannot be read: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package device
+
+import (
+	"time"
+
+	"github.com/e9ctrl/vd/log"
+	"github.com/e9ctrl/vd/protocol"
+	"github.com/e9ctrl/vd/vdfile"
+)
+
+// Max length of incomplete request kept between reads
+const PENDING_LIMIT = 64 * 1024
+
+// Cut received data into requests. Incomplete request is kept in the session until
+// the rest of it is received, requests without session are always complete.
+func (s *StreamDevice) decode(sess *session, data []byte) ([]protocol.Transaction, error) {
+	if sess == nil || s.vdfile.Framing == "" || s.vdfile.Framing == vdfile.FramingTerminator {
+		return s.proto.Decode(data)
+	}
+
+	sess.m.Lock()
+	defer sess.m.Unlock()
+
+	txs, rest, err := s.proto.DecodeFrames(append(sess.pending, data...), false)
+	if len(rest) > PENDING_LIMIT {
+		log.ERR("incomplete request too long - dropping", "len", len(rest), log.ClientKey, sess.client.ID)
+		rest = nil
+	}
+	sess.pending = rest
+
+	// request ends when nothing more is received for the gap
+	if s.vdfile.Framing == vdfile.FramingIdle && len(rest) > 0 {
+		if sess.idle != nil {
+			sess.idle.Stop()
+		}
+		sess.idle = time.AfterFunc(s.vdfile.FrameGap, func() { s.flush(sess) })
+	}
+	return txs, err
+}
+
+// Process pending request of idle framing and send the reply to the client
+func (s *StreamDevice) flush(sess *session) {
+	sess.m.Lock()
+	data := sess.pending
+	sess.pending = nil
+	sess.m.Unlock()
+	if len(data) == 0 {
+		return
+	}
+
+	txs, _, err := s.proto.DecodeFrames(data, true)
+	if err != nil {
+		log.ERR(err.Error(), clientAttrs(sess.client)...)
+		s.metrics.DecodeError()
+		return
+	}
+
+	if buf := s.respond(sess.client, sess, txs); len(buf) > 0 {
+		if _, err := sess.client.Write(buf); err != nil {
+			log.ERR("error writing response", append([]any{"err", err}, clientAttrs(sess.client)...)...)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package device
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/e9ctrl/vd/server"
+)
+
+const framingVDFile = `
+outterm = "LF"
+%s
+
+[[parameter]]
+  name = "current"
+  typ = "int"
+  val = 300
+
+[[command]]
+  name = "get_current"
+  req = "CUR?"
+  res = "CUR {%%d:current}"
+
+[[command]]
+  name = "set_current"
+  req = "CUR{%%03d:current}"
+  res = "OK"
+`
+
+func TestHandleFraming(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		framing string
+		writes  []string
+		exp     string
+	}{
+		{"fixed frames split between reads", `framing = "fixed"` + "\nframe_len = 6", []string{"CUR0", "20CUR?"}, "OK\n"},
+		{"idle gap", `framing = "idle"` + "\nframe_gap = \"50ms\"", []string{"CU", "R?"}, "CUR 300\n"},
+		{"regex", `framing = "regex"` + "\nframe_regex = '<(.*?)>'", []string{"<CUR", "?>"}, "CUR 300\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDevice(t, fmt.Sprintf(framingVDFile, tt.framing))
+			s, err := server.New(d, "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.Start()
+			defer s.Stop()
+
+			conn, err := net.Dial("tcp", s.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			for _, w := range tt.writes {
+				if _, err := conn.Write([]byte(w)); err != nil {
+					t.Fatal(err)
+				}
+				// parts are received in separate reads
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			buf := make([]byte, 64)
+			n, err := conn.Read(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(buf[:n]) != tt.exp {
+				t.Errorf("exp %q got %q", tt.exp, buf[:n])
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package device
+
+import (
+	"sync"
+	"time"
+
+	"github.com/e9ctrl/vd/info"
+	"github.com/e9ctrl/vd/log"
+	"github.com/e9ctrl/vd/protocol"
+)
+
+// Number of requests kept in the history, the oldest ones are dropped first
+const HistorySize = 1000
+
+// Request decoded by the device
+type HistoryEntry = info.HistoryEntry
+
+// Criteria of history entries, zero values match all
+type HistoryFilter = info.HistoryFilter
+
+// Bounded journal of requests
+type history struct {
+	lock    sync.Mutex
+	entries []HistoryEntry
+	// index of the oldest entry once the journal is full
+	start int
+}
+
+func newHistory(size int) *history {
+	return &history{entries: make([]HistoryEntry, 0, size)}
+}
+
+func (h *history) add(e HistoryEntry) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if len(h.entries) < cap(h.entries) {
+		h.entries = append(h.entries, e)
+		return
+	}
+	h.entries[h.start] = e
+	h.start = (h.start + 1) % len(h.entries)
+}
+
+func (h *history) list(f HistoryFilter) []HistoryEntry {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	res := make([]HistoryEntry, 0)
+	for i := range h.entries {
+		e := h.entries[(h.start+i)%len(h.entries)]
+		if f.Match(e) {
+			res = append(res, e)
+		}
+	}
+	if f.Limit > 0 && len(res) > f.Limit {
+		res = res[len(res)-f.Limit:]
+	}
+	return res
+}
+
+func (h *history) clear() {
+	h.lock.Lock()
+	h.entries = h.entries[:0]
+	h.start = 0
+	h.lock.Unlock()
+}
+
+// Record transaction decoded from the client request
+func (s *StreamDevice) record(clientID uint64, tx protocol.Transaction, values map[string]any, err error) {
+	e := HistoryEntry{
+		Time:    time.Now(),
+		Client:  clientID,
+		Raw:     string(tx.Raw),
+		Hex:     log.Hex(tx.Raw),
+		Command: tx.CommandName,
+		Typ:     tx.Typ.String(),
+		Values:  values,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	s.history.add(e)
+}
+
+// Requests received by the device in order of arrival
+func (s *StreamDevice) History(f HistoryFilter) []HistoryEntry {
+	return s.history.list(f)
+}
+
+// Remove all requests from the history
+func (s *StreamDevice) ClearHistory() {
+	s.history.clear()
+}
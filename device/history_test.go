@@ -0,0 +1,114 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/e9ctrl/vd/server"
+	"github.com/e9ctrl/vd/vdfile"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestHistoryBounded(t *testing.T) {
+	t.Parallel()
+	h := newHistory(3)
+	base := time.Now()
+	for i := 0; i < 5; i++ {
+		h.add(HistoryEntry{Time: base.Add(time.Duration(i) * time.Second), Raw: string(rune('a' + i)), Command: []string{"get", "set"}[i%2]})
+	}
+
+	tests := []struct {
+		name   string
+		filter HistoryFilter
+		exp    []string
+	}{
+		{"all entries", HistoryFilter{}, []string{"c", "d", "e"}},
+		{"command", HistoryFilter{Command: "get"}, []string{"c", "e"}},
+		{"since", HistoryFilter{Since: base.Add(3 * time.Second)}, []string{"d", "e"}},
+		{"limit", HistoryFilter{Limit: 2}, []string{"d", "e"}},
+		{"limit over size", HistoryFilter{Limit: 10}, []string{"c", "d", "e"}},
+		{"nothing matches", HistoryFilter{Command: "trigger"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, e := range h.list(tt.filter) {
+				got = append(got, e.Raw)
+			}
+			if diff := cmp.Diff(tt.exp, got); diff != "" {
+				t.Errorf("unexpected entries (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+	vd, err := vdfile.ReadVDFileFromBytes([]byte(`
+interm = "CR LF"
+outterm = "CR LF"
+
+[[parameter]]
+  name = "current"
+  typ = "int"
+  val = 300
+
+[[parameter]]
+  name = "output"
+  typ = "string"
+  val = "ON"
+  opt = "ON|OFF"
+
+[[command]]
+  name = "get_current"
+  req = "CUR?"
+  res = "CUR {%d:current}"
+
+[[command]]
+  name = "set_current"
+  req = "CUR {%d:current}"
+  res = "OK"
+
+[[command]]
+  name = "set_output"
+  req = "OUTP {%s:output}"
+  res = "OK"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDevice(vd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &server.Client{ID: 3}
+	d.Handle(client, []byte("OUTP OFF\r\nCUR 0\r\n"))
+	d.Handle(client, []byte("CUR?\r\n"))
+	d.Handle(nil, []byte("VOLT?\r\n"))
+	d.Handle(nil, []byte("OUTP BAD\r\n"))
+
+	exp := []HistoryEntry{
+		{Client: 3, Raw: "OUTP OFF", Hex: "4f 55 54 50 20 4f 46 46", Command: "set_output", Typ: "SetParam", Values: map[string]any{"output": "OFF"}},
+		{Client: 3, Raw: "CUR 0", Hex: "43 55 52 20 30", Command: "set_current", Typ: "SetParam", Values: map[string]any{"current": int64(0)}},
+		{Client: 3, Raw: "CUR?", Hex: "43 55 52 3f", Command: "get_current", Typ: "GetParam"},
+		{Raw: "VOLT?", Hex: "56 4f 4c 54 3f", Typ: "Unknown", Error: "command not found"},
+		{Raw: "OUTP BAD", Hex: "4f 55 54 50 20 42 41 44", Command: "set_output", Typ: "Mismatch", Values: map[string]any{"output": "BAD"}, Error: "value outside opts - ignoring set"},
+	}
+	got := d.History(HistoryFilter{})
+	if diff := cmp.Diff(exp, got, cmpopts.IgnoreFields(HistoryEntry{}, "Time")); diff != "" {
+		t.Errorf("unexpected history (-want +got):\n%s", diff)
+	}
+
+	got = d.History(HistoryFilter{Command: "set_current"})
+	if len(got) != 1 || got[0].Raw != "CUR 0" {
+		t.Errorf("exp only set_current request got %+v", got)
+	}
+
+	d.ClearHistory()
+	if got := d.History(HistoryFilter{}); len(got) != 0 {
+		t.Errorf("exp empty history after clear got %+v", got)
+	}
+}
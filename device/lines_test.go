@@ -0,0 +1,93 @@
+package device
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/e9ctrl/vd/server"
+)
+
+const linesVDFile = `
+interm = "LF"
+outterm = "CR LF"
+prompt = "> "
+
+[[parameter]]
+  name = "current"
+  typ = "int"
+  val = 300
+
+[[command]]
+  name = "help"
+  req = "*HELP?"
+  res = ["CUR? - read current", "", "CUR val - set current"]
+
+[[command]]
+  name = "dump"
+  req = "DUMP?"
+  res = ["BEGIN", "CUR {%d:current}", "END"]
+  line_dly = "100ms"
+
+[[command]]
+  name = "get_current"
+  req = "CUR?"
+  res = "CUR {%d:current}"
+  outterm = "LF"
+
+[[command]]
+  name = "set_current"
+  req = "CUR {%d:current}"
+`
+
+func TestHandleLines(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, linesVDFile)
+
+	testHandle(t, d, []handleTest{
+		{"lines", "*HELP?\n", "CUR? - read current\r\n\r\nCUR val - set current\r\n> "},
+		{"lines with values", "DUMP?\n", "BEGIN\r\nCUR 300\r\nEND\r\n> "},
+		{"command terminator", "CUR?\n", "CUR 300\n> "},
+		{"prompt without reply", "CUR 20\n", "> "},
+		{"prompt after mismatch", "TEST?\n", "> "},
+	})
+}
+
+func TestHandleLinesDelay(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, linesVDFile)
+
+	s, err := server.New(d, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	defer s.Stop()
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("DUMP?\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// every line is received separately after the delay
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	start := time.Now()
+	for _, exp := range []string{"BEGIN\r\n", "CUR 300\r\n", "END\r\n> "} {
+		buf := make([]byte, 64)
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != exp {
+			t.Errorf("exp line %q got %q", exp, buf[:n])
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("exp lines delayed by 100ms got response after %v", elapsed)
+	}
+}
@@ -0,0 +1,73 @@
+package device
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/e9ctrl/vd/parameter"
+)
+
+const rangeVDFile = `
+interm = "LF"
+outterm = "LF"
+mismatch = "ERR"
+
+[[parameter]]
+  name = "current"
+  typ = "int"
+  val = 10
+  min = 0
+  max = 300
+  range_err = "E02 OUT OF RANGE"
+
+[[parameter]]
+  name = "voltage"
+  typ = "float"
+  val = 1.0
+  min = 0
+  max = 12.5
+  step = 0.1
+  clamp = true
+
+[[parameter]]
+  name = "power"
+  typ = "int"
+  val = 0
+  max = 100
+
+[[command]]
+  name = "set_current"
+  req = "CUR {%d:current}"
+  res = "CUR {%d:current}"
+
+[[command]]
+  name = "set_voltage"
+  req = "VOLT {%.2f:voltage}"
+  res = "VOLT {%.1f:voltage}"
+
+[[command]]
+  name = "set_power"
+  req = "POW {%d:power}"
+  res = "POW {%d:power}"
+`
+
+func TestHandleRange(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, rangeVDFile)
+
+	testHandle(t, d, []handleTest{
+		{"current in range", "CUR 120\n", "CUR 120\n"},
+		{"current out of range", "CUR 301\n", "E02 OUT OF RANGE\n"},
+		{"voltage rounded", "VOLT 3.34\n", "VOLT 3.3\n"},
+		{"voltage clamped", "VOLT 20.00\n", "VOLT 12.5\n"},
+		{"power out of range without reply", "POW 120\n", "ERR\n"},
+	})
+
+	if v, _ := d.GetParameter("current"); v != int64(120) {
+		t.Errorf("exp current 120 got %v", v)
+	}
+
+	if err := d.SetParameter("current", "-1"); !errors.Is(err, parameter.ErrValOutOfRange) {
+		t.Errorf("exp error %v got %v", parameter.ErrValOutOfRange, err)
+	}
+}
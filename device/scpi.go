@@ -0,0 +1,175 @@
+package device
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/e9ctrl/vd/log"
+	"github.com/e9ctrl/vd/parameter"
+	"github.com/e9ctrl/vd/protocol/stream"
+	"github.com/e9ctrl/vd/vdfile"
+)
+
+// Bits of the standard event status register, errors of SCPI profile set the other ones
+const esrOperationComplete = 0x01
+
+// Bits of the status byte
+const (
+	stbErrorAvailable = 0x04
+	stbEventSummary   = 0x20
+	stbRequestService = 0x40
+)
+
+// Enable registers of SCPI device, the event status register is the status register of the error queue
+type scpiStatus struct {
+	ese byte
+	sre byte
+	m   sync.Mutex
+}
+
+// Common command of SCPI devices, it returns reply and error that should be pushed to the queue
+type commonCommand struct {
+	header string
+	handle func(s *StreamDevice, sess *session, arg string) ([]byte, *vdfile.DeviceError)
+}
+
+var commonCommands = []commonCommand{
+	{"*IDN?", func(s *StreamDevice, _ *session, _ string) ([]byte, *vdfile.DeviceError) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		return []byte(s.vdfile.IDN), nil
+	}},
+	{"*RST", func(s *StreamDevice, sess *session, _ string) ([]byte, *vdfile.DeviceError) {
+		s.reset(sess)
+		return nil, nil
+	}},
+	{"*CLS", func(s *StreamDevice, _ *session, _ string) ([]byte, *vdfile.DeviceError) {
+		s.errors.clear()
+		return nil, nil
+	}},
+	{"*OPC?", func(*StreamDevice, *session, string) ([]byte, *vdfile.DeviceError) {
+		return []byte("1"), nil
+	}},
+	{"*OPC", func(s *StreamDevice, _ *session, _ string) ([]byte, *vdfile.DeviceError) {
+		s.errors.setStatus(esrOperationComplete)
+		return nil, nil
+	}},
+	{"*WAI", func(*StreamDevice, *session, string) ([]byte, *vdfile.DeviceError) {
+		return nil, nil
+	}},
+	{"*TST?", func(*StreamDevice, *session, string) ([]byte, *vdfile.DeviceError) {
+		return []byte("0"), nil
+	}},
+	{"*ESR?", func(s *StreamDevice, _ *session, _ string) ([]byte, *vdfile.DeviceError) {
+		return []byte(strconv.Itoa(s.errors.readStatus(true))), nil
+	}},
+	{"*ESE?", func(s *StreamDevice, _ *session, _ string) ([]byte, *vdfile.DeviceError) {
+		s.scpi.m.Lock()
+		defer s.scpi.m.Unlock()
+		return []byte(strconv.Itoa(int(s.scpi.ese))), nil
+	}},
+	{"*ESE", func(s *StreamDevice, _ *session, arg string) ([]byte, *vdfile.DeviceError) {
+		return nil, s.setRegister(&s.scpi.ese, arg)
+	}},
+	{"*SRE?", func(s *StreamDevice, _ *session, _ string) ([]byte, *vdfile.DeviceError) {
+		s.scpi.m.Lock()
+		defer s.scpi.m.Unlock()
+		return []byte(strconv.Itoa(int(s.scpi.sre))), nil
+	}},
+	{"*SRE", func(s *StreamDevice, _ *session, arg string) ([]byte, *vdfile.DeviceError) {
+		return nil, s.setRegister(&s.scpi.sre, arg)
+	}},
+	{"*STB?", func(s *StreamDevice, _ *session, _ string) ([]byte, *vdfile.DeviceError) {
+		return []byte(strconv.Itoa(int(s.stb()))), nil
+	}},
+	{"SYSTem:ERRor?", systemError},
+	{"SYSTem:ERRor:NEXT?", systemError},
+	{"SYSTem:ERRor:COUNt?", func(s *StreamDevice, _ *session, _ string) ([]byte, *vdfile.DeviceError) {
+		return []byte(strconv.Itoa(s.errors.count())), nil
+	}},
+}
+
+func systemError(s *StreamDevice, _ *session, _ string) ([]byte, *vdfile.DeviceError) {
+	e := s.errors.next(true)
+	return []byte(strconv.Itoa(e.Code) + "," + strconv.Quote(e.Msg)), nil
+}
+
+// Set enable register to the value from 0 to 255
+func (s *StreamDevice) setRegister(reg *byte, arg string) *vdfile.DeviceError {
+	v, err := strconv.ParseUint(arg, 10, 8)
+	if err != nil {
+		return s.errors.config.InvalidValue
+	}
+	s.scpi.m.Lock()
+	*reg = byte(v)
+	s.scpi.m.Unlock()
+	return nil
+}
+
+// Status byte summarizing the error queue and event status register
+func (s *StreamDevice) stb() byte {
+	var stb byte
+	if s.errors.count() > 0 {
+		stb |= stbErrorAvailable
+	}
+	esr := s.errors.readStatus(false)
+
+	s.scpi.m.Lock()
+	defer s.scpi.m.Unlock()
+	if byte(esr)&s.scpi.ese != 0 {
+		stb |= stbEventSummary
+	}
+	if stb&s.scpi.sre != 0 {
+		stb |= stbRequestService
+	}
+	return stb
+}
+
+// Handle common command of the SCPI profile, it returns false when the request is not one of them
+func (s *StreamDevice) common(sess *session, req []byte, logAttrs []any) ([]byte, bool) {
+	s.lock.Lock()
+	profile := s.vdfile.Profile
+	s.lock.Unlock()
+	if profile != vdfile.ProfileSCPI {
+		return nil, false
+	}
+
+	header, arg, _ := strings.Cut(strings.TrimSpace(string(req)), " ")
+	for _, cmd := range commonCommands {
+		if !stream.MatchHeader(header, cmd.header) {
+			continue
+		}
+		log.CMD(cmd.header, logAttrs...)
+		s.metrics.Request(cmd.header)
+		res, e := cmd.handle(s, sess, strings.TrimSpace(arg))
+		if e != nil {
+			log.ERR(fmt.Sprintf("%s: %s", e.Msg, arg), append([]any{log.CommandKey, cmd.header}, logAttrs...)...)
+			s.errors.push(e)
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+// Count rejected request and push the error of the reason to the error queue
+func (s *StreamDevice) reject(reason string) {
+	s.metrics.Mismatch(reason)
+	s.pushError(reason)
+}
+
+// Set all parameters to values from the vdfile, session copies of the client are reset too
+func (s *StreamDevice) reset(sess *session) {
+	if err := s.Restore(vdfile.State{Params: s.defaults}); err != nil {
+		log.ERR("reset failed", "err", err)
+	}
+	if sess == nil {
+		return
+	}
+	for name, param := range sess.params {
+		if err := parameter.SetDecoded(param, s.defaults[name]); err != nil {
+			log.ERR("reset failed", "param", name, "err", err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package device
+
+import (
+	"testing"
+)
+
+const scpiVDFile = `
+profile = "scpi"
+idn = "ACME,PSU-1,1234,1.0"
+
+[[parameter]]
+  name = "voltage"
+  typ = "float64"
+  val = 1.5
+  min = 0
+  max = 30
+
+[[command]]
+  name = "get_voltage"
+  req = "SOURce:VOLTage?"
+  res = "{%.1f:voltage}"
+
+[[command]]
+  name = "set_voltage"
+  req = "SOURce:VOLTage {%f:voltage}"
+`
+
+func TestHandleSCPI(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, scpiVDFile)
+
+	// requests are sent one after another to the same device
+	testHandle(t, d, []handleTest{
+		{"identification", "*IDN?\n", "ACME,PSU-1,1234,1.0\n"},
+		{"long form", "source:voltage?\n", "1.5\n"},
+		{"no errors", "SYST:ERR?\n", "0,\"No error\"\n"},
+		{"undefined header", "SOUR:FREQ?\n", ""},
+		{"out of range", "SOUR:VOLT 50\n", ""},
+		{"errors available", "*STB?\n", "4\n"},
+		{"error count", "SYST:ERR:COUN?\n", "2\n"},
+		{"first error", "SYSTem:ERRor?\n", "-113,\"Undefined header\"\n"},
+		{"second error", "syst:err:next?\n", "-222,\"Data out of range\"\n"},
+		{"command and execution errors", "*ESR?\n", "48\n"},
+		{"event status cleared", "*ESR?\n", "0\n"},
+		{"compound with relative path", "SOUR:VOLT 12;VOLT?;*OPC?\n", "12.0;1\n"},
+		{"reset", "*RST;:SOUR:VOLT?\n", "1.5\n"},
+		{"event status enable", "*ESE 16;*ESE?\n", "16\n"},
+		{"illegal register value", "*SRE 300\n", ""},
+		{"event summary", "*STB?\n", "36\n"},
+		{"clear status", "*CLS;*STB?;SYST:ERR?\n", "0;0,\"No error\"\n"},
+	})
+}
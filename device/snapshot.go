@@ -0,0 +1,126 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/e9ctrl/vd/parameter"
+	"github.com/e9ctrl/vd/protocol"
+	"github.com/e9ctrl/vd/vdfile"
+)
+
+var ErrPresetNotFound = errors.New("preset not found")
+
+// Return current values of all parameters, delays of all commands and mismatch.
+// For session scoped parameters the value new sessions start with is returned.
+func (s *StreamDevice) Snapshot() vdfile.State {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	state := vdfile.State{
+		Params: make(map[string]any, len(s.vdfile.Params)),
+		Delays: make(map[string]string, len(s.vdfile.Commands)),
+	}
+	for name, param := range s.vdfile.Params {
+		state.Params[name] = param.Value()
+	}
+	for name, cmd := range s.vdfile.Commands {
+		state.Delays[name] = cmd.Dly.String()
+	}
+	mismatch := string(s.vdfile.Mismatch)
+	state.Mismatch = &mismatch
+
+	return state
+}
+
+// Restore the state, nothing is changed when any of the values is invalid
+func (s *StreamDevice) Restore(state vdfile.State) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	params, delays, err := s.validateState(state)
+	if err != nil {
+		return err
+	}
+
+	for name, param := range params {
+		// validated on a copy, setting the same value cannot fail
+		_ = s.vdfile.Params[name].SetValue(param.Value())
+	}
+	for name, d := range delays {
+		s.vdfile.Commands[name].Dly = d
+	}
+	if state.Mismatch != nil {
+		s.vdfile.Mismatch = []byte(*state.Mismatch)
+		s.proto.SetMismatch([]byte(*state.Mismatch))
+	}
+	if len(params) > 0 {
+		s.notifyChange()
+	}
+
+	return nil
+}
+
+// Names of presets defined in the vdfile in alphabetical order
+func (s *StreamDevice) Presets() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	names := make([]string, 0, len(s.vdfile.Presets))
+	for name := range s.vdfile.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Restore state of the preset defined in the vdfile
+func (s *StreamDevice) ApplyPreset(name string) error {
+	s.lock.Lock()
+	preset, exists := s.vdfile.Presets[name]
+	s.lock.Unlock()
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrPresetNotFound, name)
+	}
+
+	return s.Restore(preset)
+}
+
+// Check that all values of the state can be applied, it returns parameter copies with new values
+// and parsed delays. It has to be called with the lock held.
+func (s *StreamDevice) validateState(state vdfile.State) (map[string]parameter.Parameter, map[string]time.Duration, error) {
+	params := make(map[string]parameter.Parameter, len(state.Params))
+	for name, val := range state.Params {
+		param, exists := s.vdfile.Params[name]
+		if !exists {
+			return nil, nil, fmt.Errorf("%w: %s", protocol.ErrParamNotFound, name)
+		}
+
+		clone := param.Clone()
+		if err := parameter.SetDecoded(clone, val); err != nil {
+			return nil, nil, fmt.Errorf("parameter %s: %w", name, err)
+		}
+		params[name] = clone
+	}
+
+	delays := make(map[string]time.Duration, len(state.Delays))
+	for name, val := range state.Delays {
+		if _, exists := s.vdfile.Commands[name]; !exists {
+			return nil, nil, fmt.Errorf("%w: %s", protocol.ErrCommandNotFound, name)
+		}
+
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return nil, nil, fmt.Errorf("delay of %s: %w", name, err)
+		}
+		delays[name] = d
+	}
+
+	if state.Mismatch != nil && len(*state.Mismatch) > MISMATCH_LIMIT {
+		return nil, nil, fmt.Errorf("%w: %s", ErrMismatchTooLong, *state.Mismatch)
+	}
+
+	return params, delays, nil
+}
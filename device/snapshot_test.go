@@ -0,0 +1,162 @@
+package device
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/e9ctrl/vd/protocol"
+	"github.com/e9ctrl/vd/vdfile"
+	"github.com/google/go-cmp/cmp"
+)
+
+const snapshotVDFile = `
+interm = "CR LF"
+outterm = "CR LF"
+
+[[parameter]]
+  name = "current"
+  typ = "int"
+  val = 300
+
+[[parameter]]
+  name = "psi"
+  typ = "float"
+  val = 3.3
+
+[[parameter]]
+  name = "mode"
+  typ = "string"
+  val = "NORM"
+  opt = "NORM|SING"
+
+[[command]]
+  name = "get_current"
+  req = "CUR?"
+  res = "CUR {%d:current}"
+  dly = "10ms"
+
+[preset.fault]
+  mismatch = "ERR"
+
+[preset.fault.params]
+  current = 0
+  mode = "SING"
+
+[preset.fault.delays]
+  get_current = "2s"
+`
+
+func strPtr(s string) *string { return &s }
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, snapshotVDFile)
+
+	exp := vdfile.State{
+		Params:   map[string]any{"current": int64(300), "psi": 3.3, "mode": "NORM"},
+		Delays:   map[string]string{"get_current": "10ms"},
+		Mismatch: strPtr(""),
+	}
+	initial := d.Snapshot()
+	if diff := cmp.Diff(exp, initial); diff != "" {
+		t.Errorf("unexpected snapshot (-want +got):\n%s", diff)
+	}
+
+	d.SetParameter("current", "20")
+	d.SetCommandDelay("get_current", "1s")
+	d.SetMismatch("wrong")
+	if err := d.Restore(initial); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(exp, d.Snapshot()); diff != "" {
+		t.Errorf("state not restored (-want +got):\n%s", diff)
+	}
+	if res := d.Handle(nil, []byte("VOLT?\r\n")); res != nil {
+		t.Errorf("exp no reply with restored empty mismatch got %q", res)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		state  vdfile.State
+		expErr string
+	}{
+		{"decoded numbers", vdfile.State{Params: map[string]any{"current": 12.0, "psi": int64(4)}}, ""},
+		{"json numbers", vdfile.State{Params: map[string]any{"current": "12", "psi": "4"}}, ""},
+		{"unknown parameter", vdfile.State{Params: map[string]any{"volt": 1}}, protocol.ErrParamNotFound.Error()},
+		{"wrong type", vdfile.State{Params: map[string]any{"current": 1, "psi": "high"}}, "parameter psi"},
+		{"not allowed value", vdfile.State{Params: map[string]any{"current": 1, "mode": "BURS"}}, "parameter mode"},
+		{"unknown command", vdfile.State{Delays: map[string]string{"get_volt": "1s"}}, protocol.ErrCommandNotFound.Error()},
+		{"wrong delay", vdfile.State{Params: map[string]any{"current": 1}, Delays: map[string]string{"get_current": "soon"}}, "delay of get_current"},
+		{"mismatch too long", vdfile.State{Mismatch: strPtr(strings.Repeat("x", MISMATCH_LIMIT+1))}, ErrMismatchTooLong.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDevice(t, snapshotVDFile)
+			before := d.Snapshot()
+
+			err := d.Restore(tt.state)
+			if tt.expErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if v, _ := d.GetParameter("current"); v != int64(12) {
+					t.Errorf("exp current 12 got %v", v)
+				}
+				if v, _ := d.GetParameter("psi"); v != 4.0 {
+					t.Errorf("exp psi 4 got %v", v)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.expErr) {
+				t.Fatalf("exp error containing %q got %v", tt.expErr, err)
+			}
+			if diff := cmp.Diff(before, d.Snapshot()); diff != "" {
+				t.Errorf("state changed by failed restore (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPresets(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, snapshotVDFile)
+
+	if diff := cmp.Diff([]string{"fault"}, d.Presets()); diff != "" {
+		t.Errorf("unexpected presets (-want +got):\n%s", diff)
+	}
+
+	if err := d.ApplyPreset("fault"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := d.GetParameter("current"); v != int64(0) {
+		t.Errorf("exp current 0 got %v", v)
+	}
+	if v, _ := d.GetParameter("psi"); v != 3.3 {
+		t.Errorf("exp psi unchanged got %v", v)
+	}
+	if dly, _ := d.GetCommandDelay("get_current"); dly != 2*time.Second {
+		t.Errorf("exp delay 2s got %v", dly)
+	}
+	if res := d.Handle(nil, []byte("VOLT?\r\n")); string(res) != "ERR\r\n" {
+		t.Errorf("exp mismatch ERR got %q", res)
+	}
+
+	if err := d.ApplyPreset("missing"); !errors.Is(err, ErrPresetNotFound) {
+		t.Errorf("exp error %v got %v", ErrPresetNotFound, err)
+	}
+
+	vd, err := vdfile.ReadVDFileFromBytes([]byte(snapshotVDFile + "\n[preset.broken.params]\n  volt = 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDevice(vd); err == nil || !strings.Contains(err.Error(), "preset broken") {
+		t.Errorf("exp error for invalid preset got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package device
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/e9ctrl/vd/log"
+	"github.com/e9ctrl/vd/parameter"
+	"github.com/e9ctrl/vd/vdfile"
+)
+
+// Content of the state file, parameters are stored together with their types
+type stateFile struct {
+	Params []vdfile.ConfigParameter `toml:"parameter"`
+}
+
+// Notify that device parameters changed, without blocking
+func (s *StreamDevice) notifyChange() {
+	select {
+	case s.changes <- struct{}{}:
+	default:
+	}
+}
+
+// Set parameter values saved in the state file. Values of unknown parameters, values which type
+// changed or which are not allowed any more are skipped and keep defaults from the vdfile.
+// Missing file is not an error, all problems are returned joined.
+func (s *StreamDevice) LoadState(path string) error {
+	var state stateFile
+	_, err := toml.DecodeFile(path, &state)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed decoding state file: %w", err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var errs []error
+	for _, p := range state.Params {
+		param, exists := s.vdfile.Params[p.Name]
+		if !exists {
+			errs = append(errs, fmt.Errorf("parameter %s not found in vdfile, value ignored", p.Name))
+			continue
+		}
+		if typ := typeName(param); typ != p.Typ {
+			errs = append(errs, fmt.Errorf("parameter %s changed type from %s to %s, using default", p.Name, p.Typ, typ))
+			continue
+		}
+		if err := parameter.SetDecoded(param, p.Val); err != nil {
+			errs = append(errs, fmt.Errorf("parameter %s value %v invalid, using default: %w", p.Name, p.Val, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Write values of all parameters to the file, the file is replaced atomically
+func (s *StreamDevice) SaveState(path string) error {
+	s.lock.Lock()
+	state := stateFile{Params: make([]vdfile.ConfigParameter, 0, len(s.vdfile.Params))}
+	for name, param := range s.vdfile.Params {
+		state.Params = append(state.Params, vdfile.ConfigParameter{
+			Name: name,
+			Typ:  typeName(param),
+			Val:  param.Value(),
+		})
+	}
+	s.lock.Unlock()
+
+	sort.Slice(state.Params, func(i, j int) bool {
+		return state.Params[i].Name < state.Params[j].Name
+	})
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(state); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Save state to the file after parameters change, changes within the interval are saved at once.
+// Returned function saves pending changes and stops persisting.
+func (s *StreamDevice) PersistState(path string, interval time.Duration) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	save := func() {
+		if err := s.SaveState(path); err != nil {
+			log.ERR("saving state failed", "file", path, "err", err)
+		}
+	}
+
+	go func() {
+		defer close(done)
+		var timer <-chan time.Time
+		for {
+			select {
+			case <-s.changes:
+				if timer == nil {
+					timer = time.After(interval)
+				}
+			case <-timer:
+				timer = nil
+				save()
+			case <-stop:
+				if timer != nil {
+					save()
+				}
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
@@ -0,0 +1,124 @@
+package device
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveLoadState(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "state.toml")
+
+	d := newTestDevice(t, snapshotVDFile)
+	d.SetParameter("current", "20")
+	d.SetParameter("psi", "4.5")
+	d.SetParameter("mode", "SING")
+	if err := d.SaveState(path); err != nil {
+		t.Fatal(err)
+	}
+
+	d2 := newTestDevice(t, snapshotVDFile)
+	if err := d2.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	for name, exp := range map[string]any{"current": int64(20), "psi": 4.5, "mode": "SING"} {
+		if v, _ := d2.GetParameter(name); v != exp {
+			t.Errorf("exp %s %v got %v", name, exp, v)
+		}
+	}
+}
+
+func TestLoadStateInvalid(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.toml")
+	content := `
+[[parameter]]
+  name = "current"
+  typ = "int64"
+  val = 20
+
+[[parameter]]
+  name = "psi"
+  typ = "string"
+  val = "high"
+
+[[parameter]]
+  name = "mode"
+  typ = "string"
+  val = "BURS"
+
+[[parameter]]
+  name = "volt"
+  typ = "float64"
+  val = 1.5
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := newTestDevice(t, snapshotVDFile)
+	err := d.LoadState(path)
+	if err == nil {
+		t.Fatal("exp errors for invalid values")
+	}
+	for _, exp := range []string{
+		"parameter psi changed type from string to float64, using default",
+		"parameter mode value BURS invalid, using default",
+		"parameter volt not found in vdfile, value ignored",
+	} {
+		if !strings.Contains(err.Error(), exp) {
+			t.Errorf("exp %q in error got %v", exp, err)
+		}
+	}
+	for name, exp := range map[string]any{"current": int64(20), "psi": 3.3, "mode": "NORM"} {
+		if v, _ := d.GetParameter(name); v != exp {
+			t.Errorf("exp %s %v got %v", name, exp, v)
+		}
+	}
+
+	if err := d.LoadState(filepath.Join(dir, "missing.toml")); err != nil {
+		t.Errorf("exp no error for missing file got %v", err)
+	}
+
+	os.WriteFile(path, []byte("[[parameter"), 0o644)
+	if err := d.LoadState(path); err == nil {
+		t.Error("exp error for corrupted file")
+	}
+}
+
+func TestPersistState(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "state.toml")
+
+	d := newTestDevice(t, snapshotVDFile)
+	stop := d.PersistState(path, 10*time.Millisecond)
+
+	d.SetParameter("current", "42")
+	loaded := func(exp int64) bool {
+		d2 := newTestDevice(t, snapshotVDFile)
+		if err := d2.LoadState(path); err != nil {
+			return false
+		}
+		v, _ := d2.GetParameter("current")
+		return v == exp
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !loaded(42) {
+		if time.Now().After(deadline) {
+			t.Fatal("state not saved after change")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// pending change is saved when persisting stops
+	d.SetParameter("current", "43")
+	stop()
+	if !loaded(43) {
+		t.Error("pending change not saved on stop")
+	}
+}
@@ -0,0 +1,65 @@
+package device
+
+import (
+	"testing"
+)
+
+const templateVDFile = `
+interm = "LF"
+outterm = "LF"
+
+[[parameter]]
+  name = "enable"
+  typ = "bool"
+  val = false
+
+[[parameter]]
+  name = "fault"
+  typ = "bool"
+  val = true
+
+[[parameter]]
+  name = "current"
+  typ = "int"
+  val = 80
+
+[[command]]
+  name = "output"
+  req = "OUTP {%t:enable}"
+  res = 'OUTP {map(enable, true:"ON", false:"OFF")}'
+
+[[command]]
+  name = "get_output"
+  req = "OUTP?"
+  res = 'OUTP {map(enable, true:"ON", false:"OFF")}'
+
+[[command]]
+  name = "get_status"
+  req = "STAT?"
+  res = "STAT {%02X:bits(enable, fault, current > 100)}"
+
+[[command]]
+  name = "get_current"
+  req = "CURR?"
+  res = 'CURR {%.1f:current / 10}A {if(current > 100, "HIGH", "OK")}'
+
+[[command]]
+  name = "set_current"
+  req = "CURR {%d:current}"
+`
+
+func TestHandleTemplates(t *testing.T) {
+	t.Parallel()
+	d := newTestDevice(t, templateVDFile)
+
+	// requests are sent one after another to the same device
+	testHandle(t, d, []handleTest{
+		{"mapped value", "OUTP?\n", "OUTP OFF\n"},
+		{"status word", "STAT?\n", "STAT 02\n"},
+		{"arithmetic and condition", "CURR?\n", "CURR 8.0A OK\n"},
+		{"set mapped value", "OUTP true\n", "OUTP ON\n"},
+		{"set current", "CURR 150\n", ""},
+		{"status word after change", "STAT?\n", "STAT 07\n"},
+		{"condition after change", "CURR?\n", "CURR 15.0A HIGH\n"},
+	})
+}
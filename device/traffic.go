@@ -0,0 +1,60 @@
+package device
+
+import (
+	"time"
+
+	"github.com/e9ctrl/vd/info"
+	"github.com/e9ctrl/vd/log"
+)
+
+// Direction of the message
+const (
+	DirectionRX = "rx"
+	DirectionTX = "tx"
+)
+
+// Size of the buffer of every subscriber, messages are dropped for slow subscribers
+const subscriberBuffer = 64
+
+// Single message received from or sent to a client
+type Traffic = info.Traffic
+
+// Subscribe to traffic of the device, returned function cancels subscription
+func (s *StreamDevice) Subscribe() (<-chan Traffic, func()) {
+	ch := make(chan Traffic, subscriberBuffer)
+
+	s.subLock.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.subLock.Unlock()
+
+	return ch, func() {
+		s.subLock.Lock()
+		delete(s.subscribers, ch)
+		s.subLock.Unlock()
+	}
+}
+
+// Send message to all subscribers without blocking
+func (s *StreamDevice) publish(client uint64, direction, command string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	t := Traffic{
+		Time:      time.Now(),
+		Client:    client,
+		Direction: direction,
+		Command:   command,
+		Data:      string(data),
+		Hex:       log.Hex(data),
+	}
+
+	s.subLock.Lock()
+	defer s.subLock.Unlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- t:
+		default:
+		}
+	}
+}
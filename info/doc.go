@@ -0,0 +1,3 @@
+// info package holds descriptions of the simulated device shared by the device and the HTTP API,
+// so the API does not depend on the device implementation.
+package info
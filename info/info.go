@@ -0,0 +1,109 @@
+package info
+
+import "time"
+
+// Parameter access levels, they restrict what TCP clients can do with the parameter.
+// HTTP API follows ro and wo unless the request is forced and can access api-only parameters.
+const (
+	AccessReadWrite = "rw"
+	AccessReadOnly  = "ro"
+	AccessWriteOnly = "wo"
+	AccessAPIOnly   = "api-only"
+)
+
+// Description of the parameter exposed via HTTP API
+type Parameter struct {
+	Name  string   `json:"name"`
+	Typ   string   `json:"typ"`
+	Value any      `json:"value"`
+	Opts  []string `json:"opts,omitempty"`
+	Scope string   `json:"scope"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+	Step  float64  `json:"step,omitempty"`
+	Clamp bool     `json:"clamp,omitempty"`
+	// Access of TCP clients
+	Access string `json:"access"`
+}
+
+// Description of the command exposed via HTTP API
+type Command struct {
+	Name string `json:"name"`
+	Req  string `json:"req"`
+	Res  string `json:"res,omitempty"`
+	// Lines of multi-line response
+	Lines []string `json:"lines,omitempty"`
+	Dly   string   `json:"dly"`
+}
+
+// Client connected to TCP server
+type Client struct {
+	ID          uint64    `json:"id"`
+	RemoteAddr  string    `json:"remote_addr"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+// Single message received from or sent to a client
+type Traffic struct {
+	Time      time.Time `json:"time"`
+	Client    uint64    `json:"client"`
+	Direction string    `json:"direction"`
+	Command   string    `json:"command,omitempty"`
+	Data      string    `json:"data"`
+	Hex       string    `json:"hex"`
+}
+
+// Request decoded by the device
+type HistoryEntry struct {
+	Time   time.Time `json:"time"`
+	Client uint64    `json:"client"`
+	// Request without terminator
+	Raw     string `json:"raw"`
+	Hex     string `json:"hex"`
+	Command string `json:"command,omitempty"`
+	// One of GetParam, SetParam, Mismatch or Unknown
+	Typ string `json:"typ"`
+	// Values received in set requests
+	Values map[string]any `json:"values,omitempty"`
+	// Reason why the request was not handled
+	Error string `json:"error,omitempty"`
+}
+
+// Criteria of history entries, zero values match all
+type HistoryFilter struct {
+	Command string
+	Since   time.Time
+	// Maximal number of the newest entries
+	Limit int
+}
+
+// Check if the entry meets the criteria, Limit is applied to the whole history
+func (f HistoryFilter) Match(e HistoryEntry) bool {
+	if f.Command != "" && e.Command != f.Command {
+		return false
+	}
+	return f.Since.IsZero() || !e.Time.Before(f.Since)
+}
+
+// Error reported by the device, status is OR-ed into the status register when the error is pushed
+type DeviceError struct {
+	Code   int    `toml:"code" json:"code"`
+	Msg    string `toml:"msg,omitempty" json:"msg,omitempty"`
+	Status int    `toml:"status,omitzero" json:"status,omitempty"`
+}
+
+// Content of the error queue exposed via HTTP API
+type ErrorQueue struct {
+	// Errors the oldest first
+	Errors []DeviceError `json:"errors"`
+	Status int           `json:"status"`
+}
+
+// Runtime state of the device, used for snapshots and presets.
+// Parameters and commands that are not listed keep their state.
+type State struct {
+	Params map[string]any `toml:"params,omitempty" json:"params,omitempty"`
+	// Delays of commands as durations, e.g. 1s
+	Delays   map[string]string `toml:"delays,omitempty" json:"delays,omitempty"`
+	Mismatch *string           `toml:"mismatch,omitempty" json:"mismatch,omitempty"`
+}
@@ -0,0 +1,133 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/jwalton/gchalk"
+)
+
+// Handler that prints records in human readable form with coloured prefixes
+type ConsoleHandler struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	level  slog.Leveler
+	chalk  *gchalk.Builder
+	attrs  []slog.Attr
+	prefix string
+}
+
+// Create console handler writing to w, colours are used only when color is true
+func NewConsoleHandler(w io.Writer, level slog.Leveler, color bool) *ConsoleHandler {
+	chalk := gchalk.New()
+	if !color {
+		chalk = gchalk.New(gchalk.ForceLevel(gchalk.LevelNone))
+	}
+	return &ConsoleHandler{
+		w:     w,
+		mu:    &sync.Mutex{},
+		level: level,
+		chalk: chalk,
+	}
+}
+
+func (h *ConsoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *ConsoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	for _, a := range attrs {
+		a.Key = h.prefix + a.Key
+		h2.attrs = append(h2.attrs[:len(h2.attrs):len(h2.attrs)], a)
+	}
+	return &h2
+}
+
+func (h *ConsoleHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+func (h *ConsoleHandler) Handle(_ context.Context, r slog.Record) error {
+	var (
+		kind   string
+		hex    string
+		fields strings.Builder
+	)
+
+	addAttr := func(a slog.Attr) {
+		switch a.Key {
+		case KindKey:
+			kind = a.Value.String()
+		case HexKey:
+			hex = a.Value.String()
+		default:
+			fmt.Fprintf(&fields, " %s=%v", a.Key, a.Value)
+		}
+	}
+	for _, a := range h.attrs {
+		addAttr(a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		a.Key = h.prefix + a.Key
+		addAttr(a)
+		return true
+	})
+
+	var line strings.Builder
+	line.WriteString(h.kindPrefix(kind, r.Level))
+	line.WriteString(h.chalk.Gray(r.Time.Format("15:04:05.000")))
+	line.WriteString(" ")
+	if kind == KindTX || kind == KindRX {
+		line.WriteString(h.chalk.BrightWhite(r.Message))
+		line.WriteString(" ")
+		line.WriteString(h.chalk.Gray("[" + hex + "]"))
+	} else {
+		line.WriteString(r.Message)
+	}
+	line.WriteString(h.chalk.Gray(fields.String()))
+	line.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, line.String())
+	return err
+}
+
+func (h *ConsoleHandler) kindPrefix(kind string, level slog.Level) string {
+	switch kind {
+	case KindERR:
+		return h.chalk.BrightRed("[ERR] ")
+	case KindINF:
+		return h.chalk.BrightBlue("[INF] ")
+	case KindTX:
+		return h.chalk.BrightGreen("[<--] ")
+	case KindRX:
+		return h.chalk.BrightYellow("[-->] ")
+	case KindCMD:
+		return h.chalk.BrightBlue("[•••] ")
+	case KindAPI:
+		return h.chalk.BrightMagenta("[API] ")
+	case KindDLY:
+		return h.chalk.BrightCyan("[ 💤] ")
+	case KindMSM:
+		return h.chalk.BrightRed("[MSM] ")
+	}
+
+	switch {
+	case level >= slog.LevelError:
+		return h.chalk.BrightRed("[ERR] ")
+	case level >= slog.LevelWarn:
+		return h.chalk.BrightYellow("[WRN] ")
+	case level >= slog.LevelInfo:
+		return h.chalk.BrightBlue("[INF] ")
+	default:
+		return h.chalk.Gray("[DBG] ")
+	}
+}
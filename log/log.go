@@ -1,74 +1,181 @@
 package log
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
 	"strings"
+	"time"
 	"unicode"
+	"unicode/utf8"
 
-	"github.com/jwalton/gchalk"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-var (
-	prefixERR = gchalk.BrightRed("[ERR] ")
-	prefixINF = gchalk.BrightBlue("[INF] ")
-	prefixTX  = gchalk.BrightGreen("[<--] ")
-	prefixRX  = gchalk.BrightYellow("[-->] ")
-	prefixCMD = gchalk.BrightBlue("[•••] ")
-	prefixAPI = gchalk.BrightMagenta("[API] ")
-	prefixDLY = gchalk.BrightCyan("[ 💤] ")
-	prefixMSM = gchalk.BrightRed("[MSM] ")
+// Output formats of the logger
+const (
+	FormatConsole = "console"
+	FormatText    = "text"
+	FormatJSON    = "json"
 )
 
-func ERR(msg ...any) {
-	fmt.Println(gchalk.BrightRed(prefixERR), msg)
+// Kinds of messages, kept under kind key of every record
+const (
+	KindERR = "ERR"
+	KindINF = "INF"
+	KindTX  = "TX"
+	KindRX  = "RX"
+	KindCMD = "CMD"
+	KindAPI = "API"
+	KindDLY = "DLY"
+	KindMSM = "MSM"
+)
+
+// Keys of the fields added to records
+const (
+	KindKey    = "kind"
+	HexKey     = "hex"
+	ClientKey  = "client"
+	CommandKey = "command"
+)
+
+// Options of the logger
+type Options struct {
+	// Minimal level of printed messages, traffic is logged with debug level
+	Level slog.Level
+	// One of console, text or json
+	Format string
+	// Path of the log file, standard output is used when empty
+	File string
+	// Size in megabytes after which log file is rotated
+	MaxSize int
+	// Number of rotated log files to keep, all are kept when 0
+	MaxBackups int
 }
 
-func INF(msg ...any) {
-	fmt.Println(prefixINF, msg)
+var logger = slog.New(NewConsoleHandler(os.Stdout, slog.LevelDebug, true))
+
+// Configure global logger, by default it prints all messages in colour to standard output
+func Setup(opts Options) error {
+	var w io.Writer = os.Stdout
+	color := true
+	if opts.File != "" {
+		w = &lumberjack.Logger{
+			Filename:   opts.File,
+			MaxSize:    opts.MaxSize,
+			MaxBackups: opts.MaxBackups,
+		}
+		color = false
+	}
+
+	var h slog.Handler
+	hOpts := &slog.HandlerOptions{Level: opts.Level}
+	switch opts.Format {
+	case "", FormatConsole:
+		h = NewConsoleHandler(w, opts.Level, color)
+	case FormatText:
+		h = slog.NewTextHandler(w, hOpts)
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, hOpts)
+	default:
+		return fmt.Errorf("unknown log format %s", opts.Format)
+	}
+
+	logger = slog.New(h)
+	return nil
 }
 
-func API(msg ...any) {
-	fmt.Println(prefixAPI, msg)
+// Parse level name (debug, info, warn, error)
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(s))
+	return l, err
 }
 
-func MSM(msg ...any) {
-	fmt.Println(prefixMSM, "command unknown, returning mismatch", msg)
+// Logger used by the package functions
+func Logger() *slog.Logger {
+	return logger
 }
 
-func DLY(msg ...any) {
-	fmt.Println(prefixDLY, msg)
+func log(level slog.Level, kind, msg string, args ...any) {
+	if !logger.Enabled(context.Background(), level) {
+		return
+	}
+	r := slog.NewRecord(time.Now(), level, msg, 0)
+	r.AddAttrs(slog.String(KindKey, kind))
+	r.Add(args...)
+	_ = logger.Handler().Handle(context.Background(), r)
 }
 
-func printWithPrefix(prefix, str string, hex []byte) {
-	h := fmt.Sprintf("[% x]", hex)
-	fmt.Println(prefix, gchalk.BrightWhite(str), gchalk.Gray(h))
+func ERR(msg string, args ...any) {
+	log(slog.LevelError, KindERR, msg, args...)
 }
 
-func CMD(str ...any) {
-	fmt.Println(prefixCMD, str)
+func INF(msg string, args ...any) {
+	log(slog.LevelInfo, KindINF, msg, args...)
 }
 
-func TX(msg []byte) {
-	str := string(msg)
+func API(msg string, args ...any) {
+	log(slog.LevelInfo, KindAPI, msg, args...)
+}
 
-	str = strings.Map(func(r rune) rune {
-		if unicode.IsPrint(r) {
-			return r
-		}
-		return -1
-	}, str)
+func MSM(mismatch string, args ...any) {
+	log(slog.LevelWarn, KindMSM, "command unknown, returning mismatch", append([]any{"mismatch", mismatch}, args...)...)
+}
+
+func DLY(d time.Duration, args ...any) {
+	log(slog.LevelDebug, KindDLY, "delaying response", append([]any{"delay", d}, args...)...)
+}
+
+func CMD(msg string, args ...any) {
+	log(slog.LevelDebug, KindCMD, msg, args...)
+}
 
-	printWithPrefix(prefixTX, str, msg)
+func TX(msg []byte, args ...any) {
+	log(slog.LevelDebug, KindTX, Printable(msg), append([]any{HexKey, Hex(msg)}, args...)...)
 }
 
-func RX(msg []byte) {
-	str := string(msg)
+func RX(msg []byte, args ...any) {
+	log(slog.LevelDebug, KindRX, Printable(msg), append([]any{HexKey, Hex(msg)}, args...)...)
+}
 
-	str = strings.Map(func(r rune) rune {
+// Printable part of the message, non-printable characters are dropped
+func Printable(msg []byte) string {
+	return strings.Map(func(r rune) rune {
 		if unicode.IsPrint(r) {
 			return r
 		}
 		return -1
-	}, str)
-	printWithPrefix(prefixRX, str, msg)
+	}, string(msg))
+}
+
+// Message with terminators and other non-printable characters shown as escape sequences
+func Escape(msg []byte) string {
+	var b strings.Builder
+	for len(msg) > 0 {
+		r, size := utf8.DecodeRune(msg)
+		switch {
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case size == 1 && (r == utf8.RuneError || !unicode.IsPrint(r)):
+			fmt.Fprintf(&b, `\x%02x`, msg[0])
+		case !unicode.IsPrint(r):
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+		msg = msg[size:]
+	}
+	return b.String()
+}
+
+// Hex representation of the message
+func Hex(msg []byte) string {
+	return fmt.Sprintf("% x", msg)
 }
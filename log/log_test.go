@@ -0,0 +1,124 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestConsoleHandler(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger = slog.New(NewConsoleHandler(buf, slog.LevelInfo, false))
+	defer Setup(Options{Level: slog.LevelDebug})
+
+	RX([]byte("CUR?\r\n"), ClientKey, 1)
+	INF("client connected", ClientKey, 1)
+	TX([]byte("CUR 20\r\n"), ClientKey, 1)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("exp only info message got: %q", lines)
+	}
+	if !strings.HasPrefix(lines[0], "[INF] ") || !strings.HasSuffix(lines[0], " client connected client=1") {
+		t.Errorf("unexpected line: %s", lines[0])
+	}
+
+	buf.Reset()
+	logger = slog.New(NewConsoleHandler(buf, slog.LevelDebug, false))
+	TX([]byte("CUR 20\r\n"), ClientKey, 1)
+	if !strings.HasPrefix(buf.String(), "[<--] ") || !strings.HasSuffix(buf.String(), " CUR 20 [43 55 52 20 32 30 0d 0a] client=1\n") {
+		t.Errorf("unexpected line: %s", buf.String())
+	}
+}
+
+func TestSetupJSONFile(t *testing.T) {
+	path := t.TempDir() + "/vd.log"
+	err := Setup(Options{Level: slog.LevelDebug, Format: FormatJSON, File: path, MaxSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Setup(Options{Level: slog.LevelDebug})
+
+	RX([]byte("CUR?\r\n"), ClientKey, 2, CommandKey, "get_current")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rec map[string]any
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("log line is not valid json: %v", err)
+	}
+
+	exp := map[string]any{
+		"level":    "DEBUG",
+		"msg":      "CUR?",
+		KindKey:    KindRX,
+		HexKey:     "43 55 52 3f 0d 0a",
+		ClientKey:  2.0,
+		CommandKey: "get_current",
+	}
+	for k, v := range exp {
+		if rec[k] != v {
+			t.Errorf("exp %s: %v got: %v", k, v, rec[k])
+		}
+	}
+	if _, ok := rec["time"]; !ok {
+		t.Error("missing timestamp")
+	}
+}
+
+func TestSetupWrongFormat(t *testing.T) {
+	if err := Setup(Options{Format: "xml"}); err == nil {
+		t.Error("exp error for unknown format")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name   string
+		level  string
+		exp    slog.Level
+		expErr bool
+	}{
+		{"debug", "debug", slog.LevelDebug, false},
+		{"warn upper case", "WARN", slog.LevelWarn, false},
+		{"unknown", "verbose", slog.LevelInfo, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLevel(tt.level)
+			if (err != nil) != tt.expErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err == nil && got != tt.exp {
+				t.Errorf("exp level: %v got: %v", tt.exp, got)
+			}
+		})
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  []byte
+		exp  string
+	}{
+		{"printable", []byte("CUR 300"), "CUR 300"},
+		{"terminators", []byte("CUR?\r\n"), `CUR?\r\n`},
+		{"control characters", []byte{0x02, 'A', '\t', 0x03}, `\x02A\t\x03`},
+		{"invalid utf8", []byte{0xff, 0xfe}, `\xff\xfe`},
+		{"unicode", []byte("25°C"), "25°C"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Escape(tt.msg); got != tt.exp {
+				t.Errorf("exp: %s got: %s", tt.exp, got)
+			}
+		})
+	}
+}
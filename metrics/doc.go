@@ -0,0 +1,2 @@
+// metrics package collects Prometheus metrics of the simulated device and exposes them over HTTP
+package metrics
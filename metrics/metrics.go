@@ -0,0 +1,198 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "vd"
+
+// Reasons of sending mismatch to the client
+const (
+	ReasonUnknownCommand = "unknown_command"
+	ReasonInvalidValue   = "invalid_value"
+	ReasonOutOfRange     = "out_of_range"
+	ReasonAccessDenied   = "access_denied"
+	ReasonInvalidIndex   = "invalid_index"
+)
+
+// Function returning current values of numeric parameters, used to fill parameter gauges
+type ParamsFunc func() map[string]float64
+
+// Metrics keeps all collectors of a single device together with registry they are registered in.
+// Methods are safe to call on nil Metrics, in that case nothing is collected.
+type Metrics struct {
+	registry     *prometheus.Registry
+	clients      prometheus.Gauge
+	requests     *prometheus.CounterVec
+	mismatches   *prometheus.CounterVec
+	decodeErrors prometheus.Counter
+	bytesIn      prometheus.Counter
+	bytesOut     prometheus.Counter
+	triggers     *prometheus.CounterVec
+	delays       prometheus.Histogram
+}
+
+// Create metrics with a new registry, params is called on every scrape to read parameter values
+func New(params ParamsFunc) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		clients: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "clients_connected",
+			Help:      "Number of clients connected to the TCP server.",
+		}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Number of requests received per command.",
+		}, []string{"command"}),
+		mismatches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mismatches_total",
+			Help:      "Number of requests answered with mismatch.",
+		}, []string{"reason"}),
+		decodeErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "decode_errors_total",
+			Help:      "Number of received messages that could not be decoded.",
+		}),
+		bytesIn: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "received_bytes_total",
+			Help:      "Number of bytes received from clients.",
+		}),
+		bytesOut: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sent_bytes_total",
+			Help:      "Number of bytes sent to clients.",
+		}),
+		triggers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "triggers_total",
+			Help:      "Number of triggered responses sent to clients per command.",
+		}, []string{"command"}),
+		delays: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "response_delay_seconds",
+			Help:      "Delays applied to responses.",
+			Buckets:   []float64{0, 0.001, 0.01, 0.05, 0.1, 0.5, 1, 2, 5, 10, 30},
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.clients,
+		m.requests,
+		m.mismatches,
+		m.decodeErrors,
+		m.bytesIn,
+		m.bytesOut,
+		m.triggers,
+		m.delays,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if params != nil {
+		m.registry.MustRegister(&paramCollector{params: params})
+	}
+
+	return m
+}
+
+// HTTP handler exposing metrics in Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Registry with all collectors
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+func (m *Metrics) ClientConnected() {
+	if m == nil {
+		return
+	}
+	m.clients.Inc()
+}
+
+func (m *Metrics) ClientDisconnected() {
+	if m == nil {
+		return
+	}
+	m.clients.Dec()
+}
+
+func (m *Metrics) Request(command string) {
+	if m == nil {
+		return
+	}
+	m.requests.WithLabelValues(command).Inc()
+}
+
+func (m *Metrics) Mismatch(reason string) {
+	if m == nil {
+		return
+	}
+	m.mismatches.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) DecodeError() {
+	if m == nil {
+		return
+	}
+	m.decodeErrors.Inc()
+}
+
+func (m *Metrics) Received(n int) {
+	if m == nil {
+		return
+	}
+	m.bytesIn.Add(float64(n))
+}
+
+func (m *Metrics) Sent(n int) {
+	if m == nil {
+		return
+	}
+	m.bytesOut.Add(float64(n))
+}
+
+func (m *Metrics) Trigger(command string) {
+	if m == nil {
+		return
+	}
+	m.triggers.WithLabelValues(command).Inc()
+}
+
+func (m *Metrics) Delay(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.delays.Observe(d.Seconds())
+}
+
+// Collector that reads parameter values while scraping
+type paramCollector struct {
+	params ParamsFunc
+}
+
+var paramDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "parameter_value"),
+	"Current value of numeric parameter.",
+	[]string{"param"}, nil,
+)
+
+func (c *paramCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- paramDesc
+}
+
+func (c *paramCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, val := range c.params() {
+		ch <- prometheus.MustNewConstMetric(paramDesc, prometheus.GaugeValue, val, name)
+	}
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+	m := New(func() map[string]float64 {
+		return map[string]float64{"current": 300, "psi": 3.3}
+	})
+
+	m.ClientConnected()
+	m.ClientConnected()
+	m.ClientDisconnected()
+	m.Request("get_current")
+	m.Request("get_current")
+	m.Request("set_psi")
+	m.Mismatch(ReasonUnknownCommand)
+	m.DecodeError()
+	m.Received(6)
+	m.Sent(9)
+	m.Trigger("get_psi")
+	m.Delay(20 * time.Millisecond)
+
+	tests := []struct {
+		name string
+		got  float64
+		exp  float64
+	}{
+		{"clients", testutil.ToFloat64(m.clients), 1},
+		{"get_current requests", testutil.ToFloat64(m.requests.WithLabelValues("get_current")), 2},
+		{"set_psi requests", testutil.ToFloat64(m.requests.WithLabelValues("set_psi")), 1},
+		{"mismatches", testutil.ToFloat64(m.mismatches.WithLabelValues(ReasonUnknownCommand)), 1},
+		{"decode errors", testutil.ToFloat64(m.decodeErrors), 1},
+		{"bytes in", testutil.ToFloat64(m.bytesIn), 6},
+		{"bytes out", testutil.ToFloat64(m.bytesOut), 9},
+		{"triggers", testutil.ToFloat64(m.triggers.WithLabelValues("get_psi")), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.exp {
+				t.Errorf("exp value: %v got: %v", tt.exp, tt.got)
+			}
+		})
+	}
+
+	exp := `
+# HELP vd_parameter_value Current value of numeric parameter.
+# TYPE vd_parameter_value gauge
+vd_parameter_value{param="current"} 300
+vd_parameter_value{param="psi"} 3.3
+`
+	if err := testutil.GatherAndCompare(m.Registry(), strings.NewReader(exp), "vd_parameter_value"); err != nil {
+		t.Error(err)
+	}
+
+	if n := testutil.CollectAndCount(m.delays); n != 1 {
+		t.Errorf("exp one delay histogram got: %d", n)
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	t.Parallel()
+	var m *Metrics
+	// none of the methods should panic
+	m.ClientConnected()
+	m.ClientDisconnected()
+	m.Request("get_current")
+	m.Mismatch(ReasonInvalidValue)
+	m.DecodeError()
+	m.Received(1)
+	m.Sent(1)
+	m.Trigger("get_current")
+	m.Delay(time.Second)
+}
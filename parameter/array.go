@@ -0,0 +1,217 @@
+package parameter
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Separator of array elements used when none is given
+const DefaultSeparator = ","
+
+var (
+	ErrWrongLength  = errors.New("wrong number of array elements")
+	ErrWrongShape   = errors.New("array cannot have both fixed and max length")
+	ErrIndexInvalid = errors.New("index outside array")
+)
+
+// Indexed is implemented by parameters keeping list of values of the same type
+type Indexed interface {
+	Parameter
+	Len() int
+	Index(i int) (any, error)
+	SetIndex(i int, val any) error
+	// Type of array elements
+	Elem() reflect.Kind
+	Shape() Shape
+}
+
+// Length and format of array parameter
+type Shape struct {
+	// Fixed number of elements, 0 when not fixed
+	Len int
+	// Max number of elements, 0 when not limited
+	MaxLen int
+	// Separator of elements in requests and responses
+	Sep string
+	// Index of the first element, e.g. 1 for channels counted from 1
+	First int
+}
+
+// ArrayParameter holds list of values, every element is checked like a parameter of element type
+type ArrayParameter struct {
+	// element with zero value used to create new elements
+	elem  Parameter
+	vals  []Parameter
+	shape Shape
+	m     sync.RWMutex
+}
+
+// Array parameter constructor, typ is type of elements, opt and rng limit every element.
+// Initial value can be a list or string with elements separated by shape separator.
+func NewArray(val any, opt, typ string, rng Range, shape Shape) (*ArrayParameter, error) {
+	if shape.Len > 0 && shape.MaxLen > 0 {
+		return nil, ErrWrongShape
+	}
+	if shape.Len < 0 || shape.MaxLen < 0 {
+		return nil, ErrWrongLength
+	}
+	if shape.Sep == "" {
+		shape.Sep = DefaultSeparator
+	}
+
+	elem, err := newEmpty(opt, typ, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	param := &ArrayParameter{
+		elem:  elem,
+		shape: shape,
+	}
+
+	// fixed length array without initial value starts with zero values
+	if val == nil {
+		for i := 0; i < shape.Len; i++ {
+			param.vals = append(param.vals, elem.Clone())
+		}
+		return param, nil
+	}
+
+	if err := param.SetValue(val); err != nil {
+		return nil, err
+	}
+	return param, nil
+}
+
+// Value setter, it accepts list of elements or string with elements separated by separator.
+// Value is changed only when all elements are valid.
+func (p *ArrayParameter) SetValue(val any) error {
+	var elems []any
+	switch v := val.(type) {
+	case string:
+		if v != "" {
+			for _, e := range strings.Split(v, p.shape.Sep) {
+				elems = append(elems, strings.TrimSpace(e))
+			}
+		}
+	case []any:
+		elems = v
+	default:
+		rv := reflect.ValueOf(val)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return ErrWrongTypeVal
+		}
+		for i := 0; i < rv.Len(); i++ {
+			elems = append(elems, rv.Index(i).Interface())
+		}
+	}
+
+	if (p.shape.Len > 0 && len(elems) != p.shape.Len) || (p.shape.MaxLen > 0 && len(elems) > p.shape.MaxLen) {
+		return fmt.Errorf("%w: %d", ErrWrongLength, len(elems))
+	}
+
+	vals := make([]Parameter, len(elems))
+	for i, e := range elems {
+		vals[i] = p.elem.Clone()
+		if err := SetDecoded(vals[i], e); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+
+	p.m.Lock()
+	p.vals = vals
+	p.m.Unlock()
+	return nil
+}
+
+// Value getter, returns copy of elements
+func (p *ArrayParameter) Value() any {
+	p.m.RLock()
+	defer p.m.RUnlock()
+	vals := make([]any, len(p.vals))
+	for i, v := range p.vals {
+		vals[i] = v.Value()
+	}
+	return vals
+}
+
+// Type getter
+func (p *ArrayParameter) Type() reflect.Kind {
+	return reflect.Slice
+}
+
+// Type of elements getter
+func (p *ArrayParameter) Elem() reflect.Kind {
+	return p.elem.Type()
+}
+
+// Shape getter
+func (p *ArrayParameter) Shape() Shape {
+	return p.shape
+}
+
+// To String representation, elements are separated with separator
+func (p *ArrayParameter) String() string {
+	p.m.RLock()
+	defer p.m.RUnlock()
+	vals := make([]string, len(p.vals))
+	for i, v := range p.vals {
+		vals[i] = v.String()
+	}
+	return strings.Join(vals, p.shape.Sep)
+}
+
+// Return allowed values of elements if available
+func (p *ArrayParameter) Opts() []string {
+	return p.elem.Opts()
+}
+
+// Return limits of elements
+func (p *ArrayParameter) Range() Range {
+	return p.elem.Range()
+}
+
+// Return number of elements
+func (p *ArrayParameter) Len() int {
+	p.m.RLock()
+	defer p.m.RUnlock()
+	return len(p.vals)
+}
+
+// Return value of the element with given index counted from the first one
+func (p *ArrayParameter) Index(i int) (any, error) {
+	p.m.RLock()
+	defer p.m.RUnlock()
+	if i < p.shape.First || i-p.shape.First >= len(p.vals) {
+		return nil, fmt.Errorf("%w: %d", ErrIndexInvalid, i)
+	}
+	return p.vals[i-p.shape.First].Value(), nil
+}
+
+// Set value of the element with given index counted from the first one
+func (p *ArrayParameter) SetIndex(i int, val any) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if i < p.shape.First || i-p.shape.First >= len(p.vals) {
+		return fmt.Errorf("%w: %d", ErrIndexInvalid, i)
+	}
+	return SetDecoded(p.vals[i-p.shape.First], val)
+}
+
+// Return independent copy of the parameter with the same shape and elements
+func (p *ArrayParameter) Clone() Parameter {
+	p.m.RLock()
+	defer p.m.RUnlock()
+	vals := make([]Parameter, len(p.vals))
+	for i, v := range p.vals {
+		vals[i] = v.Clone()
+	}
+	return &ArrayParameter{
+		elem:  p.elem,
+		vals:  vals,
+		shape: p.shape,
+	}
+}
@@ -10,7 +10,7 @@ import (
 )
 
 type paramType interface {
-	int | int32 | int64 | float32 | float64 | bool | string
+	int | int8 | int16 | int32 | int64 | uint8 | uint16 | uint32 | uint64 | float32 | float64 | bool | string
 }
 
 var (
@@ -19,6 +19,7 @@ var (
 	ErrWrongStringVal   = errors.New("could not convert paramTypeString to string")
 	ErrUnknownParamType = errors.New("unknown parameter type")
 	ErrWrongIntVal      = errors.New("received param type that cannot be converted to int")
+	ErrWrongUintVal     = errors.New("received param type that cannot be converted to uint")
 	ErrWrongFloatVal    = errors.New("received param type that cannot be converted to float")
 	ErrWrongBoolVal     = errors.New("received param type that cannot be converted to bool")
 	ErrWrongTypeVal     = errors.New("received value with invalid type")
@@ -29,8 +30,11 @@ var (
 type Parameter interface {
 	SetValue(any) error
 	Value() any
+	Type() reflect.Kind
 	String() string
 	Opts() []string
+	Range() Range
+	Clone() Parameter
 }
 
 // ConcreteParameter[T paramType] hold the actual concrete value for each parameter created with New constructor.
@@ -39,6 +43,7 @@ type ConcreteParameter[T paramType] struct {
 	typ  reflect.Kind
 	val  T
 	opts []T
+	rng  Range
 	m    sync.RWMutex
 }
 
@@ -47,48 +52,96 @@ type ConcreteParameter[T paramType] struct {
 
 // Parameter constructor, the constructor will automatically create the ConcreteParameter instance base on the value passed on in the params
 func New(val any, opt, typ string) (Parameter, error) {
+	return NewWithRange(val, opt, typ, Range{})
+}
+
+// Parameter constructor like New, numeric values are additionally limited by the range
+func NewWithRange(val any, opt, typ string, rng Range) (Parameter, error) {
+	param, err := newEmpty(opt, typ, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := SetDecoded(param, val); err != nil {
+		return nil, err
+	}
+	return param, nil
+}
+
+// Create parameter of the given type holding zero value
+func newEmpty(opt, typ string, rng Range) (Parameter, error) {
 	switch typ {
+	case "int8":
+		return newParameter[int8](reflect.Int8, opt, rng)
 	case "int16":
-		return newParameter[int](reflect.Int, val, opt)
+		return newParameter[int16](reflect.Int16, opt, rng)
 	case "int32":
-		return newParameter[int32](reflect.Int32, val, opt)
+		return newParameter[int32](reflect.Int32, opt, rng)
 	case "int":
 		fallthrough
 	case "int64":
-		return newParameter[int64](reflect.Int64, val, opt)
+		return newParameter[int64](reflect.Int64, opt, rng)
+	case "byte":
+		fallthrough
+	case "uint8":
+		return newParameter[uint8](reflect.Uint8, opt, rng)
+	case "uint16":
+		return newParameter[uint16](reflect.Uint16, opt, rng)
+	case "uint32":
+		return newParameter[uint32](reflect.Uint32, opt, rng)
+	case "uint":
+		fallthrough
+	case "uint64":
+		return newParameter[uint64](reflect.Uint64, opt, rng)
 	case "float32":
-		return newParameter[float32](reflect.Float32, val, opt)
+		return newParameter[float32](reflect.Float32, opt, rng)
 	case "float":
 		fallthrough
 	case "float64":
-		return newParameter[float64](reflect.Float64, val, opt)
+		return newParameter[float64](reflect.Float64, opt, rng)
 	case "string":
-		return newParameter[string](reflect.String, val, opt)
+		return newParameter[string](reflect.String, opt, rng)
 	case "bool":
-		return newParameter[bool](reflect.Bool, val, opt)
+		return newParameter[bool](reflect.Bool, opt, rng)
 	}
 
 	return nil, ErrUnknownParamType
 }
 
-// Contructor that fix allowed values and set initial value of the parameter
-func newParameter[T paramType](typ reflect.Kind, val any, opt string) (*ConcreteParameter[T], error) {
+// Contructor that fix allowed values of the parameter
+func newParameter[T paramType](typ reflect.Kind, opt string, rng Range) (*ConcreteParameter[T], error) {
 	opts, err := buildOptions[T](typ, opt)
 	if err != nil {
 		return nil, err
 	}
 
-	param := &ConcreteParameter[T]{
+	if err := rng.validate(typ); err != nil {
+		return nil, err
+	}
+
+	return &ConcreteParameter[T]{
 		typ:  typ,
 		opts: opts,
-	}
+		rng:  rng,
+	}, nil
+}
 
-	err = param.SetValue(val)
-	if err != nil {
-		return nil, err
+// Set value decoded from TOML or JSON, numbers can be decoded to a type different than the parameter one,
+// e.g. TOML decodes all integers as int64 and all floats as float64
+func SetDecoded(param Parameter, val any) error {
+	err := param.SetValue(val)
+	if errors.Is(err, ErrWrongTypeVal) && isNumeric(param.Type()) {
+		switch reflect.ValueOf(val).Kind() {
+		case reflect.String:
+			// named string types like json.Number
+			return param.SetValue(fmt.Sprint(val))
+		default:
+			if _, ok := toFloat(val); ok {
+				return param.SetValue(fmt.Sprint(val))
+			}
+		}
 	}
-
-	return param, nil
+	return err
 }
 
 // Value setter
@@ -124,6 +177,11 @@ func (p *ConcreteParameter[T]) SetValue(val any) error {
 		}
 	}
 
+	valT, err := applyRange(p.rng, valT)
+	if err != nil {
+		return err
+	}
+
 	p.m.Lock()
 	p.val = valT
 	p.m.Unlock()
@@ -158,51 +216,39 @@ func (p *ConcreteParameter[T]) Opts() []string {
 	return opts
 }
 
+// Return limits of the parameter value
+func (p *ConcreteParameter[T]) Range() Range {
+	return p.rng
+}
+
+// Return independent copy of the parameter with the same type, options and current value
+func (p *ConcreteParameter[T]) Clone() Parameter {
+	p.m.RLock()
+	defer p.m.RUnlock()
+	return &ConcreteParameter[T]{
+		typ:  p.typ,
+		val:  p.val,
+		opts: p.opts,
+		rng:  p.rng,
+	}
+}
+
 // Used mainly while parsing commands received from TCP client
 // It converts received string to the corresponding value under parameter.
 func convertStringToVal[T paramType](typ reflect.Kind, val string) (*T, error) {
 	switch typ {
-	case reflect.Int:
-		if intVal, err := strconv.Atoi(val); err == nil {
-			return interface{}(&intVal).(*T), nil
-		} else if hexVal, err := strconv.ParseInt(val, 16, 64); err == nil {
-			// for hex that starts with 0x
-			hexValInt := int(hexVal)
-			return interface{}(&hexValInt).(*T), nil
-		} else if hexVal, err := strconv.ParseInt(val, 0, 64); err == nil {
-			// for hex without 0x
-			hexValInt := int(hexVal)
-			return interface{}(&hexValInt).(*T), nil
-		} else {
-			return nil, ErrWrongIntVal
-		}
-	case reflect.Int32:
-		if intVal, err := strconv.ParseInt(val, 10, 32); err == nil {
-			int32Val := int32(intVal)
-			return interface{}(&int32Val).(*T), nil
-		} else if hexVal, err := strconv.ParseInt(val, 16, 32); err == nil {
-			// for hex that starts with 0x
-			hexVal32 := int32(hexVal)
-			return interface{}(&hexVal32).(*T), nil
-		} else if hexVal, err := strconv.ParseInt(val, 0, 32); err == nil {
-			// for hex without 0x
-			hexVal32 := int32(hexVal)
-			return interface{}(&hexVal32).(*T), nil
-		} else {
-			return nil, ErrWrongIntVal
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		intVal, err := parseSigned(val, bitSize(typ))
+		if err != nil {
+			return nil, err
 		}
-	case reflect.Int64:
-		if intVal, err := strconv.ParseInt(val, 10, 64); err == nil {
-			return interface{}(&intVal).(*T), nil
-		} else if hexVal, err := strconv.ParseInt(val, 0, 64); err == nil {
-			// for hex that starts with 0x
-			return interface{}(&hexVal).(*T), nil
-		} else if hexVal, err := strconv.ParseInt(val, 16, 64); err == nil {
-			// for hex without 0x
-			return interface{}(&hexVal).(*T), nil
-		} else {
-			return nil, ErrWrongIntVal
+		return signedTo[T](intVal), nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		uintVal, err := parseUnsigned(val, bitSize(typ))
+		if err != nil {
+			return nil, err
 		}
+		return unsignedTo[T](uintVal), nil
 	case reflect.Float32:
 		if floatVal, err := strconv.ParseFloat(val, 32); err == nil {
 			float32Val := float32(floatVal)
@@ -233,34 +279,116 @@ func convertStringToVal[T paramType](typ reflect.Kind, val string) (*T, error) {
 	}
 }
 
+// Parse decimal or hex, with or without 0x, integer and check if it fits into given number of bits
+func parseSigned(val string, bits int) (int64, error) {
+	var outOfRange bool
+	for _, base := range []int{10, 16, 0} {
+		intVal, err := strconv.ParseInt(val, base, 64)
+		if err != nil {
+			outOfRange = outOfRange || errors.Is(err, strconv.ErrRange)
+			continue
+		}
+		if bits < 64 && (intVal < -1<<(bits-1) || intVal > 1<<(bits-1)-1) {
+			return 0, ErrValOutOfRange
+		}
+		return intVal, nil
+	}
+
+	if outOfRange {
+		return 0, ErrValOutOfRange
+	}
+	return 0, ErrWrongIntVal
+}
+
+// Parse decimal or hex, with or without 0x, unsigned integer and check if it fits into given number of bits
+func parseUnsigned(val string, bits int) (uint64, error) {
+	var outOfRange bool
+	for _, base := range []int{10, 16, 0} {
+		uintVal, err := strconv.ParseUint(val, base, 64)
+		if err != nil {
+			outOfRange = outOfRange || errors.Is(err, strconv.ErrRange)
+			continue
+		}
+		if bits < 64 && uintVal > 1<<bits-1 {
+			return 0, ErrValOutOfRange
+		}
+		return uintVal, nil
+	}
+
+	// negative numbers are valid integers that do not fit into unsigned type
+	if _, err := strconv.ParseInt(val, 0, 64); err == nil || outOfRange {
+		return 0, ErrValOutOfRange
+	}
+	return 0, ErrWrongUintVal
+}
+
+// Number of bits of the integer kind
+func bitSize(typ reflect.Kind) int {
+	switch typ {
+	case reflect.Int8, reflect.Uint8:
+		return 8
+	case reflect.Int16, reflect.Uint16:
+		return 16
+	case reflect.Int32, reflect.Uint32:
+		return 32
+	case reflect.Int:
+		return strconv.IntSize
+	}
+	return 64
+}
+
+func signedTo[T paramType](v int64) *T {
+	var res T
+	switch p := any(&res).(type) {
+	case *int:
+		*p = int(v)
+	case *int8:
+		*p = int8(v)
+	case *int16:
+		*p = int16(v)
+	case *int32:
+		*p = int32(v)
+	case *int64:
+		*p = v
+	}
+	return &res
+}
+
+func unsignedTo[T paramType](v uint64) *T {
+	var res T
+	switch p := any(&res).(type) {
+	case *uint8:
+		*p = uint8(v)
+	case *uint16:
+		*p = uint16(v)
+	case *uint32:
+		*p = uint32(v)
+	case *uint64:
+		*p = v
+	}
+	return &res
+}
+
 // Splits string into parts and tries to convert them according to value type under parameter.
 func buildOptions[T paramType](typ reflect.Kind, opt string) ([]T, error) {
 	opts := []T{}
 	if opt != "" {
 		splits := strings.Split(opt, "|")
 		switch typ {
-		case reflect.Int:
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 			for _, val := range splits {
-				if intVal, err := strconv.Atoi(val); err == nil {
-					opts = append(opts, interface{}(intVal).(T))
+				if intVal, err := strconv.ParseInt(val, 10, bitSize(typ)); err == nil {
+					opts = append(opts, *signedTo[T](intVal))
 				} else {
 					return nil, ErrWrongIntVal
 				}
 			}
-		case reflect.Int32:
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 			for _, val := range splits {
-				if intVal, err := strconv.ParseInt(val, 10, 32); err == nil {
-					opts = append(opts, interface{}(int32(intVal)).(T))
+				if uintVal, err := strconv.ParseUint(val, 10, bitSize(typ)); err == nil {
+					opts = append(opts, *unsignedTo[T](uintVal))
 				} else {
-					return nil, ErrWrongIntVal
-				}
-			}
-		case reflect.Int64:
-			for _, val := range splits {
-				if intVal, err := strconv.ParseInt(val, 10, 64); err == nil {
-					opts = append(opts, interface{}(intVal).(T))
-				} else {
-					return nil, ErrWrongIntVal
+					return nil, ErrWrongUintVal
 				}
 			}
 		case reflect.Float32:
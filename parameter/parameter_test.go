@@ -24,13 +24,29 @@ func TestSetValue(t *testing.T) {
 		{"string param wrong int value", "string", "init", 50, "", "init", ErrWrongTypeVal},
 		{"string param wrong bool value", "string", "init", false, "", "init", ErrWrongTypeVal},
 
-		{"int16 param", "int16", 20, 50, "", 50, nil},
-		{"int16 param with opts", "int16", 60, 50, "50|60", 50, nil},
-		{"int16 param string value", "int16", 20, "50", "", 50, nil},
-		{"int16 param wrong bool value", "int16", 50, false, "", 50, ErrWrongTypeVal},
-		{"int16 param wrong string value", "int16", 30, "test", "", 30, ErrWrongIntVal},
-		{"int16 param wrong float value", "int16", 30, 50.0, "", 30, ErrWrongTypeVal},
-		{"int16 param wrong int value", "int16", 30, int64(50), "", 30, ErrWrongTypeVal},
+		{"int16 param", "int16", int16(20), int16(50), "", int16(50), nil},
+		{"int16 param with opts", "int16", int16(60), int16(50), "50|60", int16(50), nil},
+		{"int16 param string value", "int16", int16(20), "50", "", int16(50), nil},
+		{"int16 param wrong bool value", "int16", int16(50), false, "", int16(50), ErrWrongTypeVal},
+		{"int16 param wrong string value", "int16", int16(30), "test", "", int16(30), ErrWrongIntVal},
+		{"int16 param wrong float value", "int16", int16(30), 50.0, "", int16(30), ErrWrongTypeVal},
+		{"int16 param wrong int value", "int16", int16(30), int64(50), "", int16(30), ErrWrongTypeVal},
+		{"int16 param overflow", "int16", int16(30), "40000", "", int16(30), ErrValOutOfRange},
+
+		{"int8 param", "int8", int8(1), "-128", "", int8(-128), nil},
+		{"int8 param overflow", "int8", int8(1), "128", "", int8(1), ErrValOutOfRange},
+
+		{"uint8 param", "uint8", uint8(1), "255", "", uint8(255), nil},
+		{"byte param hex value", "byte", uint8(1), "0x1F", "", uint8(31), nil},
+		{"uint8 param overflow", "uint8", uint8(1), "256", "", uint8(1), ErrValOutOfRange},
+		{"uint8 param negative value", "uint8", uint8(1), "-1", "", uint8(1), ErrValOutOfRange},
+		{"uint16 param hex value", "uint16", uint16(0), "FFFF", "", uint16(0xFFFF), nil},
+		{"uint16 param with opts", "uint16", uint16(1), uint16(2), "1|2", uint16(2), nil},
+		{"uint16 param wrong string value", "uint16", uint16(1), "test", "", uint16(1), ErrWrongUintVal},
+		{"uint32 param", "uint32", uint32(1), "4294967295", "", uint32(4294967295), nil},
+		{"uint32 param wrong int value", "uint32", uint32(1), 5, "", uint32(1), ErrWrongTypeVal},
+		{"uint64 param", "uint64", uint64(1), "18446744073709551615", "", uint64(18446744073709551615), nil},
+		{"uint64 param overflow", "uint", uint64(1), "18446744073709551616", "", uint64(1), ErrValOutOfRange},
 
 		{"int param", "int", int64(20), int64(50), "", int64(50), nil},
 		{"int param with opts", "int", int64(60), int64(50), "50|60", int64(50), nil},
@@ -91,6 +107,61 @@ func TestSetValue(t *testing.T) {
 	}
 }
 
+func TestNewWithDecodedValue(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		typ    string
+		val    any
+		exp    any
+		expErr error
+	}{
+		{"uint8 from int64", "uint8", int64(200), uint8(200), nil},
+		{"uint8 overflow", "uint8", int64(300), nil, ErrValOutOfRange},
+		{"int16 from int64", "int16", int64(-300), int16(-300), nil},
+		{"int32 from int64", "int32", int64(5), int32(5), nil},
+		{"float32 from int64", "float32", int64(5), float32(5), nil},
+		{"int from float64", "int", 1.5, nil, ErrWrongIntVal},
+		{"string from int64", "string", int64(5), nil, ErrWrongTypeVal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			param, err := New(tt.val, "", tt.typ)
+			if !errors.Is(err, tt.expErr) {
+				t.Fatalf("%s: exp error: %v got %v\n", tt.name, tt.expErr, err)
+			}
+			if err == nil && param.Value() != tt.exp {
+				t.Errorf("%s: exp value: %v got %v\n", tt.name, tt.exp, param.Value())
+			}
+		})
+	}
+}
+
+func TestClone(t *testing.T) {
+	t.Parallel()
+	param, err := New("one", "one|two", "string")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone := param.Clone()
+	if clone.Value() != "one" {
+		t.Errorf("exp value: one got %v\n", clone.Value())
+	}
+
+	if err := clone.SetValue("two"); err != nil {
+		t.Fatal(err)
+	}
+	if param.Value() != "one" {
+		t.Errorf("original parameter changed with clone, got %v\n", param.Value())
+	}
+
+	if err := clone.SetValue("three"); !errors.Is(err, ErrValNotAllowed) {
+		t.Errorf("exp error: %v got %v\n", ErrValNotAllowed, err)
+	}
+}
+
 func TestConvertStringToVal(t *testing.T) {
 	t.Parallel()
 
@@ -245,3 +316,158 @@ func TestBuildOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestRange(t *testing.T) {
+	t.Parallel()
+	lo, hi := 0.0, 300.0
+	limits := Range{Min: &lo, Max: &hi}
+	// 2^53 + 1, cannot be kept in float64
+	big := int64(9007199254740993)
+	tests := []struct {
+		name   string
+		typ    string
+		rng    Range
+		val    any
+		exp    any
+		expErr error
+	}{
+		{"int in range", "int", limits, "120", int64(120), nil},
+		{"int on limit", "int", limits, "300", int64(300), nil},
+		{"int above max", "int", limits, "301", int64(10), ErrValOutOfRange},
+		{"int below min", "int16", limits, "-1", int16(10), ErrValOutOfRange},
+		{"int clamped", "int32", Range{Min: &lo, Max: &hi, Clamp: true}, "500", int32(300), nil},
+		{"int rounded to step", "int", Range{Step: 5}, "12", int64(10), nil},
+		{"float in range", "float64", limits, "120.5", 120.5, nil},
+		{"float above max", "float64", limits, "300.1", 10.0, ErrValOutOfRange},
+		{"float clamped", "float64", Range{Min: &lo, Max: &hi, Clamp: true}, "-20", 0.0, nil},
+		{"float rounded to step", "float64", Range{Min: &lo, Step: 0.1}, "0.34", 0.3, nil},
+		{"float32 rounded to step", "float32", Range{Step: 0.5}, "1.3", float32(1.5), nil},
+		{"float rounded to max", "float64", Range{Max: &hi, Step: 0.1}, "300.04", 300.0, nil},
+		{"float rounded above max", "float64", Range{Max: &hi, Step: 0.1}, "300.06", 10.0, ErrValOutOfRange},
+		{"int64 on exact max", "int64", Range{IntMax: &big}, "9007199254740993", int64(9007199254740993), nil},
+		{"int64 above exact max", "int64", Range{IntMax: &big}, "9007199254740994", int64(10), ErrValOutOfRange},
+		{"uint64 clamped to exact max", "uint64", Range{IntMax: &big, Clamp: true}, "18446744073709551615", uint64(9007199254740993), nil},
+		{"uint64 rounded to step", "uint64", Range{Step: 2}, "9007199254740993", uint64(9007199254740994), nil},
+		{"int rounded to step below zero", "int", Range{Step: 5}, "-13", int64(-15), nil},
+		{"int rounded to step outside type", "int8", Range{Step: 10}, "127", int8(10), ErrValOutOfRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			param, err := NewWithRange("10", "", tt.typ, tt.rng)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = param.SetValue(tt.val)
+			if !errors.Is(err, tt.expErr) {
+				t.Errorf("%s: exp error: %v got %v\n", tt.name, tt.expErr, err)
+			}
+
+			if param.Value() != tt.exp {
+				t.Errorf("%s: exp value: %v got %v\n", tt.name, tt.exp, param.Value())
+			}
+		})
+	}
+}
+
+func TestWrongRange(t *testing.T) {
+	t.Parallel()
+	lo, hi := 10.0, 0.0
+	tests := []struct {
+		name   string
+		typ    string
+		val    any
+		rng    Range
+		expErr error
+	}{
+		{"min above max", "int", int64(5), Range{Min: &lo, Max: &hi}, ErrWrongRange},
+		{"negative step", "float64", 5.0, Range{Step: -1}, ErrWrongRange},
+		{"string param", "string", "test", Range{Max: &hi}, ErrRangeNotNumeric},
+		{"bool param", "bool", true, Range{Step: 1}, ErrRangeNotNumeric},
+		{"initial value out of range", "float64", 5.0, Range{Min: &lo}, ErrValOutOfRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewWithRange(tt.val, "", tt.typ, tt.rng)
+			if !errors.Is(err, tt.expErr) {
+				t.Errorf("%s: exp error: %v got %v\n", tt.name, tt.expErr, err)
+			}
+		})
+	}
+}
+
+func TestArray(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		typ    string
+		opt    string
+		shape  Shape
+		val    any
+		exp    string
+		expErr error
+	}{
+		{"string value", "float64", "", Shape{}, "1.5, 2.5", "1.5,2.5", nil},
+		{"list value", "int", "", Shape{}, []any{int64(1), int64(2)}, "1,2", nil},
+		{"typed list value", "uint8", "", Shape{}, []uint8{1, 2}, "1,2", nil},
+		{"array value", "float64", "", Shape{}, [2]float64{1, 2.5}, "1,2.5", nil},
+		{"separator", "string", "", Shape{Sep: ";"}, "a;b", "a;b", nil},
+		{"empty value", "string", "", Shape{}, "", "", nil},
+		{"fixed length", "int", "", Shape{Len: 2}, "1,2", "1,2", nil},
+		{"wrong fixed length", "int", "", Shape{Len: 3}, "1,2", "", ErrWrongLength},
+		{"max length", "int", "", Shape{MaxLen: 2}, "1,2,3", "", ErrWrongLength},
+		{"element with opts", "string", "ON|OFF", Shape{}, "ON,OFF", "ON,OFF", nil},
+		{"element outside opts", "string", "ON|OFF", Shape{}, "ON,on", "", ErrValNotAllowed},
+		{"wrong element", "int", "", Shape{}, "1,x", "", ErrWrongIntVal},
+		{"scalar value", "int", "", Shape{}, 5, "", ErrWrongTypeVal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			param, err := NewArray(nil, tt.opt, tt.typ, Range{}, tt.shape)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = param.SetValue(tt.val)
+			if !errors.Is(err, tt.expErr) {
+				t.Errorf("%s: exp error: %v got %v\n", tt.name, tt.expErr, err)
+			}
+			if err == nil && param.String() != tt.exp {
+				t.Errorf("%s: exp value: %v got %v\n", tt.name, tt.exp, param.String())
+			}
+		})
+	}
+}
+
+func TestArrayIndex(t *testing.T) {
+	t.Parallel()
+	param, err := NewArray([]any{int64(1), int64(2)}, "", "int16", Range{}, Shape{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := param.SetIndex(1, "7"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := param.Index(1); err != nil || v != int16(7) {
+		t.Errorf("exp element 7 got %v %v", v, err)
+	}
+	if _, err := param.Index(2); !errors.Is(err, ErrIndexInvalid) {
+		t.Errorf("exp error: %v got %v\n", ErrIndexInvalid, err)
+	}
+	if err := param.SetIndex(-1, "7"); !errors.Is(err, ErrIndexInvalid) {
+		t.Errorf("exp error: %v got %v\n", ErrIndexInvalid, err)
+	}
+
+	clone := param.Clone().(Indexed)
+	clone.SetIndex(0, "5")
+	if v, _ := param.Index(0); v != int16(1) {
+		t.Errorf("original parameter changed with clone, got %v\n", v)
+	}
+
+	if _, err := NewArray(nil, "", "int", Range{}, Shape{Len: 2, MaxLen: 3}); !errors.Is(err, ErrWrongShape) {
+		t.Errorf("exp error: %v got %v\n", ErrWrongShape, err)
+	}
+}
@@ -0,0 +1,236 @@
+package parameter
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrValOutOfRange   = errors.New("value outside range - ignoring set")
+	ErrWrongRange      = errors.New("invalid range of values")
+	ErrRangeNotNumeric = errors.New("range can be set only for numeric parameters")
+)
+
+// Limits of numeric parameter value, zero value means no limits
+type Range struct {
+	Min *float64
+	Max *float64
+	// Resolution of the value, set values are rounded to the nearest multiple of it counted from Min
+	Step float64
+	// Values outside Min and Max are limited to them instead of being rejected
+	Clamp bool
+	// Exact limits of integer parameters, float64 cannot keep integers above 2^53
+	IntMin *int64
+	IntMax *int64
+}
+
+// Check if the range limits the value anyhow
+func (r Range) empty() bool {
+	return r.Min == nil && r.Max == nil && r.IntMin == nil && r.IntMax == nil && r.Step == 0
+}
+
+// Check if the range can be used for parameter of the given type
+func (r Range) validate(typ reflect.Kind) error {
+	if r.empty() {
+		return nil
+	}
+
+	if !isNumeric(typ) {
+		return ErrRangeNotNumeric
+	}
+
+	if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+		return ErrWrongRange
+	}
+	if r.IntMin != nil && r.IntMax != nil && *r.IntMin > *r.IntMax {
+		return ErrWrongRange
+	}
+	if r.Step < 0 || math.IsNaN(r.Step) || math.IsInf(r.Step, 0) {
+		return ErrWrongRange
+	}
+	return nil
+}
+
+// Round value to the step and check it against limits, returns value that should be set
+func applyRange[T paramType](r Range, val T) (T, error) {
+	if r.empty() {
+		return val, nil
+	}
+
+	if n, ok := toBig(val); ok {
+		return applyIntRange(r, n, val)
+	}
+
+	f, ok := toFloat(val)
+	if !ok {
+		return val, nil
+	}
+
+	if r.Step > 0 {
+		var base float64
+		if r.Min != nil {
+			base = *r.Min
+		}
+		f = base + math.Round((f-base)/r.Step)*r.Step
+		// get rid of floating point noise, e.g. 0.30000000000000004
+		f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'f', decimals(r.Step), 64), 64)
+	}
+
+	if r.Min != nil && f < *r.Min {
+		if !r.Clamp {
+			return val, ErrValOutOfRange
+		}
+		f = *r.Min
+	}
+	if r.Max != nil && f > *r.Max {
+		if !r.Clamp {
+			return val, ErrValOutOfRange
+		}
+		f = *r.Max
+	}
+
+	return fromFloat[T](f), nil
+}
+
+// Same as applyRange but without going through float64, so big integers keep their precision
+func applyIntRange[T paramType](r Range, n *big.Int, val T) (T, error) {
+	lo, hi := r.intLimits()
+
+	if step := intStep(r.Step); step != nil {
+		base := new(big.Int)
+		if lo != nil {
+			base.Set(lo)
+		}
+		// round half away from zero like math.Round
+		d := new(big.Int).Sub(n, base)
+		q, m := new(big.Int).QuoRem(d, step, new(big.Int))
+		if m.Abs(m).Lsh(m, 1).Cmp(step) >= 0 {
+			q.Add(q, big.NewInt(int64(d.Sign())))
+		}
+		n = q.Mul(q, step).Add(q, base)
+	}
+
+	if lo != nil && n.Cmp(lo) < 0 {
+		if !r.Clamp {
+			return val, ErrValOutOfRange
+		}
+		n = lo
+	}
+	if hi != nil && n.Cmp(hi) > 0 {
+		if !r.Clamp {
+			return val, ErrValOutOfRange
+		}
+		n = hi
+	}
+
+	res, ok := fromBig[T](n)
+	if !ok {
+		// rounding to step went outside of the type
+		return val, ErrValOutOfRange
+	}
+	return res, nil
+}
+
+// Limits for integer values, float limits are rounded towards the inside of the range
+func (r Range) intLimits() (lo, hi *big.Int) {
+	if r.IntMin != nil {
+		lo = big.NewInt(*r.IntMin)
+	} else if r.Min != nil {
+		lo, _ = big.NewFloat(math.Ceil(*r.Min)).Int(nil)
+	}
+	if r.IntMax != nil {
+		hi = big.NewInt(*r.IntMax)
+	} else if r.Max != nil {
+		hi, _ = big.NewFloat(math.Floor(*r.Max)).Int(nil)
+	}
+	return lo, hi
+}
+
+// Step for integer values, fractional steps are rounded and cannot be lower than 1
+func intStep(step float64) *big.Int {
+	if step <= 0 {
+		return nil
+	}
+	n, _ := big.NewFloat(math.Max(math.Round(step), 1)).Int(nil)
+	return n
+}
+
+// Number of decimal places of the step
+func decimals(step float64) int {
+	s := strconv.FormatFloat(step, 'f', -1, 64)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
+
+// Check if parameter of the kind keeps numbers
+func isNumeric(typ reflect.Kind) bool {
+	switch typ {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func toFloat(val any) (float64, bool) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func toBig(val any) (*big.Int, bool) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(rv.Uint()), true
+	}
+	return nil, false
+}
+
+// Convert integer to the parameter type, false if it does not fit in it
+func fromBig[T paramType](n *big.Int) (T, bool) {
+	var res T
+	rv := reflect.ValueOf(&res).Elem()
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !n.IsInt64() || rv.OverflowInt(n.Int64()) {
+			return res, false
+		}
+		rv.SetInt(n.Int64())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !n.IsUint64() || rv.OverflowUint(n.Uint64()) {
+			return res, false
+		}
+		rv.SetUint(n.Uint64())
+	default:
+		return res, false
+	}
+	return res, true
+}
+
+func fromFloat[T paramType](f float64) T {
+	var res T
+	switch p := any(&res).(type) {
+	case *float32:
+		*p = float32(f)
+	case *float64:
+		*p = f
+	}
+	return res
+}
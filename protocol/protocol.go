@@ -12,8 +12,15 @@ var (
 
 type Protocol interface {
 	Decode(data []byte) ([]Transaction, error)
+	// Decode complete requests, rest is the beginning of incomplete request that should be
+	// passed again with the following data. When flush is set all data is decoded.
+	DecodeFrames(data []byte, flush bool) (txs []Transaction, rest []byte, err error)
 	Encode(txs []Transaction) ([]byte, error)
+	// Encode transactions into parts sent separately, e.g. lines of multi-line responses
+	EncodeParts(txs []Transaction) ([][]byte, error)
 	Trigger(cmdName string) Transaction
+	// Change message sent in reply to mismatched requests
+	SetMismatch(msg []byte)
 }
 
 type TransactionType int
@@ -25,6 +32,8 @@ const (
 	TxSetParam
 
 	TxMismatch
+	// Request handled by the device itself, e.g. common command of the device profile
+	TxReply
 )
 
 func (t TransactionType) String() string {
@@ -33,11 +42,33 @@ func (t TransactionType) String() string {
 		return "GetParam"
 	case TxSetParam:
 		return "SetParam"
+	case TxMismatch:
+		return "Mismatch"
+	case TxReply:
+		return "Reply"
 	default:
 		return "Unknown"
 	}
 }
 
+// Kinds of failures of setting the parameter, commands can reply to each of them differently
+const (
+	// Value is not one of the allowed ones or cannot be set for other reason
+	FailInvalid = "invalid"
+	// Value is outside limits of the parameter
+	FailOutOfRange = "out_of_range"
+	// Value cannot be converted to the type of the parameter
+	FailTypeError = "type_error"
+)
+
+// Failure of setting the parameter to the value received in the request
+type Failure struct {
+	Kind  string
+	Param string
+	// Value as received from the client
+	Value any
+}
+
 // TxPayload holds name : value of the parameter
 //type TxPayload map[string]any
 
@@ -45,4 +76,16 @@ type Transaction struct {
 	Typ         TransactionType
 	CommandName string
 	Payload     map[string]any
+	// Received request without terminator
+	Raw []byte
+	// Terminator the request ended with, nil when it was not terminated
+	Term []byte
+	// Reply sent instead of mismatch message, e.g. error reported by the device
+	Reply []byte
+	// Set of the parameter that failed, nil when the request did not fail to set
+	Failure *Failure
+	// Following transaction comes from the same compound request, their replies are joined
+	More bool
+	// Indexes captured from the request, e.g. #ch in VSET {%d:#ch},{%f:voltage[#ch]}
+	Indexes map[string]int
 }
@@ -0,0 +1,675 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrWrongExprSyntax = errors.New("illegal syntax in expression")
+	ErrExprEval        = errors.New("could not evaluate expression")
+)
+
+// Compiled expression of the response, e.g. map(enable, true:"ON", false:"OFF") or current*0.1
+type node interface {
+	eval(vars func(name string) any) (any, error)
+}
+
+type exprLiteral struct {
+	val any
+}
+
+// Value of the parameter or index captured from the request
+type exprRef struct {
+	name string
+}
+
+type exprUnary struct {
+	op string
+	x  node
+}
+
+type exprBinary struct {
+	op   string
+	x, y node
+}
+
+// Call of the builtin function, arguments of map are the value, keys and values of pairs and the default
+type exprCall struct {
+	fn   string
+	args []node
+	keys []node
+	def  node
+}
+
+// Number of arguments of builtin functions, -1 for any number but at least one
+var builtins = map[string]int{
+	"if":   3,
+	"map":  -1,
+	"bits": -1,
+	"min":  -1,
+	"max":  -1,
+	"abs":  1,
+}
+
+// Binary operators from the lowest precedence
+var precedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<=", ">=", "<", ">"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+type token struct {
+	// one of number, string, ident or op
+	kind string
+	val  string
+}
+
+// Compile expression, it fails when the syntax is wrong or unknown function is called
+func compileExpr(input string) (node, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	n, err := p.parse(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %s in %s", ErrWrongExprSyntax, p.tokens[p.pos].val, input)
+	}
+	return n, nil
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(input); {
+		ch := input[i]
+		switch {
+		case ch == ' ' || ch == '\t':
+			i++
+		case ch == '"':
+			quoted, err := strconv.QuotedPrefix(input[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%w: unterminated string in %s", ErrWrongExprSyntax, input)
+			}
+			s, _ := strconv.Unquote(quoted)
+			tokens = append(tokens, token{"string", s})
+			i += len(quoted)
+		case ch == '\'':
+			end := strings.IndexByte(input[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated string in %s", ErrWrongExprSyntax, input)
+			}
+			tokens = append(tokens, token{"string", input[i+1 : i+1+end]})
+			i += end + 2
+		case isNumber(rune(ch)) || (ch == '.' && i+1 < len(input) && isNumber(rune(input[i+1]))):
+			j := i
+			for j < len(input) && (isAlphaNumeric(rune(input[j])) || input[j] == '.' ||
+				// sign of the exponent, e.g. 1e-3
+				((input[j] == '-' || input[j] == '+') && (input[j-1] == 'e' || input[j-1] == 'E') && !strings.HasPrefix(strings.ToLower(input[i:]), "0x"))) {
+				j++
+			}
+			tokens = append(tokens, token{"number", input[i:j]})
+			i = j
+		case isLetter(rune(ch)) || ch == '_' || ch == '#':
+			j := i
+			for j < len(input) && (isAlphaNumeric(rune(input[j])) || input[j] == '_' || input[j] == '#') {
+				j++
+			}
+			// reference to element of array parameter, e.g. voltage[#ch]
+			if j < len(input) && input[j] == '[' {
+				end := strings.IndexByte(input[j:], ']')
+				if end < 0 {
+					return nil, fmt.Errorf("%w: unterminated index in %s", ErrWrongExprSyntax, input)
+				}
+				j += end + 1
+			}
+			tokens = append(tokens, token{"ident", input[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"||", "&&", "==", "!=", "<=", ">=", "<<", ">>"} {
+				if strings.HasPrefix(input[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" && strings.IndexByte("<>|^&+-*/%!~(),:", ch) >= 0 {
+				op = string(ch)
+			}
+			if op == "" {
+				return nil, fmt.Errorf("%w: unexpected %q in %s", ErrWrongExprSyntax, ch, input)
+			}
+			tokens = append(tokens, token{"op", op})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+// Check if the next token is the operator and consume it
+func (p *exprParser) accept(op string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == "op" && p.tokens[p.pos].val == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.accept(op) {
+		return fmt.Errorf("%w: expected %s", ErrWrongExprSyntax, op)
+	}
+	return nil
+}
+
+// Parse binary operators of the given precedence level and higher ones
+func (p *exprParser) parse(level int) (node, error) {
+	if level == len(precedence) {
+		return p.unary()
+	}
+	x, err := p.parse(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		matched := ""
+		for _, op := range precedence[level] {
+			if p.accept(op) {
+				matched = op
+				break
+			}
+		}
+		if matched == "" {
+			return x, nil
+		}
+		y, err := p.parse(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = exprBinary{matched, x, y}
+	}
+}
+
+func (p *exprParser) unary() (node, error) {
+	for _, op := range []string{"-", "!", "~"} {
+		if p.accept(op) {
+			x, err := p.unary()
+			if err != nil {
+				return nil, err
+			}
+			return exprUnary{op, x}, nil
+		}
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (node, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected end", ErrWrongExprSyntax)
+	}
+	if p.accept("(") {
+		x, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	}
+
+	t := p.tokens[p.pos]
+	p.pos++
+	switch t.kind {
+	case "string":
+		return exprLiteral{t.val}, nil
+	case "number":
+		if v, err := strconv.ParseInt(t.val, 0, 64); err == nil {
+			return exprLiteral{v}, nil
+		}
+		if v, err := strconv.ParseFloat(t.val, 64); err == nil {
+			return exprLiteral{v}, nil
+		}
+		return nil, fmt.Errorf("%w: wrong number %s", ErrWrongExprSyntax, t.val)
+	case "ident":
+		switch t.val {
+		case "true":
+			return exprLiteral{true}, nil
+		case "false":
+			return exprLiteral{false}, nil
+		}
+		if p.accept("(") {
+			return p.call(t.val)
+		}
+		return exprRef{t.val}, nil
+	}
+	return nil, fmt.Errorf("%w: unexpected %s", ErrWrongExprSyntax, t.val)
+}
+
+// Parse arguments of the function, keys of map pairs are separated from values with colon
+func (p *exprParser) call(fn string) (node, error) {
+	n, exists := builtins[fn]
+	if !exists {
+		return nil, fmt.Errorf("%w: unknown function %s", ErrWrongExprSyntax, fn)
+	}
+
+	c := exprCall{fn: fn}
+	for !p.accept(")") {
+		if len(c.args)+len(c.keys) > 0 || c.def != nil {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		if c.def != nil {
+			return nil, fmt.Errorf("%w: default value of %s has to be the last one", ErrWrongExprSyntax, fn)
+		}
+		x, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case fn == "map" && len(c.args) > 0 && p.accept(":"):
+			val, err := p.parse(0)
+			if err != nil {
+				return nil, err
+			}
+			c.keys = append(c.keys, x)
+			c.args = append(c.args, val)
+		case fn == "map" && len(c.args) > 0:
+			c.def = x
+		default:
+			c.args = append(c.args, x)
+		}
+	}
+
+	switch {
+	case fn == "map" && len(c.keys) == 0:
+		return nil, fmt.Errorf("%w: map needs value and at least one key:value pair", ErrWrongExprSyntax)
+	case n > 0 && len(c.args) != n:
+		return nil, fmt.Errorf("%w: %s takes %d arguments", ErrWrongExprSyntax, fn, n)
+	case len(c.args) == 0:
+		return nil, fmt.Errorf("%w: %s needs arguments", ErrWrongExprSyntax, fn)
+	}
+	return c, nil
+}
+
+// Names of parameters and indexes used in the expression
+func exprRefs(n node) []string {
+	switch n := n.(type) {
+	case exprRef:
+		return []string{n.name}
+	case exprUnary:
+		return exprRefs(n.x)
+	case exprBinary:
+		return append(exprRefs(n.x), exprRefs(n.y)...)
+	case exprCall:
+		var names []string
+		for _, x := range append(append(n.args, n.keys...), n.def) {
+			if x != nil {
+				names = append(names, exprRefs(x)...)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+func (l exprLiteral) eval(func(string) any) (any, error) {
+	return l.val, nil
+}
+
+func (r exprRef) eval(vars func(string) any) (any, error) {
+	v := normalize(vars(r.name))
+	if v == nil {
+		return nil, fmt.Errorf("%w: no value of %s", ErrExprEval, r.name)
+	}
+	return v, nil
+}
+
+func (u exprUnary) eval(vars func(string) any) (any, error) {
+	x, err := u.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch u.op {
+	case "!":
+		return !truthy(x), nil
+	case "~":
+		i, err := toInt(x)
+		return ^i, err
+	}
+	switch x := x.(type) {
+	case int64:
+		return -x, nil
+	case float64:
+		return -x, nil
+	}
+	return nil, fmt.Errorf("%w: cannot negate %v", ErrExprEval, x)
+}
+
+func (b exprBinary) eval(vars func(string) any) (any, error) {
+	x, err := b.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	// logical operators evaluate the right side only when needed
+	switch b.op {
+	case "&&":
+		if !truthy(x) {
+			return false, nil
+		}
+	case "||":
+		if truthy(x) {
+			return true, nil
+		}
+	}
+	y, err := b.y.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch b.op {
+	case "&&", "||":
+		return truthy(y), nil
+	case "==":
+		return equal(x, y), nil
+	case "!=":
+		return !equal(x, y), nil
+	case "<", "<=", ">", ">=":
+		return compare(b.op, x, y)
+	case "|", "^", "&", "<<", ">>", "%":
+		return intOp(b.op, x, y)
+	}
+	return arith(b.op, x, y)
+}
+
+func (c exprCall) eval(vars func(string) any) (any, error) {
+	args := make([]any, len(c.args))
+	// only the chosen branch of if is evaluated
+	if c.fn != "if" {
+		for i, a := range c.args {
+			v, err := a.eval(vars)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+	}
+
+	switch c.fn {
+	case "if":
+		cond, err := c.args[0].eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(cond) {
+			return c.args[1].eval(vars)
+		}
+		return c.args[2].eval(vars)
+	case "map":
+		// the first argument is the mapped value
+		for i, k := range c.keys {
+			key, err := k.eval(vars)
+			if err != nil {
+				return nil, err
+			}
+			if equal(args[0], key) {
+				return args[i+1], nil
+			}
+		}
+		if c.def == nil {
+			return nil, fmt.Errorf("%w: %v is not mapped", ErrExprEval, args[0])
+		}
+		return c.def.eval(vars)
+	case "bits":
+		// the first argument is the least significant bit
+		var word int64
+		for i, a := range args {
+			if truthy(a) {
+				word |= 1 << i
+			}
+		}
+		return word, nil
+	case "abs":
+		switch x := args[0].(type) {
+		case int64:
+			if x < 0 {
+				return -x, nil
+			}
+			return x, nil
+		case float64:
+			return math.Abs(x), nil
+		}
+		return nil, fmt.Errorf("%w: abs of %v", ErrExprEval, args[0])
+	}
+
+	// min or max
+	out := args[0]
+	for _, a := range args[1:] {
+		less, err := compare("<", a, out)
+		if err != nil {
+			return nil, err
+		}
+		if less == (c.fn == "min") {
+			out = a
+		}
+	}
+	return out, nil
+}
+
+// Convert value of the parameter to int64, float64, bool or string
+func normalize(v any) any {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case float32:
+		return float64(v)
+	}
+	return v
+}
+
+func truthy(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	}
+	return v != nil
+}
+
+// Bools are 0 or 1 in arithmetic and bit operations, e.g. ready | fault<<1
+func toInt(v any) (int64, error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("%w: %v is not an integer", ErrExprEval, v)
+}
+
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64, bool:
+		i, _ := toInt(v)
+		return float64(i), true
+	}
+	return 0, false
+}
+
+func equal(x, y any) bool {
+	if xf, ok := toFloat(x); ok {
+		if yf, ok := toFloat(y); ok {
+			_, xBool := x.(bool)
+			_, yBool := y.(bool)
+			return xBool == yBool && xf == yf
+		}
+	}
+	return x == y
+}
+
+func compare(op string, x, y any) (bool, error) {
+	var cmp int
+	xs, xString := x.(string)
+	ys, yString := y.(string)
+	xf, xNum := toFloat(x)
+	yf, yNum := toFloat(y)
+	switch {
+	case xString && yString:
+		cmp = strings.Compare(xs, ys)
+	case xNum && yNum:
+		switch {
+		case xf < yf:
+			cmp = -1
+		case xf > yf:
+			cmp = 1
+		}
+	default:
+		return false, fmt.Errorf("%w: cannot compare %v and %v", ErrExprEval, x, y)
+	}
+
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+	return cmp >= 0, nil
+}
+
+func intOp(op string, x, y any) (any, error) {
+	a, err := toInt(x)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toInt(y)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "|":
+		return a | b, nil
+	case "^":
+		return a ^ b, nil
+	case "&":
+		return a & b, nil
+	case "<<", ">>":
+		// negative shift panics, values are at most 64 bits
+		if b < 0 || b >= 64 {
+			return nil, fmt.Errorf("%w: shift by %d", ErrExprEval, b)
+		}
+		if op == "<<" {
+			return a << b, nil
+		}
+		return a >> b, nil
+	}
+	if b == 0 {
+		return nil, fmt.Errorf("%w: division by zero", ErrExprEval)
+	}
+	return a % b, nil
+}
+
+// Arithmetic on integers stays integer unless one of values is a float, + joins strings
+func arith(op string, x, y any) (any, error) {
+	if xs, ok := x.(string); ok && op == "+" {
+		return xs + fmt.Sprint(y), nil
+	}
+	if _, isFloat := x.(float64); !isFloat {
+		if _, isFloat := y.(float64); !isFloat {
+			a, errX := toInt(x)
+			b, errY := toInt(y)
+			if errX == nil && errY == nil {
+				switch op {
+				case "+":
+					return a + b, nil
+				case "-":
+					return a - b, nil
+				case "*":
+					return a * b, nil
+				}
+				if b == 0 {
+					return nil, fmt.Errorf("%w: division by zero", ErrExprEval)
+				}
+				return a / b, nil
+			}
+		}
+	}
+
+	a, okX := toFloat(x)
+	b, okY := toFloat(y)
+	if !okX || !okY {
+		return nil, fmt.Errorf("%w: %v %s %v", ErrExprEval, x, op, y)
+	}
+	switch op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	}
+	return a / b, nil
+}
+
+// Convert result of the expression to the type expected by the verb of the placeholder
+func coerce(f format, v any) any {
+	switch f.verb {
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		if fl, ok := toFloat(v); ok {
+			return fl
+		}
+	case 'd', 'x', 'X', 'o', 'O', 'b', 'c', 'r':
+		if fl, ok := v.(float64); ok {
+			return int64(math.Round(fl))
+		}
+		if i, err := toInt(v); err == nil {
+			return i
+		}
+	case 's', 'q', '[':
+		return fmt.Sprint(v)
+	}
+	return v
+}
@@ -0,0 +1,203 @@
+package stream
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/e9ctrl/vd/command"
+	"github.com/e9ctrl/vd/protocol"
+	"github.com/e9ctrl/vd/vdfile"
+)
+
+func TestExpr(t *testing.T) {
+	t.Parallel()
+	vars := map[string]any{
+		"enable":  true,
+		"fault":   false,
+		"ready":   true,
+		"current": int16(120),
+		"psi":     float32(2.5),
+		"mode":    "NORM",
+		"volt[1]": 3.3,
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		exp  any
+	}{
+		{"map bool", `map(enable, true:"ON", false:"OFF")`, "ON"},
+		{"map string with default", `map(mode, "SING":1, "NORM":2, 0)`, int64(2)},
+		{"map default", `map(current, 1:"one", "many")`, "many"},
+		{"if", `if(current > 100, "HIGH", "OK")`, "HIGH"},
+		{"if not evaluated branch", `if(enable, 1, missing)`, int64(1)},
+		{"integer arithmetic", "current * 2 + 1", int64(241)},
+		{"float arithmetic", "current * 0.5", 60.0},
+		{"float parameter", "psi * 2", 5.0},
+		{"integer division", "current / 7", int64(17)},
+		{"parentheses", "(current + 30) % 100", int64(50)},
+		{"bit packing with operators", "ready | fault<<1 | enable<<2", int64(5)},
+		{"bit packing with bits", "bits(ready, fault, enable)", int64(5)},
+		{"bit mask", "current & 0x0F", int64(8)},
+		{"logical", "enable && !fault", true},
+		{"comparison of strings", `mode == "NORM"`, true},
+		{"comparison of mixed numbers", "psi < current", true},
+		{"string concatenation", `mode + "-" + current`, "NORM-120"},
+		{"array element", "volt[1] * 10", 33.0},
+		{"min max", "max(min(current, 200), 150)", int64(150)},
+		{"abs", "abs(-psi)", 2.5},
+		{"unary minus", "-current", int64(-120)},
+	}
+
+	for _, tt := range tests {
+		n, err := compileExpr(tt.expr)
+		if err != nil {
+			t.Errorf("%s: compile failed: %v", tt.name, err)
+			continue
+		}
+		got, err := n.eval(func(name string) any { return vars[name] })
+		if err != nil {
+			t.Errorf("%s: eval failed: %v", tt.name, err)
+			continue
+		}
+		if got != tt.exp {
+			t.Errorf("%s: exp %v (%T) got %v (%T)", tt.name, tt.exp, tt.exp, got, got)
+		}
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	t.Parallel()
+	syntax := []string{
+		`map(enable)`,
+		`map(enable, true:"ON", "?", false:"OFF")`,
+		`if(enable, 1)`,
+		`unknown(enable)`,
+		`current +`,
+		`(current`,
+		`"unterminated`,
+		`current $ 2`,
+	}
+	for _, expr := range syntax {
+		if _, err := compileExpr(expr); !errors.Is(err, ErrWrongExprSyntax) {
+			t.Errorf("%s: exp error %v got %v", expr, ErrWrongExprSyntax, err)
+		}
+	}
+
+	eval := []string{
+		`missing + 1`,
+		`current / 0`,
+		`map(current, 1:"one")`,
+		`mode < 1`,
+		`1 << -current`,
+		`current >> -1`,
+		`1 << 64`,
+		`current >> 100`,
+	}
+	for _, expr := range eval {
+		n, err := compileExpr(expr)
+		if err != nil {
+			t.Fatalf("%s: compile failed: %v", expr, err)
+		}
+		_, err = n.eval(func(name string) any {
+			return map[string]any{"current": 5, "mode": "NORM"}[name]
+		})
+		if !errors.Is(err, ErrExprEval) {
+			t.Errorf("%s: exp error %v got %v", expr, ErrExprEval, err)
+		}
+	}
+}
+
+func TestEncodeExpr(t *testing.T) {
+	t.Parallel()
+	cmds := map[string]*command.Command{
+		"get_output": {Name: "get_output", Req: []byte("OUTP?"), Res: []byte(`OUTP {map(enable, true:"ON", false:"OFF")}`)},
+		"get_status": {Name: "get_status", Req: []byte("STAT?"), Res: []byte("STAT {%04X:bits(ready, fault, enable)}")},
+		"get_power":  {Name: "get_power", Req: []byte("POW?"), Res: []byte("POW {%.2f:current * volt}W {%d:current*volt}")},
+		"get_chan":   {Name: "get_chan", Req: []byte("CH{%d:#ch}?"), Res: []byte("CH{%d:#ch} {%.1f:volt[#ch] * 10 + #ch}")},
+	}
+	patterns, err := buildCommandPatterns(cmds)
+	if err != nil {
+		t.Fatalf("error while building patterns: %v", err)
+	}
+	p := &Parser{commandPatterns: patterns, outTerminator: []byte("\n")}
+
+	tests := []struct {
+		cmd     string
+		payload map[string]any
+		indexes map[string]int
+		exp     string
+	}{
+		{"get_output", map[string]any{"enable": false}, nil, "OUTP OFF\n"},
+		{"get_status", map[string]any{"ready": true, "fault": true, "enable": false}, nil, "STAT 0003\n"},
+		{"get_power", map[string]any{"current": int64(2), "volt": 1.3}, nil, "POW 2.60W 3\n"},
+		{"get_chan", map[string]any{"volt[2]": 3.3}, map[string]int{"#ch": 2}, "CH2 35.0\n"},
+		{"get_output", map[string]any{}, nil, "OUTP \n"},
+	}
+
+	for _, tt := range tests {
+		out, err := p.Encode([]protocol.Transaction{{Typ: protocol.TxGetParam, CommandName: tt.cmd, Payload: tt.payload, Indexes: tt.indexes}})
+		if err != nil {
+			t.Fatalf("%s: encode failed: %v", tt.cmd, err)
+		}
+		if string(out) != tt.exp {
+			t.Errorf("%s: exp %q got %q", tt.cmd, tt.exp, out)
+		}
+	}
+
+	// parameters used in expressions are read by get requests
+	tx := p.decode("STAT?", nil)
+	for _, name := range []string{"ready", "fault", "enable"} {
+		if _, exists := tx.Payload[name]; !exists {
+			t.Errorf("exp %s in payload %v", name, tx.Payload)
+		}
+	}
+}
+
+func TestBuildCommandPatternsExprErr(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		cmd  *command.Command
+		exp  error
+	}{
+		{"expression in request", &command.Command{Name: "set", Req: []byte("SET {%d:current*2}")}, ErrWrongReqSyntax},
+		{"unknown function", &command.Command{Name: "get", Req: []byte("GET?"), Res: []byte("{unknown(current)}")}, ErrWrongExprSyntax},
+		{"unterminated expression", &command.Command{Name: "get", Req: []byte("GET?"), Res: []byte(`{map(a, 1:"}"`)}, ErrWrongResSyntax},
+	}
+
+	for _, tt := range tests {
+		_, err := buildCommandPatterns(map[string]*command.Command{tt.cmd.Name: tt.cmd})
+		if !errors.Is(err, tt.exp) {
+			t.Errorf("%s: exp error %v got %v", tt.name, tt.exp, err)
+		}
+	}
+}
+
+func TestNewParserResponseParams(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		res  string
+		exp  error
+	}{
+		{"known parameter", "{%d:current - 1}", nil},
+		{"minus as part of the name", "{%d:current-1}", ErrWrongResSyntax},
+		{"unknown parameter", "{%d:voltage}", ErrWrongResSyntax},
+		{"unknown parameter in expression", "{%d:voltage * 2}", ErrWrongResSyntax},
+		{"error queue placeholder", "{%d:_error_count + 1}", nil},
+	}
+
+	for _, tt := range tests {
+		vd, err := vdfile.ReadVDFile(FILE1)
+		if err != nil {
+			t.Fatalf("error while parsing test file: %v", err)
+		}
+		vd.Commands["get_expr"] = &command.Command{Name: "get_expr", Req: []byte("EXPR?"), Res: []byte(tt.res)}
+
+		_, err = NewParser(vd)
+		if !errors.Is(err, tt.exp) {
+			t.Errorf("%s: exp error %v got %v", tt.name, tt.exp, err)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package stream
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Placeholder format split into parts, e.g. %-05.2f or %10[A-Z0-9]
+type format struct {
+	flags string
+	// 0 when not set
+	width int
+	// -1 when not set
+	prec int
+	verb byte
+	// runes accepted by %[...] placeholder, without brackets
+	charset string
+	// number of bytes and encoding of raw %r placeholder
+	size int
+	enc  string
+	// raw value is received as two's complement for signed parameters
+	signed bool
+}
+
+// Function that scans the beginning of the input and returns number of consumed bytes and received value
+type scanFunc func(input string) (int, string, bool)
+
+func parseFormat(s string) format {
+	f := format{prec: -1}
+	s = strings.TrimPrefix(s, "%")
+
+	if raw, isRaw := strings.CutPrefix(s, "r"); isRaw {
+		f.verb = 'r'
+		i := 0
+		for i < len(raw) && isNumber(rune(raw[i])) {
+			i++
+		}
+		f.size, _ = strconv.Atoi(raw[:i])
+		if f.size == 0 {
+			f.size = 1
+		}
+		f.enc = raw[i:]
+		if f.enc == "" {
+			f.enc = encBigEndian
+		}
+		return f
+	}
+
+	i := 0
+	for i < len(s) && strings.IndexByte("+-# 0", s[i]) >= 0 {
+		i++
+	}
+	f.flags = s[:i]
+
+	start := i
+	for i < len(s) && isNumber(rune(s[i])) {
+		i++
+	}
+	f.width, _ = strconv.Atoi(s[start:i])
+
+	if i < len(s) && s[i] == '.' {
+		i++
+		start = i
+		for i < len(s) && isNumber(rune(s[i])) {
+			i++
+		}
+		f.prec, _ = strconv.Atoi(s[start:i])
+	}
+
+	if i < len(s) {
+		f.verb = s[i]
+	}
+	if f.verb == '[' {
+		f.charset = strings.TrimSuffix(s[i+1:], "]")
+	}
+	return f
+}
+
+// Format used to print the value, charset placeholders are printed as strings
+func (f format) String() string {
+	if f.verb == 'r' {
+		return "%r" + strconv.Itoa(f.size) + f.enc
+	}
+	out := "%" + f.flags
+	if f.width > 0 {
+		out += strconv.Itoa(f.width)
+	}
+	if f.prec >= 0 {
+		out += "." + strconv.Itoa(f.prec)
+	}
+	if f.verb == '[' {
+		return out + "s"
+	}
+	return out + string(f.verb)
+}
+
+// Format value according to the placeholder
+func (f format) sprint(val any) string {
+	if f.verb == 'r' {
+		return formatRaw(f.size, f.enc, val)
+	}
+	format := f.String()
+	return fmt.Sprintf(format, unsignedForBase(format, val))
+}
+
+func (f format) hasFlag(flag byte) bool {
+	return strings.IndexByte(f.flags, flag) >= 0
+}
+
+func (f format) isString() bool {
+	return f.verb == 's' || f.verb == 'q' || f.verb == '['
+}
+
+// Return function scanning value of the placeholder. Elements of arrays
+// are scanned separately, so %s stops also at the separator.
+func (f format) scanner(sep string) scanFunc {
+	var scan scanFunc
+	switch f.verb {
+	case 'r':
+		// raw bytes are neither padded nor separated
+		return scanRaw(f.size, f.enc, f.signed)
+	case 'd':
+		scan = scanInt(10, "")
+	case 'x', 'X':
+		scan = scanInt(16, "0x")
+	case 'o', 'O':
+		scan = scanInt(8, "0o")
+	case 'b':
+		scan = scanInt(2, "0b")
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		scan = scanFloat
+	case 'c':
+		scan = scanChar
+	case 't':
+		scan = scanBool
+	case 'q':
+		scan = scanQuoted
+	case '[':
+		scan = scanCharset(f.charset)
+	case 's':
+		scan = scanString(sep)
+	default:
+		return func(string) (int, string, bool) { return 0, "", false }
+	}
+
+	return func(input string) (int, string, bool) {
+		field := input
+		// width of zero padded numbers and strings limits the field,
+		// so fields without separators e.g. T%03d%03d can be split
+		limit := -1
+		if f.width > 0 && (f.isString() || f.hasFlag('0')) {
+			limit = f.width
+		}
+		if f.verb == 's' && f.prec >= 0 && (limit < 0 || f.prec < limit) {
+			limit = f.prec
+		}
+		if limit >= 0 {
+			field = input[:runesLen(input, limit)]
+		}
+
+		// values shorter than width are padded with spaces
+		pad := 0
+		if !f.hasFlag('-') {
+			for pad < f.width-1 && pad < len(field) && field[pad] == ' ' {
+				pad++
+			}
+		}
+
+		n, val, ok := scan(field[pad:])
+		if !ok {
+			return 0, "", false
+		}
+		n += pad
+
+		if f.hasFlag('-') {
+			for n < f.width && n < len(field) && field[n] == ' ' {
+				n++
+			}
+		}
+		return n, val, true
+	}
+}
+
+// Number of bytes taken by the first n runes of the string
+func runesLen(s string, n int) int {
+	pos := 0
+	for i := 0; i < n && pos < len(s); i++ {
+		_, size := utf8.DecodeRuneInString(s[pos:])
+		pos += size
+	}
+	return pos
+}
+
+// Scan integer in the given base with optional sign and prefix, value is converted to decimal
+func scanInt(base int, prefix string) scanFunc {
+	return func(s string) (int, string, bool) {
+		pos := 0
+		if pos < len(s) && (s[pos] == '+' || s[pos] == '-') {
+			pos++
+		}
+		sign := s[:pos]
+		if len(s)-pos > len(prefix) && strings.EqualFold(s[pos:pos+len(prefix)], prefix) {
+			pos += len(prefix)
+		}
+
+		start := pos
+		for pos < len(s) && isDigit(s[pos], base) {
+			pos++
+		}
+		if pos == start {
+			return 0, "", false
+		}
+
+		if base == 10 {
+			return pos, sign + s[start:pos], true
+		}
+		if v, err := strconv.ParseInt(sign+s[start:pos], base, 64); err == nil {
+			return pos, strconv.FormatInt(v, 10), true
+		}
+		// values that do not fit into int64 are valid only for unsigned types
+		if v, err := strconv.ParseUint(s[start:pos], base, 64); err == nil && sign != "-" {
+			return pos, strconv.FormatUint(v, 10), true
+		}
+		return 0, "", false
+	}
+}
+
+func isDigit(b byte, base int) bool {
+	var v int
+	switch {
+	case '0' <= b && b <= '9':
+		v = int(b - '0')
+	case 'a' <= b && b <= 'z':
+		v = int(b-'a') + 10
+	case 'A' <= b && b <= 'Z':
+		v = int(b-'A') + 10
+	default:
+		return false
+	}
+	return v < base
+}
+
+// Scan floating point number in decimal or scientific notation
+func scanFloat(s string) (int, string, bool) {
+	pos := 0
+	digits := func() int {
+		start := pos
+		for pos < len(s) && isDigit(s[pos], 10) {
+			pos++
+		}
+		return pos - start
+	}
+
+	if pos < len(s) && (s[pos] == '+' || s[pos] == '-') {
+		pos++
+	}
+	n := digits()
+	if pos < len(s) && s[pos] == '.' {
+		pos++
+		n += digits()
+	}
+	if n == 0 {
+		return 0, "", false
+	}
+
+	// exponent is taken only when it is complete
+	if pos < len(s) && (s[pos] == 'e' || s[pos] == 'E') {
+		mantissa := pos
+		pos++
+		if pos < len(s) && (s[pos] == '+' || s[pos] == '-') {
+			pos++
+		}
+		if digits() == 0 {
+			pos = mantissa
+		}
+	}
+	return pos, s[:pos], true
+}
+
+// Scan single character, value is its code
+func scanChar(s string) (int, string, bool) {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || r == utf8.RuneError {
+		return 0, "", false
+	}
+	return size, strconv.Itoa(int(r)), true
+}
+
+func scanBool(s string) (int, string, bool) {
+	for _, b := range []string{"true", "false"} {
+		if strings.HasPrefix(s, b) {
+			return len(b), b, true
+		}
+	}
+	return 0, "", false
+}
+
+// Scan quoted string with Go escape sequences, value is unquoted
+func scanQuoted(s string) (int, string, bool) {
+	quoted, err := strconv.QuotedPrefix(s)
+	if err != nil {
+		return 0, "", false
+	}
+	val, err := strconv.Unquote(quoted)
+	if err != nil {
+		return 0, "", false
+	}
+	return len(quoted), val, true
+}
+
+// Scan string ending with space or separator of array elements
+func scanString(sep string) scanFunc {
+	return func(s string) (int, string, bool) {
+		out := parseString(s)
+		if sep != "" {
+			if i := strings.Index(out, sep); i >= 0 {
+				out = out[:i]
+			}
+		}
+		return len(out), out, true
+	}
+}
+
+// Scan the longest string made of runes from the set like in scanf, e.g. A-Z0-9 or ^, for all but comma
+func scanCharset(set string) scanFunc {
+	negate := strings.HasPrefix(set, "^")
+	if negate {
+		set = set[1:]
+	}
+
+	return func(s string) (int, string, bool) {
+		pos := 0
+		for pos < len(s) {
+			r, size := utf8.DecodeRuneInString(s[pos:])
+			if inCharset(set, r) == negate {
+				break
+			}
+			pos += size
+		}
+		if pos == 0 {
+			return 0, "", false
+		}
+		return pos, s[:pos], true
+	}
+}
+
+func inCharset(set string, r rune) bool {
+	runes := []rune(set)
+	for i := 0; i < len(runes); i++ {
+		// dash between two runes defines range, otherwise it is a regular rune
+		if i+2 < len(runes) && runes[i+1] == '-' {
+			if runes[i] <= r && r <= runes[i+2] {
+				return true
+			}
+			i += 2
+			continue
+		}
+		if runes[i] == r {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package stream
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/e9ctrl/vd/vdfile"
+)
+
+// Single request cut from received data
+type frame struct {
+	data []byte
+	// terminator that ended the request, nil when request was not terminated
+	term []byte
+}
+
+// Rules of cutting received data into requests
+type framing struct {
+	mode string
+	// terminators of all commands, the longest first
+	terminators [][]byte
+	length      int
+	regex       *regexp.Regexp
+}
+
+// Cut data into requests, rest is the beginning of the incomplete request.
+// When flush is set there is no more data to wait for and rest is returned as the last request.
+func (f framing) split(data []byte, flush bool) (frames []frame, rest []byte) {
+	switch f.mode {
+	case vdfile.FramingFixed:
+		for len(data) >= f.length {
+			frames = append(frames, frame{data: data[:f.length]})
+			data = data[f.length:]
+		}
+	case vdfile.FramingRegex:
+		for {
+			loc := f.regex.FindSubmatchIndex(data)
+			if loc == nil || loc[1] == 0 {
+				break
+			}
+			// the first group, when present, is the request without framing bytes
+			start, end := loc[0], loc[1]
+			if len(loc) > 2 && loc[2] >= 0 {
+				start, end = loc[2], loc[3]
+			}
+			frames = append(frames, frame{data: data[start:end]})
+			data = data[loc[1]:]
+		}
+	case vdfile.FramingIdle:
+	default:
+		for len(f.terminators) > 0 {
+			i, term := f.nextTerminator(data)
+			if i < 0 {
+				break
+			}
+			frames = append(frames, frame{data: data[:i], term: term})
+			data = data[i+len(term):]
+		}
+	}
+
+	if flush {
+		if len(data) > 0 {
+			frames = append(frames, frame{data: data})
+		}
+		return frames, nil
+	}
+	return frames, data
+}
+
+// Find the first terminator in data, the longest one is used when several start at the same position
+func (f framing) nextTerminator(data []byte) (int, []byte) {
+	pos, found := -1, []byte(nil)
+	for _, term := range f.terminators {
+		if i := bytes.Index(data, term); i >= 0 && (pos < 0 || i < pos) {
+			pos, found = i, term
+		}
+	}
+	return pos, found
+}
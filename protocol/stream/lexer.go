@@ -2,6 +2,7 @@ package stream
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"text/scanner"
 	"unicode/utf8"
@@ -36,6 +37,9 @@ const (
 	ItemEOF
 	ItemIllegal
 	ItemEscape
+	ItemByte
+	// Expression computing the value of the placeholder, e.g. map(enable, true:"ON", false:"OFF")
+	ItemExpr
 )
 
 var typeStr = map[ItemType]string{
@@ -52,6 +56,8 @@ var typeStr = map[ItemType]string{
 	ItemIllegal:    "illegal",
 	ItemNumber:     "number",
 	ItemEscape:     "escape value",
+	ItemByte:       "byte value",
+	ItemExpr:       "expression",
 }
 
 // To string representation
@@ -67,6 +73,8 @@ func (i ItemType) String() string {
 type Item struct {
 	typ ItemType
 	val string
+	// expression compiled when command patterns are built
+	expr node
 }
 
 // Item type
@@ -133,7 +141,13 @@ func ItemsFromConfig(input string) []Item {
 
 // Generate token
 func (l *Lexer) emit(t ItemType) {
-	l.ItemsCh <- Item{t, l.Input[l.start:l.pos]}
+	l.ItemsCh <- Item{typ: t, val: l.Input[l.start:l.pos]}
+	l.start = l.pos
+}
+
+// Generate token with value different than the input e.g. decoded \x02
+func (l *Lexer) emitValue(t ItemType, val string) {
+	l.ItemsCh <- Item{typ: t, val: val}
 	l.start = l.pos
 }
 
@@ -184,9 +198,13 @@ func (l *Lexer) errorf(format string, args ...interface{}) StateFn {
 	if start < 0 {
 		start = 0
 	}
+	end := l.pos + 1
+	if end > len(l.Input) {
+		end = len(l.Input)
+	}
 	l.ItemsCh <- Item{
-		ItemError,
-		fmt.Sprintf("error at char %d: '%s'\n%s", l.pos, l.Input[start:l.pos+1], msg),
+		typ: ItemError,
+		val: fmt.Sprintf("error at char %d: '%s'\n%s", l.pos, l.Input[start:end], msg),
 	}
 	//panic("PANIC")
 	return nil
@@ -256,6 +274,23 @@ func isEscape(ch rune) bool {
 	return ch == '\f' || ch == '\n' || ch == '\t' || ch == '\r' || ch == '\v'
 }
 
+// Other control characters, e.g. STX and ETX framing bytes
+func isControl(ch rune) bool {
+	return (ch >= 0 && ch < ' ') || ch == 0x7F
+}
+
+// Check if input at the position starts with byte escape e.g. \x02 and return its value
+func byteEscape(input string) (byte, bool) {
+	if len(input) < 4 || input[0] != '\\' || input[1] != 'x' {
+		return 0, false
+	}
+	b, err := strconv.ParseUint(input[2:4], 16, 8)
+	if err != nil {
+		return 0, false
+	}
+	return byte(b), true
+}
+
 // This is the initial state and base state
 func lexStart(l *Lexer) StateFn {
 	switch ch := l.next(); {
@@ -279,6 +314,19 @@ func lexStart(l *Lexer) StateFn {
 	case isEscape(ch):
 		l.emit(ItemEscape)
 		return lexStart
+	case isControl(ch):
+		l.emit(ItemByte)
+		return lexStart
+	case ch == '\\':
+		b, ok := byteEscape(l.Input[l.start:])
+		if !ok {
+			l.backup()
+			l.emit(ItemIllegal)
+			return nil
+		}
+		l.pos = l.start + 4
+		l.emitValue(ItemByte, string([]byte{b}))
+		return lexStart
 	default:
 		l.backup()
 		l.emit(ItemIllegal)
@@ -289,7 +337,8 @@ func lexStart(l *Lexer) StateFn {
 func lexCommand(l *Lexer) StateFn {
 	for {
 		ch := l.next()
-		if ch == scanner.EOF || isSpace(ch) || ch == '{' {
+		_, isByte := byteEscape(l.Input[l.pos-l.width:])
+		if ch == scanner.EOF || isSpace(ch) || ch == '{' || isByte {
 			l.backup()
 			l.emit(ItemCommand)
 			return lexStart
@@ -298,8 +347,15 @@ func lexCommand(l *Lexer) StateFn {
 }
 
 func lexParam(l *Lexer) StateFn {
-	in := "_.-0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	// brackets are used to reference elements of array parameters e.g. chan[3],
+	// # starts name of the index captured from the request e.g. #ch
+	in := "_.-[]#0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 	if l.acceptRun(in) {
+		// operator or call after the name makes the placeholder an expression, e.g. {%d:current*2}
+		if rest := strings.TrimLeft(l.Input[l.pos:], " "); rest != "" && strings.ContainsRune(exprChars, rune(rest[0])) {
+			l.pos = l.start
+			return lexExpr
+		}
 		l.emit(ItemParam)
 	}
 
@@ -308,12 +364,23 @@ func lexParam(l *Lexer) StateFn {
 
 func lexPlaceholder(l *Lexer) StateFn {
 	if l.accept("%") {
-		if l.accept("s") {
+		if l.accept("r") {
+			return lexRaw
+		}
+		// flags, width and precision
+		l.acceptRun("+-# 0")
+		l.acceptRun("0123456789")
+		if l.accept(".") {
+			l.acceptRun("0123456789")
+		}
+
+		switch {
+		case l.accept("sq"):
 			l.emit(ItemStringValuePlaceholder)
 			return lexInsideParamPlaceholder
-		}
-		in := ".0123456789gGeEfFdcbtxX"
-		if l.acceptRun(in) {
+		case l.accept("["):
+			return lexCharset
+		case l.accept("gGeEfFdcbtoOxX"):
 			ch := l.peek()
 			if ch == ':' || ch == '}' {
 				l.emit(ItemNumberValuePlaceholder)
@@ -324,6 +391,44 @@ func lexPlaceholder(l *Lexer) StateFn {
 	return l.errorf("wrong placeholder value")
 }
 
+// Raw integer with number of bytes and encoding, e.g. %r2be, %r4le or %r2bcd
+func lexRaw(l *Lexer) StateFn {
+	digits := l.pos
+	l.acceptRun("0123456789")
+	if size := l.Input[digits:l.pos]; size != "" {
+		if n, err := strconv.Atoi(size); err != nil || n < 1 || n > maxRawSize {
+			return l.errorf("raw placeholder size has to be between 1 and %d", maxRawSize)
+		}
+	}
+	for _, enc := range []string{"be", "le", "bcd"} {
+		if strings.HasPrefix(l.Input[l.pos:], enc) {
+			l.pos += len(enc)
+			break
+		}
+	}
+	if ch := l.peek(); ch != ':' && ch != '}' {
+		return l.errorf("wrong raw placeholder value")
+	}
+	l.emit(ItemNumberValuePlaceholder)
+	return lexInsideParamPlaceholder
+}
+
+// Set of characters accepted by the placeholder like in scanf, e.g. %[A-Z0-9] or %[^,]
+func lexCharset(l *Lexer) StateFn {
+	l.accept("^")
+	// closing bracket right after the opening one is part of the set
+	l.accept("]")
+	for {
+		switch l.next() {
+		case ']':
+			l.emit(ItemStringValuePlaceholder)
+			return lexInsideParamPlaceholder
+		case eof:
+			return l.errorf("unterminated character set")
+		}
+	}
+}
+
 func lexLeftMeta(l *Lexer) StateFn {
 	l.emit(ItemLeftMeta)
 	// ignore all spaces between % and {
@@ -333,7 +438,10 @@ func lexLeftMeta(l *Lexer) StateFn {
 
 	l.backup()
 
-	// after { there always should be %
+	// after { there always should be % or expression e.g. {map(enable, true:"ON", false:"OFF")}
+	if ch := l.peek(); isLetter(ch) || ch == '(' || ch == '!' {
+		return lexExpr
+	}
 	if l.peek() != '%' {
 		l.emit(ItemIllegal)
 		return nil
@@ -342,6 +450,34 @@ func lexLeftMeta(l *Lexer) StateFn {
 	return lexInsideParamPlaceholder
 }
 
+// Characters following parameter name that start expression
+const exprChars = "(+-*/%<>=!&|^"
+
+// Expression up to the closing bracket, brackets inside quoted strings are part of it
+func lexExpr(l *Lexer) StateFn {
+	var quote rune
+	for {
+		ch := l.next()
+		switch {
+		case ch == eof:
+			return l.errorf("unterminated expression")
+		case quote != 0:
+			if ch == '\\' && quote == '"' {
+				l.next()
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '}':
+			l.backup()
+			l.emitValue(ItemExpr, strings.TrimRight(l.Input[l.start:l.pos], " "))
+			l.next()
+			return lexRightMeta
+		}
+	}
+}
+
 func lexInsideParamPlaceholder(l *Lexer) StateFn {
 	for {
 		ch := l.next()
@@ -360,7 +496,7 @@ func lexInsideParamPlaceholder(l *Lexer) StateFn {
 			continue
 		}
 
-		if isAlphaNumeric(ch) {
+		if isAlphaNumeric(ch) || ch == '#' || ch == '_' {
 			l.backup()
 			return lexParam
 		}
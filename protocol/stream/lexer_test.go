@@ -38,13 +38,27 @@ func TestLexer(t *testing.T) {
 		{"one parameter with whitespaces", "{ %d:param }", []lexer.ItemType{lexer.ItemLeftMeta, lexer.ItemNumberValuePlaceholder, lexer.ItemParam, lexer.ItemRightMeta, lexer.ItemEOF}, "{%dparam}"},
 		{"one parameter with more whitespaces", "{   %d:param   }", []lexer.ItemType{lexer.ItemLeftMeta, lexer.ItemNumberValuePlaceholder, lexer.ItemParam, lexer.ItemRightMeta, lexer.ItemEOF}, "{%dparam}"},
 
+		{"error queue parameter", "{%d:_error}", []lexer.ItemType{lexer.ItemLeftMeta, lexer.ItemNumberValuePlaceholder, lexer.ItemParam, lexer.ItemRightMeta, lexer.ItemEOF}, "{%d_error}"},
+		{"bounded string", "{%-8s:name}", []lexer.ItemType{lexer.ItemLeftMeta, lexer.ItemStringValuePlaceholder, lexer.ItemParam, lexer.ItemRightMeta, lexer.ItemEOF}, "{%-8sname}"},
+		{"quoted string", "{%q:name}", []lexer.ItemType{lexer.ItemLeftMeta, lexer.ItemStringValuePlaceholder, lexer.ItemParam, lexer.ItemRightMeta, lexer.ItemEOF}, "{%qname}"},
+		{"charset", "{%[A-Z: }]:id}", []lexer.ItemType{lexer.ItemLeftMeta, lexer.ItemStringValuePlaceholder, lexer.ItemParam, lexer.ItemRightMeta, lexer.ItemEOF}, "{%[A-Z: }]id}"},
+		{"unterminated charset", "{%[A-Z", []lexer.ItemType{lexer.ItemLeftMeta, lexer.ItemError, lexer.ItemEOF}, "{error at char 6: '{%[A-Z'\nunterminated character set"},
 		{"illegal character", "!", []lexer.ItemType{lexer.ItemIllegal, lexer.ItemEOF}, ""},
-		{"illegal escape", "\a", []lexer.ItemType{lexer.ItemIllegal, lexer.ItemEOF}, ""},
+		{"control character", "\a", []lexer.ItemType{lexer.ItemByte, lexer.ItemEOF}, "\a"},
+		{"illegal escape", "\\q", []lexer.ItemType{lexer.ItemIllegal, lexer.ItemEOF}, ""},
+		{"byte escapes", "\\x02STAT\\x03", []lexer.ItemType{lexer.ItemByte, lexer.ItemCommand, lexer.ItemByte, lexer.ItemEOF}, "\x02STAT\x03"},
+		{"raw placeholder", "CNT{%r2be:counter}", []lexer.ItemType{lexer.ItemCommand, lexer.ItemLeftMeta, lexer.ItemNumberValuePlaceholder, lexer.ItemParam, lexer.ItemRightMeta, lexer.ItemEOF}, "CNT{%r2becounter}"},
+		{"too big raw placeholder", "{%r9be:counter}", []lexer.ItemType{lexer.ItemLeftMeta, lexer.ItemError, lexer.ItemEOF}, "{error at char 4: '{%r9b'\nraw placeholder size has to be between 1 and 8"},
+		{"empty raw placeholder", "{%r0:counter}", []lexer.ItemType{lexer.ItemLeftMeta, lexer.ItemError, lexer.ItemEOF}, "{error at char 4: '{%r0:'\nraw placeholder size has to be between 1 and 8"},
+		{"wrong raw placeholder", "{%r2xx:counter}", []lexer.ItemType{lexer.ItemLeftMeta, lexer.ItemError, lexer.ItemEOF}, "{error at char 4: '{%r2x'\nwrong raw placeholder value"},
 		{"new line between params", "val: {%s:param}\n{%s:param}", []lexer.ItemType{lexer.ItemCommand, lexer.ItemWhiteSpace, lexer.ItemLeftMeta, lexer.ItemStringValuePlaceholder, lexer.ItemParam, lexer.ItemRightMeta, lexer.ItemEscape, lexer.ItemLeftMeta, lexer.ItemStringValuePlaceholder, lexer.ItemParam, lexer.ItemRightMeta, lexer.ItemEOF}, "val: {%sparam}\n{%sparam}"},
 		{"number as a command", "get two 2", []lexer.ItemType{lexer.ItemCommand, lexer.ItemWhiteSpace, lexer.ItemCommand, lexer.ItemWhiteSpace, lexer.ItemCommand, lexer.ItemEOF}, "get two 2"},
 		{"hex command", "HEX 0x{%03X:hex}", []lexer.ItemType{lexer.ItemCommand, lexer.ItemWhiteSpace, lexer.ItemCommand, lexer.ItemLeftMeta, lexer.ItemNumberValuePlaceholder, lexer.ItemParam, lexer.ItemRightMeta, lexer.ItemEOF}, "HEX 0x{%03Xhex}"},
 		{"long command", ":STAT POW,{%.1f:pow},1.1,2.2,3.3,4.4", []lexer.ItemType{lexer.ItemCommand, lexer.ItemWhiteSpace, lexer.ItemCommand, lexer.ItemLeftMeta, lexer.ItemNumberValuePlaceholder, lexer.ItemParam, lexer.ItemRightMeta, lexer.ItemCommand, lexer.ItemEOF}, ":STAT POW,{%.1fpow},1.1,2.2,3.3,4.4"},
 		{"set ch1 tec cmd", "set ch1 tec07A", []lexer.ItemType{lexer.ItemCommand, lexer.ItemWhiteSpace, lexer.ItemCommand, lexer.ItemWhiteSpace, lexer.ItemCommand, lexer.ItemEOF}, "set ch1 tec07A"},
+		{"expression", `OUTP {map(enable, true:"ON", false:"}")}`, []lexer.ItemType{lexer.ItemCommand, lexer.ItemWhiteSpace, lexer.ItemLeftMeta, lexer.ItemExpr, lexer.ItemRightMeta, lexer.ItemEOF}, `OUTP {map(enable, true:"ON", false:"}")}`},
+		{"expression with placeholder", "{%.1f: current * 0.1 }", []lexer.ItemType{lexer.ItemLeftMeta, lexer.ItemNumberValuePlaceholder, lexer.ItemExpr, lexer.ItemRightMeta, lexer.ItemEOF}, "{%.1fcurrent * 0.1}"},
+		{"unterminated expression", "{if(a, 1, 2)", []lexer.ItemType{lexer.ItemLeftMeta, lexer.ItemError, lexer.ItemEOF}, "{error at char 12: 'f(a, 1, 2)'\nunterminated expression"},
 		{"set ch1 tec config", "set ch1 tec{%03X:tec_max_current}\r", []lexer.ItemType{lexer.ItemCommand, lexer.ItemWhiteSpace, lexer.ItemCommand, lexer.ItemWhiteSpace, lexer.ItemCommand, lexer.ItemLeftMeta, lexer.ItemNumberValuePlaceholder, lexer.ItemParam, lexer.ItemRightMeta, lexer.ItemEscape, lexer.ItemEOF}, "set ch1 tec{%03Xtec_max_current}\r"},
 	}
 	for _, tt := range tests {
@@ -1,20 +1,28 @@
 package stream
 
 import (
-	"bufio"
 	"bytes"
 	"errors"
 	"fmt"
-	"os"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/e9ctrl/vd/command"
 	"github.com/e9ctrl/vd/log"
+	"github.com/e9ctrl/vd/parameter"
 	"github.com/e9ctrl/vd/protocol"
 	"github.com/e9ctrl/vd/vdfile"
 )
 
+// Names of placeholders of responses to failed set, e.g. on_invalid = "ERR {%s:_param}={%s:_value}"
+const (
+	failureParam = "_param"
+	failureValue = "_value"
+)
+
 var (
 	ErrWrongResSyntax = errors.New("illegal syntax in response")
 	ErrWrongReqSyntax = errors.New("illegal syntax in request")
@@ -23,80 +31,128 @@ var (
 // Keeps request and response tokens
 type CommandPattern struct {
 	reqItems []Item
+	// items of all lines of the response
 	resItems []Item
+	resLines [][]Item
+	// responses to failed set of the parameter by kind of the failure
+	failures map[string][]Item
+	// terminators of request and response lines, global ones are used when nil
+	inTerminator  []byte
+	outTerminator []byte
 }
 
 // Main parser structure, based on vdfile generates map of commands, and then parses incoming messages
 type Parser struct {
-	splitter        bufio.SplitFunc
+	framing         framing
+	inTerminator    []byte
 	outTerminator   []byte
+	prompt          []byte
 	mismatch        []byte
+	mismatchLock    sync.RWMutex
 	commandPatterns map[string]CommandPattern
+	// separators of elements of array parameters
+	separators map[string]string
+	// parameters of signed integer types, their raw values are sign extended
+	signed map[string]bool
+	// SCPI requests can be compound and their headers are case insensitive
+	scpi bool
 }
 
 // Method that fullfils main Protocol interface, all logic is implemented here.
 // Based on byte input it returns transactions to be processed.
 func (p *Parser) Decode(data []byte) ([]protocol.Transaction, error) {
+	txs, _, err := p.DecodeFrames(data, true)
+	return txs, err
+}
 
-	r := bytes.NewReader(data)
-	scanner := bufio.NewScanner(r)
-	scanner.Split(p.splitter)
+// Method that fulfils Protocol interface. Data is cut into requests according to the framing,
+// incomplete request is returned as rest unless flush is set.
+func (p *Parser) DecodeFrames(data []byte, flush bool) ([]protocol.Transaction, []byte, error) {
+	frames, rest := p.framing.split(data, flush)
 
-	var err error
-	txs := make([]protocol.Transaction, 0)
-	for scanner.Scan() {
-		txs = append(txs, p.decode(scanner.Text()))
-	}
+	txs := make([]protocol.Transaction, 0, len(frames))
+	for _, f := range frames {
+		if !p.scpi {
+			txs = append(txs, p.decode(string(f.data), f.term))
+			continue
+		}
 
-	if err = scanner.Err(); err != nil {
-		fmt.Fprintln(os.Stderr, "Error scanning: ", err.Error())
-		return []protocol.Transaction{}, err
+		// only the last command of compound request is followed by the terminator
+		cmds := splitCompound(string(f.data))
+		for i, cmd := range cmds {
+			var term []byte
+			if i == len(cmds)-1 {
+				term = f.term
+			}
+			tx := p.decode(cmd, term)
+			tx.More = i < len(cmds)-1
+			txs = append(txs, tx)
+		}
 	}
-
-	return txs, nil
+	return txs, rest, nil
 }
 
-func (p *Parser) decode(input string) protocol.Transaction {
+// Decode single request, term is the terminator it ended with
+func (p *Parser) decode(input string, term []byte) protocol.Transaction {
 
 	tx := protocol.Transaction{
 		Payload: make(map[string]any),
+		Raw:     []byte(input),
+		Term:    term,
 	}
 
 	// It happens that input string matches several patterns
 	// To prevent from random matches, it's better to sort patterns
 	// and always use the longest slice of Items that matches input
 	matched := []struct {
-		cmd  string
-		res  []Item
-		req  []Item
-		vals map[string]any
+		cmd     string
+		res     []Item
+		req     []Item
+		vals    map[string]any
+		indexes map[string]int
 	}{}
 
 	for cmdName, pattern := range p.commandPatterns {
+		if !p.terminatedBy(pattern, term) {
+			continue
+		}
 		// chcecks if input string matches one of the request
-		match, values := checkPattern(input, pattern.reqItems)
+		literal := matchLiteral
+		if p.scpi {
+			literal = matchSCPI
+		}
+		match, values := matchPattern(input, pattern.reqItems, p.separators, p.signed, literal)
 		if !match {
 			continue
 		}
+		indexes, valid := takeIndexes(values)
+		if !valid {
+			continue
+		}
 		// This copies data from map to struct to sort it
 		// Maps cannot be sorted
 		m := struct {
-			cmd  string
-			res  []Item
-			req  []Item
-			vals map[string]any
+			cmd     string
+			res     []Item
+			req     []Item
+			vals    map[string]any
+			indexes map[string]int
 		}{
-			cmd:  cmdName,
-			req:  pattern.reqItems,
-			res:  pattern.resItems,
-			vals: values,
+			cmd:     cmdName,
+			req:     pattern.reqItems,
+			res:     pattern.resItems,
+			vals:    values,
+			indexes: indexes,
 		}
 		matched = append(matched, m)
 	}
 
 	// if nothing is matched, just return an error
 	if len(matched) == 0 {
-		log.ERR(protocol.ErrCommandNotFound)
+		if p.typeError(&tx, input, term) {
+			return tx
+		}
+		log.ERR(protocol.ErrCommandNotFound.Error(), "request", input)
 		return tx
 	}
 
@@ -110,13 +166,14 @@ func (p *Parser) decode(input string) protocol.Transaction {
 	// it does not matter how many matches we have
 	values := matched[0].vals
 	tx.CommandName = matched[0].cmd
+	tx.Indexes = matched[0].indexes
 	res := matched[0].res
 
 	if len(values) > 0 {
 		// set params
 		tx.Typ = protocol.TxSetParam
 		for paramName, val := range values {
-			tx.Payload[paramName] = val
+			tx.Payload[resolveIndexes(paramName, tx.Indexes)] = val
 		}
 
 		return tx
@@ -124,37 +181,148 @@ func (p *Parser) decode(input string) protocol.Transaction {
 
 	//get params
 	tx.Typ = protocol.TxGetParam
-	for _, item := range res {
-		if item.Type() == ItemParam {
-			tx.Payload[item.Value()] = nil
-
+	for _, name := range responseParams(res) {
+		if !isIndex(name) {
+			tx.Payload[resolveIndexes(name, tx.Indexes)] = nil
 		}
 	}
 	return tx
 }
 
+// Check if request ended with terminator of the command, not terminated requests match all commands
+func (p *Parser) terminatedBy(pattern CommandPattern, term []byte) bool {
+	if term == nil {
+		return true
+	}
+	if pattern.inTerminator != nil {
+		return bytes.Equal(pattern.inTerminator, term)
+	}
+	return bytes.Equal(p.inTerminator, term)
+}
+
 // Method that fulfils Protocol interface.
 // Based on received transactions it generates byte response/
 func (p *Parser) Encode(txs []protocol.Transaction) ([]byte, error) {
+	parts, err := p.EncodeParts(txs)
+	if err != nil {
+		return nil, err
+	}
 
-	var buf []byte
 	var out []byte
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out, nil
+}
+
+// Method that fulfils Protocol interface. Every line of the response
+// is a separate part, prompt is added at the end of every reply.
+// Replies to commands of compound request are joined with semicolons into one line.
+func (p *Parser) EncodeParts(txs []protocol.Transaction) ([][]byte, error) {
+	var (
+		parts [][]byte
+		// replies to previous commands of compound request
+		compound [][]byte
+		joining  bool
+	)
 
 	for _, tx := range txs {
-		if tx.Typ == protocol.TxMismatch {
-			buf = p.mismatch
-			log.MSM(string(buf))
-		} else {
-			responseItems := p.commandPatterns[tx.CommandName].resItems
-			buf = constructOutput(responseItems, tx.Payload)
+		lines, terminator := p.reply(tx)
+		if tx.More || joining {
+			compound = append(compound, lines...)
+			joining = tx.More
+			if joining {
+				continue
+			}
+			lines = nil
+			if len(compound) > 0 {
+				lines = [][]byte{bytes.Join(compound, []byte(";"))}
+			}
+			compound = nil
+		}
+
+		for i := range lines {
+			lines[i] = append(lines[i], terminator...)
+		}
+		if len(p.prompt) > 0 {
+			if len(lines) == 0 {
+				lines = append(lines, nil)
+			}
+			lines[len(lines)-1] = append(lines[len(lines)-1], p.prompt...)
 		}
+		parts = append(parts, lines...)
+	}
+
+	return parts, nil
+}
+
+// Lines of the reply to the transaction without terminator and the terminator they should end with
+func (p *Parser) reply(tx protocol.Transaction) ([][]byte, []byte) {
+	var lines [][]byte
+	switch {
+	case tx.Typ == protocol.TxReply:
+		if len(tx.Reply) > 0 {
+			lines = append(lines, bytes.Clone(tx.Reply))
+		}
+	case tx.Typ == protocol.TxMismatch && tx.Failure != nil && p.hasFailure(tx):
+		pattern := p.commandPatterns[tx.CommandName]
+		buf := constructOutput(pattern.failures[tx.Failure.Kind], failurePayload(tx), p.separators, tx.Indexes)
+		log.MSM(string(buf))
+		lines = append(lines, buf)
+		if pattern.outTerminator != nil {
+			return lines, pattern.outTerminator
+		}
+	case tx.Typ == protocol.TxMismatch && len(tx.Reply) > 0:
+		buf := bytes.Clone(tx.Reply)
+		log.MSM(string(buf))
+		lines = append(lines, buf)
+	case tx.Typ == protocol.TxMismatch:
+		p.mismatchLock.RLock()
+		buf := bytes.Clone(p.mismatch)
+		p.mismatchLock.RUnlock()
+		log.MSM(string(buf))
 		if len(buf) > 0 {
-			buf = append(buf, p.outTerminator...)
-			out = append(out, buf...)
+			lines = append(lines, buf)
+		}
+	default:
+		pattern := p.commandPatterns[tx.CommandName]
+		for _, items := range pattern.resLines {
+			buf := constructOutput(items, tx.Payload, p.separators, tx.Indexes)
+			// empty lines are sent only as a part of multi-line response
+			if len(buf) > 0 || len(pattern.resLines) > 1 {
+				lines = append(lines, buf)
+			}
+		}
+		if pattern.outTerminator != nil {
+			return lines, pattern.outTerminator
 		}
 	}
+	return lines, p.outTerminator
+}
 
-	return out, nil
+// Check if the command of the transaction has response to its failure
+func (p *Parser) hasFailure(tx protocol.Transaction) bool {
+	_, exists := p.commandPatterns[tx.CommandName].failures[tx.Failure.Kind]
+	return exists
+}
+
+// Payload of the response to the failure, name of the parameter and received value
+// are available as _param and _value next to the current values of parameters
+func failurePayload(tx protocol.Transaction) map[string]any {
+	payload := make(map[string]any, len(tx.Payload)+2)
+	for name, val := range tx.Payload {
+		payload[name] = val
+	}
+	payload[failureParam] = tx.Failure.Param
+	payload[failureValue] = tx.Failure.Value
+	return payload
+}
+
+// Method that fulfils Protocol interface, following mismatched requests are answered with msg
+func (p *Parser) SetMismatch(msg []byte) {
+	p.mismatchLock.Lock()
+	p.mismatch = msg
+	p.mismatchLock.Unlock()
 }
 
 // Method that fulfils Protocol interface. It enforces processing of
@@ -170,9 +338,9 @@ func (p *Parser) Trigger(cmdName string) protocol.Transaction {
 	tx.Payload = make(map[string]any)
 	tx.CommandName = cmdName
 
-	for _, item := range responseItems {
-		if item.Type() == ItemParam {
-			tx.Payload[item.Value()] = nil
+	for _, name := range responseParams(responseItems) {
+		if !isIndex(name) {
+			tx.Payload[name] = nil
 		}
 	}
 
@@ -185,58 +353,117 @@ func NewParser(vdfile *vdfile.VDFile) (protocol.Protocol, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := checkResponseParams(commandPattern, vdfile.Params); err != nil {
+		return nil, err
+	}
+	if vdfile.Profile == profileSCPI {
+		trimRootColon(commandPattern)
+	}
+
+	separators := make(map[string]string)
+	signed := make(map[string]bool)
+	for name, param := range vdfile.Params {
+		typ := param.Type()
+		if arr, ok := param.(parameter.Indexed); ok {
+			separators[name] = arr.Shape().Sep
+			typ = arr.Elem()
+		}
+		switch typ {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			signed[name] = true
+		}
+	}
 
 	return &Parser{
+		separators:      separators,
+		signed:          signed,
 		commandPatterns: commandPattern,
 		outTerminator:   vdfile.OutTerminator,
+		prompt:          vdfile.Prompt,
 		mismatch:        vdfile.Mismatch,
-		splitter: func(data []byte, atEOF bool) (advance int, token []byte, err error) {
-			if atEOF && len(data) == 0 {
-				return 0, nil, nil
-			}
-			if vdfile.InTerminator == nil {
-				return 0, nil, nil
-			}
-			// Find sequence of terminator bytes
-			if i := bytes.Index(data, vdfile.InTerminator); i >= 0 {
-				return i + len(vdfile.InTerminator), data[0:i], nil
-			}
-
-			// If we're at EOF, we have a final, non-terminated line. Return it.
-			if atEOF {
-				return len(data), data, nil
-			}
-			// Request more data.
-			return 0, nil, nil
+		inTerminator:    vdfile.InTerminator,
+		scpi:            vdfile.Profile == profileSCPI,
+		framing: framing{
+			mode:        vdfile.Framing,
+			terminators: terminators(vdfile),
+			length:      vdfile.FrameLen,
+			regex:       vdfile.FrameRegex,
 		},
 	}, nil
 }
 
+// Global terminator and terminators of commands, the longest first
+func terminators(vdfile *vdfile.VDFile) [][]byte {
+	var terms [][]byte
+	add := func(term []byte) {
+		if len(term) == 0 {
+			return
+		}
+		for _, t := range terms {
+			if bytes.Equal(t, term) {
+				return
+			}
+		}
+		terms = append(terms, term)
+	}
+
+	add(vdfile.InTerminator)
+	for _, cmd := range vdfile.Commands {
+		add(cmd.InTerminator)
+	}
+	sort.SliceStable(terms, func(i, j int) bool {
+		return len(terms[i]) > len(terms[j])
+	})
+	return terms
+}
+
 func buildCommandPatterns(commands map[string]*command.Command) (map[string]CommandPattern, error) {
 	patterns := map[string]CommandPattern{}
 
 	// validate the items output for each req and res,
 	// report the error back when there is a IllegalItem
 	for key, cmd := range commands {
-		pattern := CommandPattern{}
+		pattern := CommandPattern{inTerminator: cmd.InTerminator, outTerminator: cmd.OutTerminator}
 		if len(cmd.Req) > 0 {
 			pattern.reqItems = ItemsFromConfig(string(cmd.Req))
 
 			for _, item := range pattern.reqItems {
-				if item.typ == ItemIllegal || item.typ == ItemError {
+				// expressions are computed only in responses
+				if item.typ == ItemIllegal || item.typ == ItemError || item.typ == ItemExpr {
 					return nil, ErrWrongReqSyntax
 				}
 			}
 		}
 
-		if len(cmd.Res) > 0 {
-			pattern.resItems = ItemsFromConfig(string(cmd.Res))
+		lines := cmd.Lines
+		if len(lines) == 0 && len(cmd.Res) > 0 {
+			lines = [][]byte{cmd.Res}
+		}
+		for _, line := range lines {
+			items, err := compileResponse(line)
+			if err != nil {
+				return nil, err
+			}
+			pattern.resItems = append(pattern.resItems, items...)
+			pattern.resLines = append(pattern.resLines, items)
+		}
 
-			for _, item := range pattern.resItems {
-				if item.typ == ItemIllegal || item.typ == ItemError {
-					return nil, ErrWrongResSyntax
-				}
+		for kind, res := range map[string][]byte{
+			protocol.FailInvalid:    cmd.OnInvalid,
+			protocol.FailOutOfRange: cmd.OnOutOfRange,
+			protocol.FailTypeError:  cmd.OnTypeError,
+		} {
+			if len(res) == 0 {
+				continue
+			}
+			items, err := compileResponse(res)
+			if err != nil {
+				return nil, err
 			}
+			if pattern.failures == nil {
+				pattern.failures = make(map[string][]Item)
+			}
+			pattern.failures[kind] = items
 		}
 
 		patterns[key] = pattern
@@ -245,53 +472,91 @@ func buildCommandPatterns(commands map[string]*command.Command) (map[string]Comm
 	return patterns, nil
 }
 
-func isAlphaNumericParse(b byte) bool {
-	return '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || b == '_' || b == '+' || b == '-'
+// Check if parameters used in responses exist, so typos like {%d:current-1} instead of {%d:current - 1} are reported at start
+func checkResponseParams(patterns map[string]CommandPattern, params map[string]parameter.Parameter) error {
+	for cmdName, pattern := range patterns {
+		items := pattern.resItems
+		for _, failure := range pattern.failures {
+			items = append(items, failure...)
+		}
+		for _, ref := range responseParams(items) {
+			name, _, _ := strings.Cut(ref, "[")
+			// indexes and special placeholders like _error are not parameters
+			if isIndex(name) || strings.HasPrefix(name, "_") {
+				continue
+			}
+			if _, exists := params[name]; !exists {
+				err := fmt.Errorf("%w: unknown parameter %s in response of %s", ErrWrongResSyntax, ref, cmdName)
+				if strings.Contains(name, "-") {
+					err = fmt.Errorf("%w, - has to be surrounded with spaces in expressions", err)
+				}
+				return err
+			}
+		}
+	}
+	return nil
 }
 
-func checkPattern(input string, items []Item) (bool, map[string]any) {
-	var values = map[string]any{}
-	var value any
+// Items of the response with compiled expressions
+func compileResponse(res []byte) ([]Item, error) {
+	items := ItemsFromConfig(string(res))
+	for i, item := range items {
+		switch item.typ {
+		case ItemIllegal, ItemError:
+			return nil, ErrWrongResSyntax
+		case ItemExpr:
+			expr, err := compileExpr(item.val)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrWrongResSyntax, err)
+			}
+			items[i].expr = expr
+		}
+	}
+	return items, nil
+}
 
+// Names of parameters the response refers to, including those used in expressions
+func responseParams(items []Item) []string {
+	var names []string
 	for _, item := range items {
 		switch item.Type() {
-		case ItemCommand,
-			ItemWhiteSpace:
-			if len(input) < len(item.Value()) {
-				return false, nil
-			}
-
-			if input[:len(item.Value())] == item.Value() {
-
-				next, found := strings.CutPrefix(input, item.Value())
-				if !found {
-					return false, nil
-				}
-				input = next
-
-				continue
-			}
+		case ItemParam:
+			names = append(names, item.Value())
+		case ItemExpr:
+			names = append(names, exprRefs(item.expr)...)
+		}
+	}
+	return names
+}
 
-			return false, nil
-		case ItemStringValuePlaceholder:
-			out := parseString(input)
-			value = out
+// Check if input matches request items and return received values,
+// values of whole array parameters are lists of elements separated with separator.
+// Literals of the request are matched by the given function
+func matchPattern(input string, items []Item, separators map[string]string, signed map[string]bool, literal literalFunc) (bool, map[string]any) {
+	var values = map[string]any{}
+	var value any
 
-			next, found := strings.CutPrefix(input, out)
-			if !found {
+	for i, item := range items {
+		switch item.Type() {
+		case ItemCommand,
+			ItemWhiteSpace,
+			ItemEscape,
+			ItemByte:
+			n, ok := literal(input, item.Value())
+			if !ok {
 				return false, nil
 			}
-			input = next
+			input = input[n:]
 			continue
-
-		case ItemNumberValuePlaceholder:
-			out := parseNumber(input)
-			value = out
-			next, found := strings.CutPrefix(input, out)
-			if !found {
+		case ItemStringValuePlaceholder,
+			ItemNumberValuePlaceholder:
+			// value is parsed according to verb, width and precision of the placeholder
+			n, out, ok := placeholderScanner(item, items[i+1:], separators, signed)(input)
+			if !ok {
 				return false, nil
 			}
-			input = next
+			value = out
+			input = input[n:]
 			continue
 
 		case ItemParam:
@@ -314,65 +579,144 @@ func checkPattern(input string, items []Item) (bool, map[string]any) {
 	return true, values
 }
 
-func parseNumber(s string) string {
-	pos := 0
-	peek := func() byte {
-		if pos < len(s) {
-			return s[pos]
+// Scanner of the value of the placeholder, it is parsed according to verb, width and precision
+// and whole array parameters are lists of elements separated with separator
+func placeholderScanner(item Item, rest []Item, separators map[string]string, signed map[string]bool) scanFunc {
+	f := parseFormat(item.Value())
+	param := nextParam(rest)
+	base, _, _ := strings.Cut(param, "[")
+	f.signed = signed[base]
+	if sep, isArray := separators[param]; isArray {
+		return scanList(sep, f.scanner(sep))
+	}
+	return f.scanner("")
+}
+
+// Mark the request as type error of the command when literals of its request match the input
+// but the value cannot be scanned, e.g. CUR high for CUR {%d:current}.
+// Only commands with on_type_error are checked, other requests stay unknown.
+func (p *Parser) typeError(tx *protocol.Transaction, input string, term []byte) bool {
+	var names []string
+	for cmdName, pattern := range p.commandPatterns {
+		if _, exists := pattern.failures[protocol.FailTypeError]; exists && p.terminatedBy(pattern, term) {
+			names = append(names, cmdName)
 		}
-		return 0
 	}
-	accept := func(chars string) bool {
-		if strings.ContainsRune(chars, rune(peek())) {
-			pos++
-			return true
+	// the longest request wins like for matched requests
+	sort.Slice(names, func(i, j int) bool {
+		li, lj := len(p.commandPatterns[names[i]].reqItems), len(p.commandPatterns[names[j]].reqItems)
+		if li != lj {
+			return li > lj
 		}
-		return false
+		return names[i] < names[j]
+	})
+
+	literal := matchLiteral
+	if p.scpi {
+		literal = matchSCPI
 	}
-	acceptRun := func(chars string) {
-		for strings.ContainsRune(chars, rune(peek())) {
-			pos++
+	for _, cmdName := range names {
+		param, value, failed := scanFailure(input, p.commandPatterns[cmdName].reqItems, p.separators, p.signed, literal)
+		if !failed {
+			continue
 		}
+		tx.Typ = protocol.TxMismatch
+		tx.CommandName = cmdName
+		// current value of the parameter can be used in the response
+		tx.Payload[param] = nil
+		tx.Failure = &protocol.Failure{Kind: protocol.FailTypeError, Param: param, Value: value}
+		return true
 	}
+	return false
+}
 
-	// Optional leading sign.
-	accept("+-")
-	// Is it hex?
-	digits := "0123456789"
-	if accept("0") && accept("xX") {
-		digits = "0123456789abcdefABCDEF"
-		acceptRun(digits)
-		if isAlphaNumericParse(peek()) {
-			return ""
+// Find the placeholder that cannot scan the input while all literals before it match,
+// returns the name of its parameter and the received value
+func scanFailure(input string, items []Item, separators map[string]string, signed map[string]bool, literal literalFunc) (string, string, bool) {
+	for i, item := range items {
+		switch item.Type() {
+		case ItemCommand,
+			ItemWhiteSpace,
+			ItemEscape,
+			ItemByte:
+			n, ok := literal(input, item.Value())
+			if !ok {
+				return "", "", false
+			}
+			input = input[n:]
+		case ItemStringValuePlaceholder,
+			ItemNumberValuePlaceholder:
+			n, _, ok := placeholderScanner(item, items[i+1:], separators, signed)(input)
+			if !ok {
+				param := nextParam(items[i+1:])
+				if param == "" || isIndex(param) {
+					return "", "", false
+				}
+				return param, failedValue(input, items[i+1:]), true
+			}
+			input = input[n:]
+		case ItemParam, ItemLeftMeta, ItemRightMeta:
+		default:
+			return "", "", false
 		}
-		return s[:pos]
-	}
-	// Is it hex without 0x
-	backupPos := pos
-	digits = "0123456789abcdfABCDF"
-	acceptRun(digits)
-	// if something left it means that it was not hex
-	// going back and start again with decimal format
-	if isAlphaNumericParse(peek()) {
-		pos = backupPos
 	}
+	return "", "", false
+}
 
-	digits = "0123456789"
-	acceptRun(digits)
-	if accept(".") {
-		acceptRun(digits)
+// Received value that could not be scanned, it ends where literals following the placeholder start
+func failedValue(input string, rest []Item) string {
+	var suffix string
+	for _, item := range rest {
+		switch item.Type() {
+		case ItemCommand, ItemWhiteSpace, ItemEscape, ItemByte:
+			suffix += item.Value()
+		case ItemParam, ItemRightMeta:
+		default:
+			// another placeholder follows, the value ends with a space
+			return parseString(input)
+		}
 	}
-	if accept("eE") {
-		accept("+-")
-		acceptRun("0123456789")
+	if value, found := strings.CutSuffix(input, suffix); found {
+		return value
 	}
-	// Is it imaginary?
-	accept("i")
-	// Next thing mustn't be alphanumeric.
-	if isAlphaNumericParse(peek()) {
-		return ""
+	return parseString(input)
+}
+
+// Name of the parameter the placeholder refers to
+func nextParam(items []Item) string {
+	for _, item := range items {
+		switch item.Type() {
+		case ItemParam:
+			return item.Value()
+		case ItemRightMeta:
+			return ""
+		}
+	}
+	return ""
+}
+
+// Scan elements separated by separator, it returns the longest list of elements matching the input
+func scanList(sep string, scan scanFunc) scanFunc {
+	return func(s string) (int, string, bool) {
+		var vals []string
+		pos := 0
+		for {
+			n, val, ok := scan(s[pos:])
+			if !ok || n == 0 {
+				return pos, strings.Join(vals, sep), true
+			}
+			pos += n
+			vals = append(vals, val)
+			if sep == "" || !strings.HasPrefix(s[pos:], sep) {
+				return pos, strings.Join(vals, sep), true
+			}
+			// separator is included only when followed by the element
+			if n, _, ok := scan(s[pos+len(sep):]); !ok || n == 0 {
+				return pos, strings.Join(vals, sep), true
+			}
+			pos += len(sep)
+		}
 	}
-	return s[:pos]
 }
 
 func parseString(input string) string {
@@ -390,11 +734,12 @@ func parseString(input string) string {
 	return output
 }
 
-func constructOutput(items []Item, payload map[string]any) []byte {
+// Build response from items, indexes replace index names in references to array elements
+func constructOutput(items []Item, payload map[string]any, separators map[string]string, indexes map[string]int) []byte {
 	var (
-		out    []byte
-		temp   string
-		format string
+		out  []byte
+		temp string
+		f    format
 	)
 	for _, i := range items {
 		switch i.Type() {
@@ -402,20 +747,62 @@ func constructOutput(items []Item, payload map[string]any) []byte {
 			ItemWhiteSpace:
 			temp += i.Value()
 
+		case ItemLeftMeta:
+			f = format{prec: -1}
+
 		case ItemNumberValuePlaceholder,
 			ItemStringValuePlaceholder:
-			format = i.Value()
+			f = parseFormat(i.Value())
+
+		case ItemExpr:
+			val, err := i.expr.eval(func(name string) any {
+				if isIndex(name) {
+					if idx, exists := indexes[name]; exists {
+						return idx
+					}
+					return nil
+				}
+				return payload[resolveIndexes(name, indexes)]
+			})
+			if err != nil {
+				log.ERR(err.Error(), "expr", i.Value())
+				continue
+			}
+			// expressions without placeholder are printed in the default format
+			if f.verb == 0 {
+				temp += fmt.Sprint(val)
+				continue
+			}
+			temp += f.sprint(coerce(f, val))
 
 		case ItemParam:
+			if isIndex(i.Value()) {
+				temp += f.sprint(indexes[i.Value()])
+				continue
+			}
 
 			// Note:
 			// i.Value() hold the parameter name
 			// which the parameter instance itself can be retrieve from the vdfile.Params
 			// check the type of payload[i.Value()]
-			add := fmt.Sprintf(format, payload[i.Value()])
-			temp += add
+			name := resolveIndexes(i.Value(), indexes)
+			if elems, isArray := payload[name].([]any); isArray {
+				sep, exists := separators[name]
+				if !exists {
+					sep = parameter.DefaultSeparator
+				}
+				out := make([]string, len(elems))
+				for j, e := range elems {
+					out[j] = f.sprint(e)
+				}
+				temp += strings.Join(out, sep)
+				continue
+			}
+
+			temp += f.sprint(payload[name])
 
-		case ItemEscape:
+		case ItemEscape,
+			ItemByte:
 			temp += i.Value()
 		}
 
@@ -423,3 +810,63 @@ func constructOutput(items []Item, payload map[string]any) []byte {
 	out = []byte(temp)
 	return out
 }
+
+// Devices send negative numbers in hex, octal or binary as two's complement of the parameter width,
+// e.g. -1 of int16 is FFFF
+func unsignedForBase(format string, val any) any {
+	if format == "" || !strings.ContainsAny(format[len(format)-1:], "xXoOb") {
+		return val
+	}
+
+	switch v := val.(type) {
+	case int8:
+		return uint8(v)
+	case int16:
+		return uint16(v)
+	case int32:
+		return uint32(v)
+	case int64:
+		return uint64(v)
+	case int:
+		return uint(v)
+	}
+	return val
+}
+
+// Check if name of the placeholder is an index captured from the request
+func isIndex(name string) bool {
+	return strings.HasPrefix(name, "#")
+}
+
+// Remove indexes from received values and convert them to numbers, returns false when index is not a number
+func takeIndexes(values map[string]any) (map[string]int, bool) {
+	var indexes map[string]int
+	for name, val := range values {
+		if !isIndex(name) {
+			continue
+		}
+
+		idx, err := strconv.Atoi(fmt.Sprint(val))
+		if err != nil {
+			return nil, false
+		}
+		if indexes == nil {
+			indexes = make(map[string]int)
+		}
+		indexes[name] = idx
+		delete(values, name)
+	}
+	return indexes, true
+}
+
+// Replace index names in reference to array element with their values, e.g. voltage[#ch] to voltage[3]
+func resolveIndexes(name string, indexes map[string]int) string {
+	if len(indexes) == 0 || !strings.Contains(name, "[#") {
+		return name
+	}
+
+	for idx, val := range indexes {
+		name = strings.ReplaceAll(name, "["+idx+"]", "["+strconv.Itoa(val)+"]")
+	}
+	return name
+}
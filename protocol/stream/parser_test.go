@@ -3,13 +3,13 @@ package stream
 import (
 	"bytes"
 	"errors"
+	"regexp"
 	"testing"
 
-	"github.com/google/go-cmp/cmp"
-
 	"github.com/e9ctrl/vd/command"
 	"github.com/e9ctrl/vd/protocol"
 	"github.com/e9ctrl/vd/vdfile"
+	"github.com/google/go-cmp/cmp"
 )
 
 const FILE1 = "../../vdfile/vdfile"
@@ -37,7 +37,7 @@ func TestDecode(t *testing.T) {
 		{"empty command", []byte(""), []protocol.Transaction{{Typ: protocol.TxUnknown, CommandName: ""}}},
 		{"non-existent command", []byte("test 30.0"), []protocol.Transaction{{Typ: protocol.TxUnknown, CommandName: ""}}},
 		{"set current command", []byte("CUR 30"), []protocol.Transaction{{Typ: protocol.TxSetParam, CommandName: "set_current", Payload: map[string]any{"current": 30}}}},
-		{"wrong value of the command", []byte("CUR 30.0"), []protocol.Transaction{{Typ: protocol.TxSetParam, CommandName: "set_current", Payload: map[string]any{"current": 30}}}},
+		{"wrong value of the command", []byte("CUR 30.0"), []protocol.Transaction{{Typ: protocol.TxUnknown, CommandName: ""}}},
 		{"set command with opt", []byte(":PULSE0:MODE SING"), []protocol.Transaction{{Typ: protocol.TxSetParam, CommandName: "set_mode", Payload: map[string]any{"mode": "SING"}}}},
 		{"wrong opt of the command", []byte(":PULSE0:MODE TEST"), []protocol.Transaction{{Typ: protocol.TxSetParam, CommandName: "set_mode", Payload: map[string]any{"mode": "TEST"}}}},
 		{"set hex", []byte("HEX 0x03F"), []protocol.Transaction{{Typ: protocol.TxSetParam, CommandName: "set_hex", Payload: map[string]any{"hex": 0x03F}}}},
@@ -61,6 +61,269 @@ func TestDecode(t *testing.T) {
 	}
 }
 
+func TestDecodeIndexes(t *testing.T) {
+	t.Parallel()
+	vd, err := vdfile.ReadVDFileFromBytes([]byte(`
+interm = "LF"
+
+[[parameter]]
+  name = "voltage[1..16]"
+  typ = "float"
+  val = 0.0
+
+[[command]]
+  name = "set_voltage"
+  req = "VSET {%d:#ch},{%f:voltage[#ch]}"
+  res = "OK"
+
+[[command]]
+  name = "get_voltage"
+  req = "VMON? {%d:#ch}"
+  res = "{%.1f:voltage[#ch]}"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewParser(vd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		data  string
+		expTx protocol.Transaction
+	}{
+		{"set", "VSET 3,120.5\n", protocol.Transaction{Typ: protocol.TxSetParam, CommandName: "set_voltage", Payload: map[string]any{"voltage[3]": "120.5"}, Indexes: map[string]int{"#ch": 3}}},
+		{"get", "VMON? 12\n", protocol.Transaction{Typ: protocol.TxGetParam, CommandName: "get_voltage", Payload: map[string]any{"voltage[12]": nil}, Indexes: map[string]int{"#ch": 12}}},
+		{"index not a number", "VMON? 1.5\n", protocol.Transaction{Typ: protocol.TxUnknown, Payload: map[string]any{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txs, err := p.Decode([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			txs[0].Raw, txs[0].Term = nil, nil
+			if diff := cmp.Diff(tt.expTx, txs[0]); diff != "" {
+				t.Errorf("unexpected transaction (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	txs, _ := p.Decode([]byte("VMON? 7\n"))
+	txs[0].Payload["voltage[7]"] = 1.25
+	if out, _ := p.Encode(txs); string(out) != "1.2" {
+		t.Errorf("exp response 1.2 got %q", out)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	t.Parallel()
+	terms := framing{terminators: [][]byte{[]byte("\r\n"), []byte("\n"), []byte(";")}}
+	tests := []struct {
+		name      string
+		framing   framing
+		data      string
+		flush     bool
+		expFrames []string
+		expRest   string
+	}{
+		{"terminators", terms, "A?\r\nB?;C?\n", false, []string{"A?", "B?", "C?"}, ""},
+		{"incomplete request", terms, "A?;B", false, []string{"A?"}, "B"},
+		{"flushed request", terms, "A?;B", true, []string{"A?", "B"}, ""},
+		{"no terminators", framing{}, "A?\n", false, nil, "A?\n"},
+		{"no terminators flushed", framing{}, "A?\n", true, []string{"A?\n"}, ""},
+		{"fixed", framing{mode: vdfile.FramingFixed, length: 3}, "ABCDEFGH", false, []string{"ABC", "DEF"}, "GH"},
+		{"idle", framing{mode: vdfile.FramingIdle}, "A?", false, nil, "A?"},
+		{"idle flushed", framing{mode: vdfile.FramingIdle}, "A?", true, []string{"A?"}, ""},
+		{"regex", framing{mode: vdfile.FramingRegex, regex: regexp.MustCompile(`\x02(.*?)\x03`)}, "x\x02A?\x03\x02B?\x03\x02C", false, []string{"A?", "B?"}, "\x02C"},
+		{"regex without group", framing{mode: vdfile.FramingRegex, regex: regexp.MustCompile(`[A-Z]\?`)}, "A?B?", false, []string{"A?", "B?"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, rest := tt.framing.split([]byte(tt.data), tt.flush)
+			var got []string
+			for _, f := range frames {
+				got = append(got, string(f.data))
+			}
+			if diff := cmp.Diff(tt.expFrames, got); diff != "" {
+				t.Errorf("unexpected frames (-want +got):\n%s", diff)
+			}
+			if string(rest) != tt.expRest {
+				t.Errorf("exp rest %q got %q", tt.expRest, rest)
+			}
+		})
+	}
+}
+
+func TestDecodeCommandTerminator(t *testing.T) {
+	t.Parallel()
+	vd, err := vdfile.ReadVDFileFromBytes([]byte(`
+interm = "CR LF"
+
+[[command]]
+  name = "get_status"
+  req = "STAT?"
+  res = "OK"
+
+[[command]]
+  name = "get_status_short"
+  req = "STAT?"
+  res = "1"
+  interm = "ETX"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewParser(vd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txs, err := p.Decode([]byte("STAT?\r\nSTAT?\x03"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 2 || txs[0].CommandName != "get_status" || txs[1].CommandName != "get_status_short" {
+		t.Errorf("unexpected transactions %v", txs)
+	}
+}
+
+func TestSplitCompound(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		input string
+		exp   []string
+	}{
+		{"single", "SOUR:VOLT 1", []string{"SOUR:VOLT 1"}},
+		{"relative path", "SOUR:VOLT 1;CURR 2", []string{"SOUR:VOLT 1", "SOUR:CURR 2"}},
+		{"absolute path", "SOUR:VOLT 1;:MEAS:CURR?", []string{"SOUR:VOLT 1", "MEAS:CURR?"}},
+		{"common command keeps path", "SOUR:VOLT 1;*OPC;CURR 2", []string{"SOUR:VOLT 1", "*OPC", "SOUR:CURR 2"}},
+		{"quoted semicolon", `DISP:TEXT "a;b";*IDN?`, []string{`DISP:TEXT "a;b"`, "*IDN?"}},
+		{"empty commands", "*RST;;*CLS;", []string{"*RST", "*CLS"}},
+		{"empty request", "", []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitCompound(tt.input)
+			if diff := cmp.Diff(tt.exp, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMatchSCPI(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		input string
+		lit   string
+		exp   int
+		match bool
+	}{
+		{"short form", "VOLT?", "VOLTage?", 5, true},
+		{"long form", "voltage?", "VOLTage?", 8, true},
+		{"path", "sour:volt:lev 1", "SOURce:VOLTage:LEVel", 13, true},
+		{"upper case mnemonic", "idn?", "IDN?", 4, true},
+		{"partial form", "VOLTA?", "VOLTage?", 0, false},
+		{"missing query", "VOLT", "VOLTage?", 0, false},
+		{"numeric suffix", "CHAN2", "CHANnel2", 5, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, ok := matchSCPI(tt.input, tt.lit)
+			if n != tt.exp || ok != tt.match {
+				t.Errorf("exp %d %v got %d %v", tt.exp, tt.match, n, ok)
+			}
+		})
+	}
+}
+
+func TestCompound(t *testing.T) {
+	t.Parallel()
+	vd, err := vdfile.ReadVDFileFromBytes([]byte(`
+profile = "scpi"
+
+[[parameter]]
+  name = "voltage"
+  typ = "float64"
+  val = 1.5
+
+[[parameter]]
+  name = "current"
+  typ = "float64"
+  val = 0.2
+
+[[command]]
+  name = "get_voltage"
+  req = "SOURce:VOLTage?"
+  res = "{%.1f:voltage}"
+
+[[command]]
+  name = "set_voltage"
+  req = "SOURce:VOLTage {%f:voltage}"
+
+[[command]]
+  name = "get_current"
+  req = "SOURce:CURRent?"
+  res = "{%.1f:current}"
+
+[[command]]
+  name = "get_pulse_mode"
+  req = ":PULSe0:MODE?"
+  res = "{%.1f:current}"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewParser(vd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// leading colon is optional in requests and in the vdfile
+	for _, req := range []string{":PULS0:MODE?\n", "PULSE0:MODE?\n"} {
+		txs, err := p.Decode([]byte(req))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(txs) != 1 || txs[0].CommandName != "get_pulse_mode" {
+			t.Errorf("%q: exp get_pulse_mode got %v", req, txs)
+		}
+	}
+
+	txs, err := p.Decode([]byte("sour:volt 2.5;VOLT?;:SOURCE:CURRENT?\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tx := range txs {
+		names = append(names, tx.CommandName)
+	}
+	if diff := cmp.Diff([]string{"set_voltage", "get_voltage", "get_current"}, names); diff != "" {
+		t.Fatalf("mismatch (-want +got):\n%s", diff)
+	}
+	if !txs[0].More || !txs[1].More || txs[2].More {
+		t.Errorf("exp all but the last transaction followed by more")
+	}
+
+	txs[1].Payload["voltage"] = 2.5
+	txs[2].Payload["current"] = 0.2
+	res, err := p.Encode(txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res) != "2.5;0.2\n" {
+		t.Errorf("exp joined replies got %q", res)
+	}
+}
+
 func TestBuildCommandPatterns(t *testing.T) {
 	input := map[string]*command.Command{}
 	cmd1 := &command.Command{
@@ -242,7 +505,25 @@ func TestBuildCommandPatternsResErr(t *testing.T) {
 	}
 }
 
-func TestCheckPattern(t *testing.T) {
+func TestBuildCommandPatternsFailureErr(t *testing.T) {
+	input := map[string]*command.Command{}
+	cmd1 := &command.Command{
+		Name:      "current_set",
+		Req:       []byte("set curr {%d:current}"),
+		OnInvalid: []byte("wrong {%3z.2f:_value}"),
+	}
+	input["current_set"] = cmd1
+
+	cmdPattern, err := buildCommandPatterns(input)
+	if cmdPattern != nil {
+		t.Error("patterns should be empty")
+	}
+	if !errors.Is(ErrWrongResSyntax, err) {
+		t.Errorf("exp error: %v got %v", ErrWrongResSyntax, err)
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
 		name   string
@@ -257,19 +538,28 @@ func TestCheckPattern(t *testing.T) {
 		{"Simple set", "volt {%3.2f:voltage}", "volt 34.45", true, map[string]any{"voltage": "34.45"}},
 		{"Complex set", "set ch1 max {%2d:max}", "set ch1 max 35", true, map[string]any{"max": "35"}},
 		{"Placeholder between", "set ch1 {%2.2f:power} pow", "set ch1 34.56 pow", true, map[string]any{"power": "34.56"}},
-		//Note: we need to more strict checking on different type of placeholder
-		//{"Wrong input", "set voltage {%d:voltage}", "set voltage 20.45", false, map[string]any{}},
+		{"Wrong input", "set voltage {%d:voltage}", "set voltage 20.45", false, nil},
 		{"Command not found", "get temp?", "set voltage 20", false, nil},
 		{"Wrong value", "set current {%03X:current}", "set current test", false, nil},
 		{"Too many elements", "TEMP?", "TEMP?asdf", false, nil},
-		{"Set hex", "HEX 0x{%03X:hex}", "HEX 0x03F", true, map[string]any{"hex": "03F"}},
+		{"Set hex", "HEX 0x{%03X:hex}", "HEX 0x03F", true, map[string]any{"hex": "63"}},
+		{"Fixed width fields", "T{%03d:a}{%03d:b}", "T001020", true, map[string]any{"a": "001", "b": "020"}},
+		{"Fixed width hex fields", "T{%02X:a}{%02X:b}", "T0AFF", true, map[string]any{"a": "10", "b": "255"}},
+		{"Quoted string", "NAME {%q:name}", `NAME "Power Supply 1"`, true, map[string]any{"name": "Power Supply 1"}},
+		{"Charset", "ID {%[A-Z0-9]:id}-{%d:n}", "ID AB12-3", true, map[string]any{"id": "AB12", "n": "3"}},
+		{"Charset not matched", "ID {%[A-Z]:id}", "ID ab", false, nil},
+		{"Bool", "ACK {%t:ack}", "ACK true", true, map[string]any{"ack": "true"}},
+		{"Wrong bool", "ACK {%t:ack}", "ACK 1", false, nil},
+		{"Scientific notation", "VAL {%e:val}", "VAL 1.5e-3", true, map[string]any{"val": "1.5e-3"}},
+		{"Framed raw value", `\x02CNT{%r2be:counter}\x03`, "\x02CNT\x01\x00\x03", true, map[string]any{"counter": "256"}},
+		{"Wrong frame", `\x02CNT{%r2be:counter}\x03`, "\x02CNT\x01\x00\x04", false, nil},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			items := ItemsFromConfig(tt.forLex)
 
-			got, values := checkPattern(tt.input, items)
+			got, values := matchPattern(tt.input, items, nil, nil, matchLiteral)
 			if got != tt.exp {
 				t.Errorf("exp bool: %t got: %t\n", tt.exp, got)
 				return
@@ -298,29 +588,99 @@ func TestCheckPattern(t *testing.T) {
 
 }
 
-func TestParseNumber(t *testing.T) {
+func TestMatchPatternArray(t *testing.T) {
 	t.Parallel()
+	separators := map[string]string{"wave": ",", "names": ";"}
 	tests := []struct {
-		name  string
-		input string
-		exp   string
+		name   string
+		forLex string
+		input  string
+		exp    bool
+		expVal map[string]any
 	}{
-		{"Small scientific notation", "3e-10", "3e-10"},
-		{"Big scientific notation", "4.5e6", "4.5e6"},
-		{"Big hex", "0xFF", "0xFF"},
-		{"Small hex", "0xaa", "0xaa"},
-		{"Imaginary number", "5.2i", "5.2i"},
-		{"Standard float", "34.567", "34.567"},
-		{"Standard decimal", "20", "20"},
-		{"Wrong hex", "0xx43", ""},
-		{"Wrong scientific notation", "44e-f5", ""},
+		{"numbers", "WAVE {%f:wave}", "WAVE 1.2,3.4,5.6", true, map[string]any{"wave": "1.2,3.4,5.6"}},
+		{"single number", "WAVE {%f:wave}", "WAVE 1.2", true, map[string]any{"wave": "1.2"}},
+		{"numbers followed by text", "WAVE {%f:wave} END", "WAVE 1,2 END", true, map[string]any{"wave": "1,2"}},
+		{"trailing separator", "WAVE {%f:wave}", "WAVE 1,2,", false, nil},
+		{"strings", "NAMES {%s:names}", "NAMES a;b;c", true, map[string]any{"names": "a;b;c"}},
+		{"element", "CHAN3 {%f:wave[3]}", "CHAN3 1.5", true, map[string]any{"wave[3]": "1.5"}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := parseNumber(tt.input)
+			got, values := matchPattern(tt.input, ItemsFromConfig(tt.forLex), separators, nil, matchLiteral)
 			if got != tt.exp {
-				t.Errorf("exp string: %s got: %s\n", tt.exp, got)
+				t.Fatalf("exp bool: %t got: %t\n", tt.exp, got)
+			}
+			if len(values) != len(tt.expVal) {
+				t.Fatalf("exp values: %v got: %v\n", tt.expVal, values)
+			}
+			for k, v := range tt.expVal {
+				if values[k] != v {
+					t.Errorf("exp value: %v got: %v\n", v, values[k])
+				}
+			}
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		expN   int
+		expVal string
+		expOk  bool
+	}{
+		{"decimal", "%d", "20 mA", 2, "20", true},
+		{"negative decimal", "%d", "-20", 3, "-20", true},
+		{"decimal not float", "%d", "30.0", 2, "30", true},
+		{"decimal from hex", "%d", "FF", 0, "", false},
+		{"fixed width decimal", "%03d", "001002", 3, "001", true},
+		{"fixed width shorter value", "%03d", "7", 1, "7", true},
+		{"space padded decimal", "%4d", "  42", 4, "42", true},
+		{"left aligned decimal", "%-4d", "42  x", 4, "42", true},
+		{"hex", "%X", "FF", 2, "255", true},
+		{"hex with prefix", "%#x", "0xff", 4, "255", true},
+		{"fixed width hex", "%03X", "07A1F", 3, "122", true},
+		{"wrong hex", "%x", "0xx43", 0, "", false},
+		{"octal", "%o", "17", 2, "15", true},
+		{"binary", "%b", "0b101", 5, "5", true},
+		{"binary stops at other digits", "%b", "1012", 3, "5", true},
+		{"uint64 hex", "%X", "FFFFFFFFFFFFFFFF", 16, "18446744073709551615", true},
+		{"standard float", "%f", "34.567", 6, "34.567", true},
+		{"float without fraction", "%.2f", "34", 2, "34", true},
+		{"small scientific notation", "%e", "3e-10", 5, "3e-10", true},
+		{"big scientific notation", "%e", "4.5E6", 5, "4.5E6", true},
+		{"incomplete exponent", "%g", "44e-f5", 2, "44", true},
+		{"float from text", "%f", "abc", 0, "", false},
+		{"char", "%c", "A1", 1, "65", true},
+		{"bool", "%t", "true", 4, "true", true},
+		{"wrong bool", "%t", "yes", 0, "", false},
+		{"string", "%s", "test1 test2", 5, "test1", true},
+		{"bounded string", "%4s", "test1", 4, "test", true},
+		{"padded string", "%6s", "  test", 6, "test", true},
+		{"quoted string", "%q", `"two words\n" rest`, 13, "two words\n", true},
+		{"unterminated quoted string", "%q", `"two words`, 0, "", false},
+		{"charset", "%[A-Z0-9]", "AB12cd", 4, "AB12", true},
+		{"negated charset", "%[^,]", "two words,3", 9, "two words", true},
+		{"charset with width", "%2[A-Z]", "ABC", 2, "AB", true},
+		{"charset not matched", "%[0-9]", "abc", 0, "", false},
+		{"raw big endian", "%r2be", "\x12\x34\x56", 2, "4660", true},
+		{"raw little endian", "%r2le", "\x12\x34", 2, "13330", true},
+		{"raw default single byte", "%r", " ", 1, "32", true},
+		{"raw too short", "%r4be", "\x12\x34", 0, "", false},
+		{"bcd", "%r2bcd", "\x12\x34", 2, "1234", true},
+		{"wrong bcd", "%r1bcd", "\x1A", 0, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, val, ok := parseFormat(tt.format).scanner("")(tt.input)
+			if ok != tt.expOk || n != tt.expN || val != tt.expVal {
+				t.Errorf("exp: %d %q %t got: %d %q %t", tt.expN, tt.expVal, tt.expOk, n, val, ok)
 			}
 		})
 	}
@@ -358,6 +718,12 @@ func TestConstructOutput(t *testing.T) {
 	payload["version"] = "version"
 	payload["hex"] = 30
 	payload["max"] = 11.11
+	payload["status"] = uint16(0xFF)
+	payload["offset"] = int16(-2)
+	payload["trim"] = int8(7)
+	payload["mask"] = uint8(5)
+	payload["counter"] = uint16(0x1234)
+	payload["bcd"] = 1234
 
 	tests := []struct {
 		name  string
@@ -373,10 +739,48 @@ func TestConstructOutput(t *testing.T) {
 		{"empty lexer", []Item(nil), ""},
 		{"two params", ItemsFromConfig("{%s:version} - {%2.2f:max}"), "version - 11.11"},
 		{"hex param", ItemsFromConfig("HEX 0x{%03X:hex}"), "HEX 0x01E"},
+		{"uint16 hex param", ItemsFromConfig("STAT {%04X:status}"), "STAT 00FF"},
+		{"int16 negative hex param", ItemsFromConfig("OFF {%04X:offset}"), "OFF FFFE"},
+		{"int16 negative param", ItemsFromConfig("OFF {%+05d:offset}"), "OFF -0002"},
+		{"int8 positive param with sign", ItemsFromConfig("TRIM {%+d:trim}"), "TRIM +7"},
+		{"uint8 binary param", ItemsFromConfig("MASK {%08b:mask}"), "MASK 00000101"},
+		{"raw big endian", ItemsFromConfig(`\x02{%r2be:counter}\x03`), "\x02\x12\x34\x03"},
+		{"raw little endian", ItemsFromConfig("{%r4le:counter}"), "\x34\x12\x00\x00"},
+		{"raw negative", ItemsFromConfig("{%r2be:offset}"), "\xFF\xFE"},
+		{"bcd", ItemsFromConfig("{%r3bcd:bcd}"), "\x00\x12\x34"},
+		{"raw string", ItemsFromConfig("{%r1:version}"), "%!r(string=version)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := constructOutput(tt.items, payload, nil, nil)
+			if string(res) != tt.exp {
+				t.Errorf("exp output: %s got: %s", tt.exp, res)
+			}
+		})
+	}
+}
+
+func TestConstructOutputArray(t *testing.T) {
+	t.Parallel()
+	payload := map[string]any{
+		"wave":    []any{1.2, 3.4},
+		"mask":    []any{uint8(1), uint8(255)},
+		"wave[1]": 3.4,
+	}
+	separators := map[string]string{"wave": ",", "mask": " "}
+
+	tests := []struct {
+		name string
+		res  string
+		exp  string
+	}{
+		{"whole array", "WAVE {%.2f:wave}", "WAVE 1.20,3.40"},
+		{"separator", "MASK {%02X:mask}", "MASK 01 FF"},
+		{"element", "CH1 {%.1f:wave[1]}", "CH1 3.4"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			res := constructOutput(tt.items, payload)
+			res := constructOutput(ItemsFromConfig(tt.res), payload, separators, nil)
 			if string(res) != tt.exp {
 				t.Errorf("exp output: %s got: %s", tt.exp, res)
 			}
@@ -462,3 +866,70 @@ func TestTrigger(t *testing.T) {
 		})
 	}
 }
+
+func TestRawSigned(t *testing.T) {
+	t.Parallel()
+	vd, err := vdfile.ReadVDFileFromBytes([]byte(`
+interm = "LF"
+outterm = "LF"
+
+[[parameter]]
+  name = "offset"
+  typ = "int16"
+  val = -1
+
+[[parameter]]
+  name = "counter"
+  typ = "uint16"
+  val = 65535
+
+[[command]]
+  name = "get_offset"
+  req = "OFF?"
+  res = "OFF{%r2be:offset}"
+
+[[command]]
+  name = "set_offset"
+  req = "OFF{%r2be:offset}"
+
+[[command]]
+  name = "set_counter"
+  req = "CNT{%r2le:counter}"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewParser(vd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// negative value sent by the device is received back as the same value
+	out, err := p.Encode([]protocol.Transaction{{Typ: protocol.TxGetParam, CommandName: "get_offset", Payload: map[string]any{"offset": int16(-1)}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "OFF\xFF\xFF\n" {
+		t.Fatalf("exp two's complement got %q", out)
+	}
+
+	tests := []struct {
+		req   string
+		param string
+		exp   string
+	}{
+		{string(out), "offset", "-1"},
+		{"OFF\x80\x00\n", "offset", "-32768"},
+		{"OFF\x7F\xFF\n", "offset", "32767"},
+		{"CNT\xFF\xFF\n", "counter", "65535"},
+	}
+	for _, tt := range tests {
+		txs, err := p.Decode([]byte(tt.req))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(txs) != 1 || txs[0].Payload[tt.param] != tt.exp {
+			t.Errorf("%q: exp %s = %s got %v", tt.req, tt.param, tt.exp, txs)
+		}
+	}
+}
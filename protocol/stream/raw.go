@@ -0,0 +1,100 @@
+package stream
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Encodings of raw %r placeholders
+const (
+	encBigEndian    = "be"
+	encLittleEndian = "le"
+	// packed binary coded decimal, two digits per byte
+	encBCD = "bcd"
+)
+
+// Raw values are kept in uint64
+const maxRawSize = 8
+
+// Scan integer sent as size bytes, value is converted to decimal.
+// Binary values of signed parameters are sign extended like they are sent by formatRaw
+func scanRaw(size int, enc string, signed bool) scanFunc {
+	return func(s string) (int, string, bool) {
+		if size > maxRawSize || len(s) < size {
+			return 0, "", false
+		}
+		b := []byte(s[:size])
+
+		var v uint64
+		switch enc {
+		case encBigEndian:
+			for _, c := range b {
+				v = v<<8 | uint64(c)
+			}
+		case encLittleEndian:
+			for i := len(b) - 1; i >= 0; i-- {
+				v = v<<8 | uint64(b[i])
+			}
+		case encBCD:
+			for _, c := range b {
+				hi, lo := c>>4, c&0x0F
+				if hi > 9 || lo > 9 {
+					return 0, "", false
+				}
+				v = v*100 + uint64(hi)*10 + uint64(lo)
+			}
+		default:
+			return 0, "", false
+		}
+		if signed && enc != encBCD {
+			shift := 64 - 8*size
+			return size, strconv.FormatInt(int64(v<<shift)>>shift, 10), true
+		}
+		return size, strconv.FormatUint(v, 10), true
+	}
+}
+
+// Encode integer value as size bytes, negative values are sent as two's complement
+func formatRaw(size int, enc string, val any) string {
+	v, ok := rawUint(val)
+	if !ok || size > maxRawSize {
+		return fmt.Sprintf("%%!r(%T=%v)", val, val)
+	}
+
+	b := make([]byte, 8)
+	switch enc {
+	case encBigEndian:
+		binary.BigEndian.PutUint64(b, v)
+		return string(b[8-size:])
+	case encLittleEndian:
+		binary.LittleEndian.PutUint64(b, v)
+		return string(b[:size])
+	case encBCD:
+		// digits that do not fit are dropped like bits of too big binary values
+		b = make([]byte, size)
+		for i := size - 1; i >= 0; i-- {
+			b[i] = byte(v%10) | byte(v/10%10)<<4
+			v /= 100
+		}
+		return string(b)
+	}
+	return fmt.Sprintf("%%!r(%s=%v)", enc, val)
+}
+
+func rawUint(val any) (uint64, bool) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint(), true
+	case reflect.Bool:
+		if rv.Bool() {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
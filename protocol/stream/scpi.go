@@ -0,0 +1,153 @@
+package stream
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/e9ctrl/vd/vdfile"
+)
+
+// Profile of SCPI devices, NewParser cannot reach the vdfile package as its argument shadows it
+const profileSCPI = vdfile.ProfileSCPI
+
+// Function that matches literal of the request at the beginning of the input and returns number of consumed bytes
+type literalFunc func(input, lit string) (int, bool)
+
+// Literals of the request have to be received exactly as written
+func matchLiteral(input, lit string) (int, bool) {
+	if !strings.HasPrefix(input, lit) {
+		return 0, false
+	}
+	return len(lit), true
+}
+
+// Match literal of SCPI request case insensitively, mnemonics written in mixed case
+// like VOLTage match both the short form VOLT and the long form VOLTAGE
+func matchSCPI(input, lit string) (int, bool) {
+	pos := 0
+	for i := 0; i < len(lit); {
+		j := i
+		for j < len(lit) && isASCIILetter(lit[j]) {
+			j++
+		}
+		if j == i {
+			if pos >= len(input) || input[pos] != lit[i] {
+				return 0, false
+			}
+			pos++
+			i++
+			continue
+		}
+
+		word := lit[i:j]
+		matched := false
+		for _, form := range []string{word, shortForm(word)} {
+			if len(input)-pos >= len(form) && strings.EqualFold(input[pos:pos+len(form)], form) {
+				pos += len(form)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return 0, false
+		}
+		i = j
+	}
+	return pos, true
+}
+
+// Check if the whole SCPI header matches the pattern, e.g. syst:err? matches SYSTem:ERRor?
+func MatchHeader(header, pattern string) bool {
+	n, ok := matchSCPI(header, pattern)
+	return ok && n == len(header)
+}
+
+// Short form of the mnemonic is made of its upper case letters, e.g. VOLT of VOLTage
+func shortForm(word string) string {
+	upper := 0
+	for upper < len(word) && 'A' <= word[upper] && word[upper] <= 'Z' {
+		upper++
+	}
+	if upper == 0 || upper == len(word) || strings.ToLower(word[upper:]) != word[upper:] {
+		return word
+	}
+	return word[:upper]
+}
+
+func isASCIILetter(b byte) bool {
+	return ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+// Split compound SCPI request into commands separated by semicolons. Headers without leading colon
+// are relative to the path of the previous command, e.g. SOUR:VOLT 1;CURR 2 sets SOUR:CURR.
+// Common commands starting with asterisk do not change the path.
+func splitCompound(input string) []string {
+	var (
+		units []string
+		quote byte
+		start int
+	)
+	for i := 0; i < len(input); i++ {
+		switch {
+		case quote != 0:
+			if input[i] == quote {
+				quote = 0
+			}
+		case input[i] == '"' || input[i] == '\'':
+			quote = input[i]
+		case input[i] == ';':
+			units = append(units, input[start:i])
+			start = i + 1
+		}
+	}
+	units = append(units, input[start:])
+
+	var (
+		cmds []string
+		path string
+	)
+	for _, u := range units {
+		u = strings.TrimLeft(u, " \t")
+		switch {
+		case u == "":
+			continue
+		case strings.HasPrefix(u, "*"):
+			cmds = append(cmds, u)
+			continue
+		case strings.HasPrefix(u, ":"):
+			u = u[1:]
+		default:
+			u = path + u
+		}
+
+		header := u
+		if i := strings.IndexAny(u, " \t"); i >= 0 {
+			header = u[:i]
+		}
+		path = header[:strings.LastIndex(header, ":")+1]
+		cmds = append(cmds, u)
+	}
+
+	// empty request is decoded as it is
+	if len(cmds) == 0 {
+		return []string{input}
+	}
+	return cmds
+}
+
+// Remove leading colon from requests, it is optional in SCPI and splitCompound strips it from received
+// commands, so :PULSe0:MODE? is matched like PULSe0:MODE?
+func trimRootColon(patterns map[string]CommandPattern) {
+	for name, pattern := range patterns {
+		if len(pattern.reqItems) == 0 || pattern.reqItems[0].typ != ItemCommand || !strings.HasPrefix(pattern.reqItems[0].val, ":") {
+			continue
+		}
+		items := slices.Clone(pattern.reqItems)
+		items[0].val = items[0].val[1:]
+		if items[0].val == "" {
+			items = items[1:]
+		}
+		pattern.reqItems = items
+		patterns[name] = pattern
+	}
+}
@@ -0,0 +1,2 @@
+// scenario pkg runs declarative test scenarios against the simulator started in-process
+package scenario
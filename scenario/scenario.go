@@ -0,0 +1,366 @@
+package scenario
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/e9ctrl/vd/device"
+	"github.com/e9ctrl/vd/server"
+	"github.com/e9ctrl/vd/vdfile"
+)
+
+const defaultTimeout = time.Second
+
+var (
+	ErrNoAction        = errors.New("step has no action")
+	ErrManyActions     = errors.New("step has more than one action")
+	ErrNoReply         = errors.New("no reply received")
+	ErrNoNumber        = errors.New("no number found in reply")
+	ErrExpectNoReply   = errors.New("expectation given for step without reply")
+	ErrUnexpectedReply = errors.New("unexpected reply")
+)
+
+var numberRegex = regexp.MustCompile(`[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?`)
+
+// Step of the scenario, every step has exactly one action: send, set, assert, wait or trigger.
+// Replies to send and trigger are checked against expect, match or number, they are not read
+// when none of them is given. Empty expect checks that nothing is replied.
+type Step struct {
+	Name    string         `toml:"name"`
+	Send    *string        `toml:"send"`
+	Set     map[string]any `toml:"set"`
+	Assert  map[string]any `toml:"assert"`
+	Wait    string         `toml:"wait"`
+	Trigger string         `toml:"trigger"`
+
+	// Exact reply without out terminator
+	Expect *string `toml:"expect"`
+	// Regular expression the reply has to match
+	Match string `toml:"match"`
+	// Expected number, taken from the first group of match or the first number in the reply
+	Number *float64 `toml:"number"`
+	// Allowed difference of numbers in reply and assert
+	Tolerance float64 `toml:"tolerance"`
+	// Time to wait for the reply, scenario timeout is used when empty
+	Timeout string `toml:"timeout"`
+}
+
+// Content of the scenario file
+type Scenario struct {
+	Name string `toml:"name"`
+	// Path of the vdfile, relative to the scenario file
+	VDFile string `toml:"vdfile"`
+	// Default time to wait for replies
+	Timeout string `toml:"timeout"`
+	Steps   []Step `toml:"step"`
+}
+
+// Result of a single step
+type Result struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+func (r Result) Passed() bool { return r.Err == nil }
+
+// Read scenario from the given path, vdfile path is made relative to the scenario file
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var sc Scenario
+	if _, err := toml.Decode(string(data), &sc); err != nil {
+		return nil, err
+	}
+	if sc.Name == "" {
+		sc.Name = filepath.Base(path)
+	}
+	if sc.VDFile != "" && !filepath.IsAbs(sc.VDFile) {
+		sc.VDFile = filepath.Join(filepath.Dir(path), sc.VDFile)
+	}
+
+	return &sc, sc.validate()
+}
+
+func (sc *Scenario) validate() error {
+	if _, err := parseDuration(sc.Timeout, defaultTimeout); err != nil {
+		return err
+	}
+
+	for i, st := range sc.Steps {
+		actions := 0
+		for _, set := range []bool{st.Send != nil, st.Set != nil, st.Assert != nil, st.Wait != "", st.Trigger != ""} {
+			if set {
+				actions++
+			}
+		}
+		if actions == 0 {
+			return fmt.Errorf("step %d: %w", i+1, ErrNoAction)
+		}
+		if actions > 1 {
+			return fmt.Errorf("step %d: %w", i+1, ErrManyActions)
+		}
+		if st.Send == nil && st.Trigger == "" && (st.Expect != nil || st.Match != "" || st.Number != nil) {
+			return fmt.Errorf("step %d: %w", i+1, ErrExpectNoReply)
+		}
+		if st.Match != "" {
+			if _, err := regexp.Compile(st.Match); err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+		}
+		if _, err := parseDuration(st.Wait, 0); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+		if _, err := parseDuration(st.Timeout, 0); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Start the device described by vdfile on an ephemeral port and run all steps as its client.
+// Steps are run even if previous ones failed.
+func (sc *Scenario) Run(vd *vdfile.VDFile) ([]Result, error) {
+	dev, err := device.NewDevice(vd)
+	if err != nil {
+		return nil, err
+	}
+
+	srv, err := server.New(dev, "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	srv.Start()
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	r := &runner{
+		dev:     dev,
+		conn:    conn,
+		interm:  vd.InTerminator,
+		outterm: vd.OutTerminator,
+	}
+	r.timeout, _ = parseDuration(sc.Timeout, defaultTimeout)
+
+	results := make([]Result, 0, len(sc.Steps))
+	for i, st := range sc.Steps {
+		name := st.Name
+		if name == "" {
+			name = fmt.Sprintf("step %d", i+1)
+		}
+		start := time.Now()
+		err := r.run(st)
+		results = append(results, Result{Name: name, Err: err, Duration: time.Since(start)})
+	}
+
+	return results, nil
+}
+
+type runner struct {
+	dev     *device.StreamDevice
+	conn    net.Conn
+	interm  []byte
+	outterm []byte
+	timeout time.Duration
+}
+
+func (r *runner) run(st Step) error {
+	timeout, _ := parseDuration(st.Timeout, r.timeout)
+
+	switch {
+	case st.Send != nil:
+		r.drain()
+		if _, err := r.conn.Write(append([]byte(*st.Send), r.interm...)); err != nil {
+			return err
+		}
+		return r.expect(st, timeout)
+	case st.Trigger != "":
+		r.drain()
+		if err := r.trigger(st.Trigger, timeout); err != nil {
+			return err
+		}
+		return r.expect(st, timeout)
+	case st.Set != nil:
+		for _, name := range sortedKeys(st.Set) {
+			if err := r.dev.SetParameter(name, setValue(st.Set[name])); err != nil {
+				return fmt.Errorf("set %s: %w", name, err)
+			}
+		}
+	case st.Assert != nil:
+		for _, name := range sortedKeys(st.Assert) {
+			val, err := r.dev.GetParameter(name)
+			if err != nil {
+				return err
+			}
+			if !equal(val, st.Assert[name], st.Tolerance) {
+				return fmt.Errorf("parameter %s: exp %v got %v", name, st.Assert[name], val)
+			}
+		}
+	case st.Wait != "":
+		d, _ := parseDuration(st.Wait, 0)
+		time.Sleep(d)
+	}
+	return nil
+}
+
+// Trigger command, retried until timeout because the connection may not be handled by the server yet
+func (r *runner) trigger(name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := r.dev.Trigger(name)
+		if !errors.Is(err, device.ErrNoClient) || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (r *runner) expect(st Step, timeout time.Duration) error {
+	if st.Expect == nil && st.Match == "" && st.Number == nil {
+		return nil
+	}
+
+	reply, err := r.read(timeout)
+	noReply := st.Expect != nil && *st.Expect == "" && st.Match == "" && st.Number == nil
+	switch {
+	case noReply && errors.Is(err, ErrNoReply):
+		return nil
+	case err != nil:
+		return err
+	case noReply:
+		return fmt.Errorf("%w: %q", ErrUnexpectedReply, bytes.TrimSuffix(reply, r.outterm))
+	}
+	reply = bytes.TrimSuffix(reply, r.outterm)
+
+	if st.Expect != nil && string(reply) != *st.Expect {
+		return fmt.Errorf("exp reply %q got %q", *st.Expect, reply)
+	}
+
+	numStr := numberRegex.Find(reply)
+	if st.Match != "" {
+		m := regexp.MustCompile(st.Match).FindSubmatch(reply)
+		if m == nil {
+			return fmt.Errorf("reply %q does not match %q", reply, st.Match)
+		}
+		if len(m) > 1 {
+			numStr = m[1]
+		}
+	}
+
+	if st.Number != nil {
+		num, err := strconv.ParseFloat(string(numStr), 64)
+		if err != nil {
+			return fmt.Errorf("%w: %q", ErrNoNumber, reply)
+		}
+		if math.Abs(num-*st.Number) > st.Tolerance {
+			return fmt.Errorf("exp number %v±%v got %v", *st.Number, st.Tolerance, num)
+		}
+	}
+	return nil
+}
+
+// Drop replies of previous steps that were not checked
+func (r *runner) drain() {
+	buf := make([]byte, 1024)
+	for {
+		r.conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+		if _, err := r.conn.Read(buf); err != nil {
+			return
+		}
+	}
+}
+
+// Read single reply, it ends with out terminator or when nothing more arrives
+func (r *runner) read(timeout time.Duration) ([]byte, error) {
+	var reply []byte
+	buf := make([]byte, 1024)
+	r.conn.SetReadDeadline(time.Now().Add(timeout))
+	for {
+		n, err := r.conn.Read(buf)
+		reply = append(reply, buf[:n]...)
+		if len(r.outterm) > 0 && bytes.HasSuffix(reply, r.outterm) {
+			return reply, nil
+		}
+
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			if len(reply) == 0 {
+				return nil, ErrNoReply
+			}
+			return reply, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(r.outterm) == 0 {
+			// without terminator reply ends when the device goes quiet
+			r.conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		}
+	}
+}
+
+// Lists are set as they are, other values are set as text like in HTTP API
+func setValue(val any) any {
+	if _, isList := val.([]any); isList {
+		return val
+	}
+	return fmt.Sprint(val)
+}
+
+// Compare parameter value with expected one, numbers are compared with tolerance
+func equal(val, exp any, tolerance float64) bool {
+	v, vOk := toFloat(val)
+	e, eOk := toFloat(exp)
+	if vOk && eOk {
+		return math.Abs(v-e) <= tolerance
+	}
+	return fmt.Sprint(val) == fmt.Sprint(exp)
+}
+
+func toFloat(val any) (float64, bool) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func parseDuration(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
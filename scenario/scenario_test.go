@@ -0,0 +1,159 @@
+package scenario
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/e9ctrl/vd/vdfile"
+)
+
+func run(t *testing.T, path string) (*Scenario, []Result) {
+	t.Helper()
+	sc, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vd, err := vdfile.ReadVDFile(sc.VDFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := sc.Run(vd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sc, results
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	sc, results := run(t, "testdata/scenario.toml")
+
+	if sc.Name != "power supply" {
+		t.Errorf("exp name power supply got %s", sc.Name)
+	}
+	if len(results) != len(sc.Steps) {
+		t.Fatalf("exp %d results got %d", len(sc.Steps), len(results))
+	}
+	for _, r := range results {
+		if !r.Passed() {
+			t.Errorf("step %s failed: %v", r.Name, r.Err)
+		}
+	}
+	if results[7].Name != "step 8" {
+		t.Errorf("exp default name step 8 got %s", results[7].Name)
+	}
+}
+
+func TestRunSetArray(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	vd := "[[parameter]]\nname = \"wave\"\ntyp = \"float64[]\"\nval = [0.0]\n"
+	sc := "vdfile = \"vdfile\"\n[[step]]\nset = { wave = [1, 2.5, 3] }\n[[step]]\nassert = { wave = [1.0, 2.5, 3.0] }\n"
+	os.WriteFile(filepath.Join(dir, "vdfile"), []byte(vd), 0o644)
+	os.WriteFile(filepath.Join(dir, "scenario.toml"), []byte(sc), 0o644)
+
+	_, results := run(t, filepath.Join(dir, "scenario.toml"))
+	for _, r := range results {
+		if !r.Passed() {
+			t.Errorf("step %s failed: %v", r.Name, r.Err)
+		}
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	t.Parallel()
+	_, results := run(t, "testdata/failing.toml")
+
+	tests := []struct {
+		name   string
+		expErr string
+	}{
+		{"wrong reply", `exp reply "version 2.0" got "version 1.0"`},
+		{"no match", `reply "CUR 300" does not match "^CURRENT"`},
+		{"number out of tolerance", "exp number 3.5±0.1 got 3.3"},
+		{"no reply", ErrNoReply.Error()},
+		{"wrong parameter", "parameter current: exp 301 got 300"},
+		{"passing step", ""},
+		{"unexpected reply", `unexpected reply: "version 1.0"`},
+	}
+
+	for i, tt := range tests {
+		r := results[i]
+		if r.Name != tt.name {
+			t.Errorf("exp step %s got %s", tt.name, r.Name)
+		}
+		if tt.expErr == "" {
+			if !r.Passed() {
+				t.Errorf("step %s: unexpected error %v", tt.name, r.Err)
+			}
+			continue
+		}
+		if r.Passed() || r.Err.Error() != tt.expErr {
+			t.Errorf("step %s: exp error %q got %v", tt.name, tt.expErr, r.Err)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		content string
+		expErr  error
+	}{
+		{"no action", "[[step]]\nexpect = \"OK\"\n", ErrNoAction},
+		{"many actions", "[[step]]\nsend = \"CUR?\"\nwait = \"1s\"\n", ErrManyActions},
+		{"expect without reply", "[[step]]\nwait = \"1s\"\nexpect = \"OK\"\n", ErrExpectNoReply},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "scenario.toml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load(path)
+			if !errors.Is(err, tt.expErr) {
+				t.Errorf("exp error %v got %v", tt.expErr, err)
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "scenario.toml")
+	os.WriteFile(path, []byte("[[step]]\nsend = \"CUR?\"\nmatch = \"(\"\n"), 0o644)
+	if _, err := Load(path); err == nil {
+		t.Error("exp error for wrong regular expression")
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	t.Parallel()
+	results := []Result{
+		{Name: "ok", Duration: 1500000},
+		{Name: "bad <step>", Err: errors.New(`exp reply "a" got "b"`)},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, "suite", results); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, exp := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<testsuite name="suite" tests="2" failures="1" time="0.002">`,
+		`<testcase name="ok" classname="suite" time="0.002"></testcase>`,
+		`<testcase name="bad &lt;step&gt;" classname="suite" time="0.000">`,
+		`<failure message="exp reply &#34;a&#34; got &#34;b&#34;"></failure>`,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("exp %s in report, got:\n%s", exp, out)
+		}
+	}
+}
@@ -1,13 +1,16 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/e9ctrl/vd/log"
+	"github.com/e9ctrl/vd/metrics"
 )
 
 const (
@@ -15,9 +18,48 @@ const (
 	BUF_SIZE  = 4096
 )
 
+// Handler is implemented by devices served over TCP. Every call receives the client
+// that sent the data, so the handler can keep per-connection session state.
 type Handler interface {
-	Handle([]byte) []byte
+	Handle(*Client, []byte) []byte
 	Triggered() chan []byte
+	Connected(*Client)
+	Disconnected(*Client)
+}
+
+// Handlers that collect metrics implement this interface,
+// server then reports connected clients and traffic to them.
+type Instrumented interface {
+	Metrics() *metrics.Metrics
+}
+
+// Client describes a single TCP connection accepted by the server
+type Client struct {
+	ID          uint64    `json:"id"`
+	RemoteAddr  string    `json:"remote_addr"`
+	ConnectedAt time.Time `json:"connected_at"`
+	conn        net.Conn
+	metrics     *metrics.Metrics
+}
+
+// Close terminates connection with the client
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Write sends data to the client before the reply to the request,
+// e.g. lines of multi-line reply that are sent with delays
+func (c *Client) Write(data []byte) (int, error) {
+	if c.conn == nil {
+		return 0, net.ErrClosed
+	}
+	log.TX(data, log.ClientKey, c.ID)
+	n, err := c.conn.Write(data)
+	c.metrics.Sent(n)
+	return n, err
 }
 
 // Server struct
@@ -26,7 +68,9 @@ type Server struct {
 	listener   net.Listener
 	shutdown   chan struct{}
 	connection chan net.Conn
+	lastID     atomic.Uint64
 	d          Handler
+	metrics    *metrics.Metrics
 }
 
 // Create a new server with given handler and address
@@ -36,12 +80,17 @@ func New(device Handler, address string) (*Server, error) {
 		return nil, fmt.Errorf("failed to listen on address %s: %w", address, err)
 	}
 
-	return &Server{
+	s := &Server{
 		listener:   listener,
 		shutdown:   make(chan struct{}),
 		connection: make(chan net.Conn),
 		d:          device,
-	}, nil
+	}
+	if i, ok := device.(Instrumented); ok {
+		s.metrics = i.Metrics()
+	}
+
+	return s, nil
 }
 
 func (s *Server) acceptConnections() {
@@ -69,47 +118,77 @@ func (s *Server) handleConnections() {
 		case <-s.shutdown:
 			return
 		case conn := <-s.connection:
-			go s.handleConnection(conn)
-			go s.handleAsync(conn)
+			client := &Client{
+				ID:          s.lastID.Add(1),
+				RemoteAddr:  conn.RemoteAddr().String(),
+				ConnectedAt: time.Now(),
+				conn:        conn,
+				metrics:     s.metrics,
+			}
+			s.d.Connected(client)
+			s.metrics.ClientConnected()
+
+			done := make(chan struct{})
+			go s.handleConnection(client, done)
+			go s.handleAsync(client, done)
 		}
 	}
 }
 
 // Used to send value to the client when Trigget via HTTP is called.
-func (s *Server) handleAsync(conn net.Conn) {
+func (s *Server) handleAsync(client *Client, done chan struct{}) {
 	for {
-		resp := <-s.d.Triggered()
-		_, writeErr := conn.Write(resp)
-		if writeErr != nil {
-			fmt.Println("error writing response", writeErr.Error())
-			break
+		select {
+		case <-done:
+			return
+		case resp := <-s.d.Triggered():
+			log.TX(resp, log.ClientKey, client.ID)
+			n, writeErr := client.conn.Write(resp)
+			s.metrics.Sent(n)
+			if writeErr != nil {
+				log.ERR("error writing response", "err", writeErr, log.ClientKey, client.ID)
+				return
+			}
 		}
 	}
 }
 
-func (s *Server) handleConnection(conn net.Conn) {
-	defer conn.Close()
+func (s *Server) handleConnection(client *Client, done chan struct{}) {
+	defer func() {
+		close(done)
+		client.conn.Close()
+		s.d.Disconnected(client)
+		s.metrics.ClientDisconnected()
+	}()
+
 	buffer := make([]byte, BUF_SIZE)
 	for {
-		n, err := conn.Read(buffer)
+		n, err := client.conn.Read(buffer)
 		if err != nil {
-			if err != io.EOF {
-				fmt.Println("error reading connection", err.Error())
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				log.ERR("error reading connection", "err", err, log.ClientKey, client.ID)
 			}
 			break
 		}
 
-		log.RX(buffer[:n])
-		response := s.d.Handle(buffer[:n])
-		log.TX(response)
-		_, writeErr := conn.Write(response)
+		s.metrics.Received(n)
+		log.RX(buffer[:n], log.ClientKey, client.ID)
+		response := s.d.Handle(client, buffer[:n])
+		log.TX(response, log.ClientKey, client.ID)
+		n, writeErr := client.conn.Write(response)
+		s.metrics.Sent(n)
 		if writeErr != nil {
-			fmt.Println("error writing response", writeErr.Error())
+			log.ERR("error writing response", "err", writeErr, log.ClientKey, client.ID)
 			break
 		}
 	}
 }
 
+// Address the server is listening on
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
 // Start server
 func (s *Server) Start() {
 	s.wg.Add(2)
@@ -132,7 +211,7 @@ func (s *Server) Stop() {
 	case <-done:
 		return
 	case <-time.After(time.Second):
-		fmt.Println("Timed out waiting for connections to finish.")
+		log.ERR("timed out waiting for connections to finish")
 		return
 	}
 }
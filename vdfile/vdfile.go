@@ -5,34 +5,165 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/e9ctrl/vd/command"
+	"github.com/e9ctrl/vd/info"
 	"github.com/e9ctrl/vd/parameter"
 )
 
-type configParameter struct {
-	Name string `toml:"name"`
-	Typ  string `toml:"typ"`
-	Val  any    `toml:"val"`
-	Opt  string `toml:"opt,omitempty"`
+// Parameter scopes, device parameters are shared by all clients,
+// session parameters are copied for every connected client
+const (
+	ScopeDevice  = "device"
+	ScopeSession = "session"
+)
+
+// Parameter access levels, see info package
+const (
+	AccessReadWrite = info.AccessReadWrite
+	AccessReadOnly  = info.AccessReadOnly
+	AccessWriteOnly = info.AccessWriteOnly
+	AccessAPIOnly   = info.AccessAPIOnly
+)
+
+// Framing modes, they define how received data is cut into requests
+const (
+	// requests end with terminator
+	FramingTerminator = "terminator"
+	// requests have fixed number of bytes
+	FramingFixed = "fixed"
+	// request ends when nothing is received for some time
+	FramingIdle = "idle"
+	// requests are matches of regular expression
+	FramingRegex = "regex"
+)
+
+// Device profiles providing built-in commands
+const (
+	// IEEE 488.2 common commands and SCPI error queue
+	ProfileSCPI = "scpi"
+)
+
+// Identification returned by *IDN? of the SCPI profile when idn is not set
+const DefaultIDN = "e9ctrl,vd,0,0"
+
+// Number of errors kept in the error queue when size is not set
+const DefaultErrorQueueSize = 16
+
+// Standard errors of SCPI devices, status bits are the ones of the event status register
+var scpiErrors = ConfigErrors{
+	UnknownCommand: &DeviceError{Code: -113, Msg: "Undefined header", Status: 0x20},
+	InvalidIndex:   &DeviceError{Code: -114, Msg: "Header suffix out of range", Status: 0x20},
+	AccessDenied:   &DeviceError{Code: -221, Msg: "Settings conflict", Status: 0x10},
+	OutOfRange:     &DeviceError{Code: -222, Msg: "Data out of range", Status: 0x10},
+	InvalidValue:   &DeviceError{Code: -224, Msg: "Illegal parameter value", Status: 0x10},
+	NoError:        &DeviceError{Code: 0, Msg: "No error"},
+	Overflow:       &DeviceError{Code: -350, Msg: "Queue overflow", Status: 0x08},
+}
+
+var familyRegexp = regexp.MustCompile(`^([A-Za-z0-9_.-]+)\[(-?[0-9]+)\.\.(-?[0-9]+)\]$`)
+
+// Parameter section of the vdfile
+type ConfigParameter struct {
+	Name  string `toml:"name"`
+	Typ   string `toml:"typ"`
+	Val   any    `toml:"val"`
+	Opt   string `toml:"opt,omitempty"`
+	Scope string `toml:"scope,omitempty"`
+	// Access of TCP clients to the parameter, rw by default
+	Access string `toml:"access,omitempty"`
+	// Reply sent when client has no access to the parameter, mismatch is used when empty
+	AccessErr string `toml:"access_err,omitempty"`
+	// Limits of numeric values
+	Min   any  `toml:"min,omitempty"`
+	Max   any  `toml:"max,omitempty"`
+	Step  any  `toml:"step,omitempty"`
+	Clamp bool `toml:"clamp,omitempty"`
+	// Reply sent when set value is outside min and max, mismatch is used when empty
+	RangeErr string `toml:"range_err,omitempty"`
+	// Fixed or max number of elements and their separator of array parameters, e.g. typ = "float64[]"
+	Len    int    `toml:"len,omitzero"`
+	MaxLen int    `toml:"max_len,omitzero"`
+	Sep    string `toml:"sep,omitempty"`
+	// Reply sent when referenced array element or family member does not exist, mismatch is used when empty
+	IndexErr string `toml:"index_err,omitempty"`
 }
 
-type configCommand struct {
+// Command section of the vdfile
+type ConfigCommand struct {
 	Name string `toml:"name"`
 	Req  string `toml:"req"`
-	Res  string `toml:"res,omitempty"`
-	Dly  string `toml:"dly,omitempty"`
+	// Response string or list of lines sent with the out terminator each
+	Res any    `toml:"res,omitempty"`
+	Dly string `toml:"dly,omitempty"`
+	// Delay between lines of multi-line response
+	LineDly string `toml:"line_dly,omitempty"`
+	// Echo of the request overriding the global one
+	Echo *bool `toml:"echo,omitempty"`
+	// Responses sent when set of the parameter fails, they can use {%s:_param} and {%s:_value}
+	OnInvalid    string `toml:"on_invalid,omitempty"`
+	OnOutOfRange string `toml:"on_out_of_range,omitempty"`
+	OnTypeError  string `toml:"on_type_error,omitempty"`
+	// Terminators of the request and response overriding the global ones
+	InTerminator  string `toml:"interm,omitempty"`
+	OutTerminator string `toml:"outterm,omitempty"`
+}
+
+// Error reported by the device, status is OR-ed into the status register when the error is pushed
+type DeviceError = info.DeviceError
+
+// Error queue section of the vdfile, errors pushed when requests are rejected.
+// Reasons without error are not reported.
+type ConfigErrors struct {
+	// Max number of errors in the queue
+	Size           int          `toml:"size,omitzero"`
+	UnknownCommand *DeviceError `toml:"unknown_command,omitempty"`
+	InvalidValue   *DeviceError `toml:"invalid_value,omitempty"`
+	OutOfRange     *DeviceError `toml:"out_of_range,omitempty"`
+	AccessDenied   *DeviceError `toml:"access_denied,omitempty"`
+	InvalidIndex   *DeviceError `toml:"invalid_index,omitempty"`
+	// Read from the empty queue, code 0 when not set
+	NoError *DeviceError `toml:"no_error,omitempty"`
+	// Replaces the last error when the queue is full, new errors are dropped when not set
+	Overflow *DeviceError `toml:"overflow,omitempty"`
 }
 
+// Runtime state of the device, used for snapshots and presets
+type State = info.State
+
+// Content of the vdfile
 type Config struct {
 	InTerminator  string            `toml:"interm"`
 	OutTerminator string            `toml:"outterm"`
-	Params        []configParameter `toml:"parameter"`
-	Commands      []configCommand   `toml:"command"`
+	Params        []ConfigParameter `toml:"parameter"`
+	Commands      []ConfigCommand   `toml:"command"`
 	Mismatch      string            `toml:"mismatch,omitempty"`
+	// Profile of the device with built-in commands, e.g. scpi
+	Profile string `toml:"profile,omitempty"`
+	// Identification of the device returned by *IDN? of the SCPI profile
+	IDN string `toml:"idn,omitempty"`
+	// Error queue and status register, SCPI devices have one with standard errors
+	Errors *ConfigErrors `toml:"errors,omitempty"`
+	// Sent after every reply e.g. "> "
+	Prompt string `toml:"prompt,omitempty"`
+	// Send back every received request, also the mismatched one, before the response
+	Echo bool `toml:"echo,omitempty"`
+	// Delay between echoed characters, whole request is echoed at once when empty
+	EchoDly string `toml:"echo_dly,omitempty"`
+	// Framing of requests, terminator by default
+	Framing string `toml:"framing,omitempty"`
+	// Length of fixed frames
+	FrameLen int `toml:"frame_len,omitzero"`
+	// Time without received data that ends idle frame, e.g. 50ms
+	FrameGap string `toml:"frame_gap,omitempty"`
+	// Regular expression matching the whole frame, the first group is the request when present
+	FrameRegex string           `toml:"frame_regex,omitempty"`
+	Presets    map[string]State `toml:"preset,omitempty"`
 }
 
 // VDFile struct
@@ -40,8 +171,34 @@ type VDFile struct {
 	InTerminator  []byte
 	OutTerminator []byte
 	Params        map[string]parameter.Parameter
+	// Names of parameters with session scope
+	SessionParams map[string]bool
 	Commands      map[string]*command.Command
 	Mismatch      []byte
+	Profile       string
+	IDN           string
+	// Error queue settings, nil when the device has no error queue
+	Errors  *ConfigErrors
+	Prompt  []byte
+	Echo    bool
+	EchoDly time.Duration
+	// Framing mode with its settings
+	Framing    string
+	FrameLen   int
+	FrameGap   time.Duration
+	FrameRegex *regexp.Regexp
+	// Replies to sets outside parameter range
+	RangeErrors map[string][]byte
+	// Access levels of parameters other than rw
+	Access map[string]string
+	// Replies to requests violating parameter access
+	AccessErrors map[string][]byte
+	// Replies to requests referencing elements outside arrays
+	IndexErrors map[string][]byte
+	// Named states that can be applied at runtime
+	Presets map[string]State
+	// Config the vdfile was created from, it keeps order and original definitions
+	Config Config
 }
 
 // Read VDFile from disk from the given filepath
@@ -54,29 +211,109 @@ func ReadVDFile(path string) (*VDFile, error) {
 	return ReadVDFileFromConfig(config)
 }
 
+// Read VDFile from the TOML content
+func ReadVDFileFromBytes(data []byte) (*VDFile, error) {
+	config, err := DecodeVDBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding file with err %w", err)
+	}
+
+	return ReadVDFileFromConfig(config)
+}
+
 // Creates vdfile struct based on Config containing result of TOML file parsing
 func ReadVDFileFromConfig(config Config) (*VDFile, error) {
 	vdfile := &VDFile{
-		Params:   make(map[string]parameter.Parameter, 0),
-		Commands: make(map[string]*command.Command, 0),
+		Params:        make(map[string]parameter.Parameter, 0),
+		SessionParams: make(map[string]bool, 0),
+		RangeErrors:   make(map[string][]byte, 0),
+		Access:        make(map[string]string, 0),
+		AccessErrors:  make(map[string][]byte, 0),
+		IndexErrors:   make(map[string][]byte, 0),
+		Commands:      make(map[string]*command.Command, 0),
+		Config:        config,
 	}
 
 	paramCount := make(map[string]bool)
 	for _, param := range config.Params {
-		if _, exists := paramCount[param.Name]; exists {
-			return nil, fmt.Errorf("%s name is duplicated", param.Name)
+		name := param.Name
+		if base, _, _, isFamily := ParseFamily(name); isFamily {
+			name = base
+		}
+		if _, exists := paramCount[name]; exists {
+			return nil, fmt.Errorf("%s name is duplicated", name)
 		}
-		paramCount[param.Name] = true
+		paramCount[name] = true
 	}
 
 	for _, param := range config.Params {
-		currentParam, err := parameter.New(param.Val, param.Opt, param.Typ)
+		rng, err := parseRange(param)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s has wrong range: %w", param.Name, err)
+		}
+
+		name, val := param.Name, param.Val
+		typ, isArray := strings.CutSuffix(param.Typ, "[]")
+		shape := parameter.Shape{
+			Len:    param.Len,
+			MaxLen: param.MaxLen,
+			Sep:    param.Sep,
+		}
+		if base, first, last, isFamily := ParseFamily(param.Name); isFamily {
+			if isArray || param.Len != 0 || param.MaxLen != 0 {
+				return nil, fmt.Errorf("parameter family %s cannot be an array", param.Name)
+			}
+			if last < first {
+				return nil, fmt.Errorf("parameter family %s has no members", param.Name)
+			}
+			name, isArray = base, true
+			shape.Len, shape.First = last-first+1, first
+			// single value is the initial value of all family members
+			if _, isList := val.([]any); !isList && val != nil {
+				vals := make([]any, shape.Len)
+				for i := range vals {
+					vals[i] = val
+				}
+				val = vals
+			}
+		}
+
+		var currentParam parameter.Parameter
+		if isArray {
+			currentParam, err = parameter.NewArray(val, param.Opt, typ, rng, shape)
+		} else {
+			currentParam, err = parameter.NewWithRange(val, param.Opt, typ, rng)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed initializing parameter %s, err: %w", param.Val, err)
 		}
 
-		vdfile.Params[param.Name] = currentParam
+		vdfile.Params[name] = currentParam
+		if param.RangeErr != "" {
+			vdfile.RangeErrors[name] = []byte(param.RangeErr)
+		}
+		if param.IndexErr != "" {
+			vdfile.IndexErrors[name] = []byte(param.IndexErr)
+		}
 
+		switch param.Scope {
+		case "", ScopeDevice:
+		case ScopeSession:
+			vdfile.SessionParams[name] = true
+		default:
+			return nil, fmt.Errorf("parameter %s has unknown scope %s", name, param.Scope)
+		}
+
+		switch param.Access {
+		case "", AccessReadWrite:
+		case AccessReadOnly, AccessWriteOnly, AccessAPIOnly:
+			vdfile.Access[name] = param.Access
+		default:
+			return nil, fmt.Errorf("parameter %s has unknown access %s", name, param.Access)
+		}
+		if param.AccessErr != "" {
+			vdfile.AccessErrors[name] = []byte(param.AccessErr)
+		}
 	}
 
 	commandCount := make(map[string]bool)
@@ -89,22 +326,221 @@ func ReadVDFileFromConfig(config Config) (*VDFile, error) {
 
 	for _, cmd := range config.Commands {
 		currentCmd := &command.Command{
-			Name: cmd.Name,
-			Req:  []byte(cmd.Req),
-			Res:  []byte(cmd.Res),
-			Dly:  parseDelays(cmd.Dly),
+			Name:          cmd.Name,
+			Req:           []byte(cmd.Req),
+			Dly:           parseDelays(cmd.Dly),
+			LineDly:       parseDelays(cmd.LineDly),
+			InTerminator:  ParseTerminator(cmd.InTerminator),
+			OutTerminator: ParseTerminator(cmd.OutTerminator),
+			Echo:          config.Echo,
+			OnInvalid:     []byte(cmd.OnInvalid),
+			OnOutOfRange:  []byte(cmd.OnOutOfRange),
+			OnTypeError:   []byte(cmd.OnTypeError),
+		}
+		if cmd.Echo != nil {
+			currentCmd.Echo = *cmd.Echo
+		}
+
+		switch res := cmd.Res.(type) {
+		case nil:
+		case string:
+			currentCmd.Res = []byte(res)
+		case []string:
+			for _, line := range res {
+				currentCmd.Lines = append(currentCmd.Lines, []byte(line))
+			}
+		case []any:
+			for _, line := range res {
+				l, isString := line.(string)
+				if !isString {
+					return nil, fmt.Errorf("command %s has response line %v that is not a string", cmd.Name, line)
+				}
+				currentCmd.Lines = append(currentCmd.Lines, []byte(l))
+			}
+		default:
+			return nil, fmt.Errorf("command %s has wrong response %v", cmd.Name, cmd.Res)
 		}
 
 		vdfile.Commands[cmd.Name] = currentCmd
 	}
 
-	vdfile.InTerminator = parseTerminator(config.InTerminator)
-	vdfile.OutTerminator = parseTerminator(config.OutTerminator)
+	// profile sets terminators as it may change the default ones
+	if err := parseProfile(config, vdfile); err != nil {
+		return nil, err
+	}
+	if err := parseErrors(config, vdfile); err != nil {
+		return nil, err
+	}
 	vdfile.Mismatch = []byte(config.Mismatch)
+	vdfile.Prompt = []byte(config.Prompt)
+	vdfile.Echo = config.Echo
+	vdfile.EchoDly = parseDelays(config.EchoDly)
+	if err := parseFraming(config, vdfile); err != nil {
+		return nil, err
+	}
+	vdfile.Presets = config.Presets
 
 	return vdfile, nil
 }
 
+// Check profile of the device, SCPI devices use LF terminators unless they are set
+func parseProfile(config Config, vdfile *VDFile) error {
+	switch config.Profile {
+	case "":
+	case ProfileSCPI:
+		vdfile.IDN = config.IDN
+		if vdfile.IDN == "" {
+			vdfile.IDN = DefaultIDN
+		}
+		if config.InTerminator == "" {
+			config.InTerminator = "LF"
+		}
+		if config.OutTerminator == "" {
+			config.OutTerminator = "LF"
+		}
+	default:
+		return fmt.Errorf("unknown profile %s", config.Profile)
+	}
+	vdfile.Profile = config.Profile
+	vdfile.InTerminator = ParseTerminator(config.InTerminator)
+	vdfile.OutTerminator = ParseTerminator(config.OutTerminator)
+	return nil
+}
+
+// Set up error queue, errors that are not set are taken from the profile
+func parseErrors(config Config, vdfile *VDFile) error {
+	if config.Errors == nil && vdfile.Profile != ProfileSCPI {
+		return nil
+	}
+
+	var errs ConfigErrors
+	if config.Errors != nil {
+		errs = *config.Errors
+	}
+	if errs.Size < 0 {
+		return fmt.Errorf("error queue size cannot be negative, got %d", errs.Size)
+	}
+	if errs.Size == 0 {
+		errs.Size = DefaultErrorQueueSize
+	}
+
+	defaults := ConfigErrors{NoError: &DeviceError{}}
+	if vdfile.Profile == ProfileSCPI {
+		defaults = scpiErrors
+	}
+	for _, e := range []struct{ err, def **DeviceError }{
+		{&errs.UnknownCommand, &defaults.UnknownCommand},
+		{&errs.InvalidValue, &defaults.InvalidValue},
+		{&errs.OutOfRange, &defaults.OutOfRange},
+		{&errs.AccessDenied, &defaults.AccessDenied},
+		{&errs.InvalidIndex, &defaults.InvalidIndex},
+		{&errs.NoError, &defaults.NoError},
+		{&errs.Overflow, &defaults.Overflow},
+	} {
+		if *e.err == nil {
+			*e.err = *e.def
+		}
+	}
+
+	vdfile.Errors = &errs
+	return nil
+}
+
+// Check framing mode and its settings
+func parseFraming(config Config, vdfile *VDFile) error {
+	vdfile.Framing = config.Framing
+	switch config.Framing {
+	case "", FramingTerminator:
+		vdfile.Framing = FramingTerminator
+	case FramingFixed:
+		if config.FrameLen <= 0 {
+			return fmt.Errorf("fixed framing requires positive frame_len, got %d", config.FrameLen)
+		}
+		vdfile.FrameLen = config.FrameLen
+	case FramingIdle:
+		gap, err := time.ParseDuration(config.FrameGap)
+		if err != nil || gap <= 0 {
+			return fmt.Errorf("idle framing requires positive frame_gap, got %q", config.FrameGap)
+		}
+		vdfile.FrameGap = gap
+	case FramingRegex:
+		re, err := regexp.Compile(config.FrameRegex)
+		if err != nil {
+			return fmt.Errorf("wrong frame_regex: %w", err)
+		}
+		vdfile.FrameRegex = re
+	default:
+		return fmt.Errorf("unknown framing %s", config.Framing)
+	}
+	return nil
+}
+
+// Split name of the parameter family like voltage[1..16] into name and indexes of the first and the last member
+func ParseFamily(name string) (base string, first, last int, ok bool) {
+	m := familyRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return name, 0, 0, false
+	}
+
+	first, _ = strconv.Atoi(m[2])
+	last, _ = strconv.Atoi(m[3])
+	return m[1], first, last, true
+}
+
+// Build range of the parameter from min, max and step that can be written as integers or floats
+func parseRange(param ConfigParameter) (parameter.Range, error) {
+	rng := parameter.Range{Clamp: param.Clamp}
+	var err error
+	if rng.Min, err = parseLimit(param.Min); err != nil {
+		return rng, fmt.Errorf("min: %w", err)
+	}
+	if rng.Max, err = parseLimit(param.Max); err != nil {
+		return rng, fmt.Errorf("max: %w", err)
+	}
+	rng.IntMin, rng.IntMax = intLimit(param.Min), intLimit(param.Max)
+
+	step, err := parseLimit(param.Step)
+	if err != nil {
+		return rng, fmt.Errorf("step: %w", err)
+	}
+	if step != nil {
+		rng.Step = *step
+	}
+
+	return rng, nil
+}
+
+func parseLimit(val any) (*float64, error) {
+	var f float64
+	switch v := val.(type) {
+	case nil:
+		return nil, nil
+	case int64:
+		f = float64(v)
+	case int:
+		f = float64(v)
+	case float64:
+		f = v
+	default:
+		return nil, fmt.Errorf("%v is not a number", val)
+	}
+	return &f, nil
+}
+
+// Exact value of integer limit, nil for float limits
+func intLimit(val any) *int64 {
+	var n int64
+	switch v := val.(type) {
+	case int64:
+		n = v
+	case int:
+		n = int64(v)
+	default:
+		return nil
+	}
+	return &n
+}
+
 // Parse TOML file to Config struct
 func DecodeVDFile(path string) (Config, error) {
 	var config Config
@@ -121,17 +557,35 @@ func DecodeVDFS(f fs.FS, path string) (Config, error) {
 	return config, err
 }
 
+// Parse TOML content to Config struct
+func DecodeVDBytes(data []byte) (Config, error) {
+	var config Config
+	_, err := toml.Decode(string(data), &config)
+
+	return config, err
+}
+
 // Created TOML config file based on Config
 func WriteVDFile(path string, config Config) error {
+	data, err := EncodeVDFile(config)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0666)
+}
+
+// Encode Config to TOML content of the vdfile
+func EncodeVDFile(config Config) ([]byte, error) {
 	var buf = bytes.Buffer{}
 	var encoder = toml.NewEncoder(&buf)
 
 	err := encoder.Encode(config)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return os.WriteFile(path, buf.Bytes(), 0666)
+	return buf.Bytes(), nil
 }
 
 // Checks if string can be converted to time.Duration
@@ -147,7 +601,8 @@ func parseDelays(line string) time.Duration {
 	return t
 }
 
-func parseTerminator(line string) []byte {
+// Parse terminator made of space separated ASCII names (CR LF) or literal characters
+func ParseTerminator(line string) []byte {
 	if len(line) == 0 {
 		return nil
 	}
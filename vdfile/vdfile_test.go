@@ -23,7 +23,7 @@ func TestParseTerminator(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			res := parseTerminator(tt.line)
+			res := ParseTerminator(tt.line)
 			if !bytes.Equal(res, tt.exp) {
 				t.Errorf("%s: exp value: %v got %v\n", tt.name, tt.exp, res)
 			}
@@ -81,3 +81,284 @@ func TestParseDelays(t *testing.T) {
 		})
 	}
 }
+
+func TestReadVDFileFromBytes(t *testing.T) {
+	t.Parallel()
+	data := []byte(`
+interm = "CR LF"
+
+[[parameter]]
+  name = "current"
+  typ = "int"
+  val = 300
+
+[[command]]
+  name = "get_current"
+  req = "CUR?"
+  res = "CUR {%d:current}"
+`)
+
+	vd, err := ReadVDFileFromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(vd.InTerminator, []byte("\r\n")) {
+		t.Errorf("exp in terminator CR LF got %v", vd.InTerminator)
+	}
+	if v := vd.Params["current"].Value(); v != int64(300) {
+		t.Errorf("exp current 300 got %v", v)
+	}
+	if _, exists := vd.Commands["get_current"]; !exists {
+		t.Error("exp get_current command")
+	}
+
+	if _, err := ReadVDFileFromBytes([]byte("interm = ")); err == nil {
+		t.Error("exp error for invalid TOML")
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		param  string
+		expErr bool
+	}{
+		{"integer limits", "min = 0\nmax = 300", false},
+		{"float limits", "min = -1.5\nmax = 1.5\nstep = 0.5\nclamp = true", false},
+		{"string limit", `max = "300"`, true},
+		{"min above max", "min = 10\nmax = 0", true},
+		{"value out of range", "max = 100", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := "[[parameter]]\nname = \"current\"\ntyp = \"int\"\nval = 200\n" + tt.param
+			_, err := ReadVDFileFromBytes([]byte(data))
+			if (err != nil) != tt.expErr {
+				t.Errorf("exp error %v got %v", tt.expErr, err)
+			}
+		})
+	}
+}
+
+func TestParameterAccess(t *testing.T) {
+	t.Parallel()
+	data := "[[parameter]]\nname = \"temp\"\ntyp = \"int\"\nval = 20\naccess = \"ro\"\n[[parameter]]\nname = \"mode\"\ntyp = \"int\"\nval = 0\naccess = \"rw\"\n"
+	vd, err := ReadVDFileFromBytes([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vd.Access["temp"] != AccessReadOnly {
+		t.Errorf("exp temp access ro got %s", vd.Access["temp"])
+	}
+	if _, exists := vd.Access["mode"]; exists {
+		t.Error("exp rw access not stored")
+	}
+
+	if _, err := ReadVDFileFromBytes([]byte("[[parameter]]\nname = \"temp\"\ntyp = \"int\"\nval = 20\naccess = \"none\"\n")); err == nil {
+		t.Error("exp error for unknown access")
+	}
+}
+
+func TestParameterFamily(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		param  string
+		exp    string
+		expErr bool
+	}{
+		{"single value", "name = \"volt[1..3]\"\ntyp = \"int\"\nval = 5", "5,5,5", false},
+		{"list value", "name = \"volt[0..1]\"\ntyp = \"int\"\nval = [1, 2]", "1,2", false},
+		{"without value", "name = \"volt[1..2]\"\ntyp = \"int\"", "0,0", false},
+		{"wrong number of values", "name = \"volt[1..3]\"\ntyp = \"int\"\nval = [1, 2]", "", true},
+		{"no members", "name = \"volt[3..1]\"\ntyp = \"int\"\nval = 5", "", true},
+		{"family of arrays", "name = \"volt[1..3]\"\ntyp = \"int[]\"\nval = 5", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vd, err := ReadVDFileFromBytes([]byte("[[parameter]]\n" + tt.param))
+			if (err != nil) != tt.expErr {
+				t.Fatalf("exp error %v got %v", tt.expErr, err)
+			}
+			if err == nil && vd.Params["volt"].String() != tt.exp {
+				t.Errorf("exp value %s got %s", tt.exp, vd.Params["volt"].String())
+			}
+		})
+	}
+}
+
+func TestCommandLines(t *testing.T) {
+	t.Parallel()
+	vd, err := ReadVDFileFromBytes([]byte(`
+prompt = "> "
+
+[[command]]
+  name = "help"
+  req = "*HELP?"
+  res = ["line 1", "line 2"]
+  line_dly = "10ms"
+  outterm = "LF"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := vd.Commands["help"]
+	if len(cmd.Lines) != 2 || string(cmd.Lines[1]) != "line 2" || cmd.Res != nil {
+		t.Errorf("unexpected response lines %q and res %q", cmd.Lines, cmd.Res)
+	}
+	if cmd.LineDly != 10*time.Millisecond {
+		t.Errorf("exp line delay 10ms got %v", cmd.LineDly)
+	}
+	if string(cmd.OutTerminator) != "\n" || string(vd.Prompt) != "> " {
+		t.Errorf("unexpected terminator %q and prompt %q", cmd.OutTerminator, vd.Prompt)
+	}
+
+	_, err = ReadVDFileFromBytes([]byte(`
+[[command]]
+  name = "help"
+  req = "*HELP?"
+  res = ["line 1", 2]
+`))
+	if err == nil {
+		t.Error("exp error for response line that is not a string")
+	}
+}
+
+func TestEcho(t *testing.T) {
+	t.Parallel()
+	vd, err := ReadVDFileFromBytes([]byte(`
+echo = true
+echo_dly = "5ms"
+
+[[command]]
+  name = "get"
+  req = "GET?"
+
+[[command]]
+  name = "set"
+  req = "SET"
+  echo = false
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !vd.Echo || vd.EchoDly != 5*time.Millisecond {
+		t.Errorf("unexpected echo %v with delay %v", vd.Echo, vd.EchoDly)
+	}
+	if !vd.Commands["get"].Echo || vd.Commands["set"].Echo {
+		t.Errorf("exp echo inherited by get and disabled for set")
+	}
+}
+
+func TestProfile(t *testing.T) {
+	t.Parallel()
+	vd, err := ReadVDFileFromBytes([]byte(`profile = "scpi"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vd.Profile != ProfileSCPI || vd.IDN != DefaultIDN {
+		t.Errorf("unexpected profile %s with identification %s", vd.Profile, vd.IDN)
+	}
+	if string(vd.InTerminator) != "\n" || string(vd.OutTerminator) != "\n" {
+		t.Errorf("exp LF terminators got %q and %q", vd.InTerminator, vd.OutTerminator)
+	}
+
+	vd, err = ReadVDFileFromBytes([]byte(`
+profile = "scpi"
+idn = "ACME,PSU-1,1234,1.0"
+interm = "CR LF"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vd.IDN != "ACME,PSU-1,1234,1.0" || string(vd.InTerminator) != "\r\n" {
+		t.Errorf("unexpected identification %s and terminator %q", vd.IDN, vd.InTerminator)
+	}
+
+	if _, err := ReadVDFileFromBytes([]byte(`profile = "modbus"`)); err == nil {
+		t.Error("exp error for unknown profile")
+	}
+}
+
+func TestErrorQueue(t *testing.T) {
+	t.Parallel()
+	vd, err := ReadVDFileFromBytes([]byte(`
+[errors]
+  [errors.out_of_range]
+    code = 2
+    msg = "out of range"
+    status = 4
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vd.Errors == nil || vd.Errors.Size != DefaultErrorQueueSize || *vd.Errors.NoError != (DeviceError{}) {
+		t.Fatalf("exp default size and no error got %+v", vd.Errors)
+	}
+	if vd.Errors.OutOfRange.Code != 2 || vd.Errors.UnknownCommand != nil {
+		t.Errorf("unexpected errors %+v", vd.Errors)
+	}
+
+	// errors that are not set come from the profile
+	vd, err = ReadVDFileFromBytes([]byte(`
+profile = "scpi"
+
+[errors]
+  size = 4
+  [errors.out_of_range]
+    code = -222
+    msg = "Voltage out of range"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vd.Errors.Size != 4 || vd.Errors.OutOfRange.Msg != "Voltage out of range" || vd.Errors.UnknownCommand.Code != -113 {
+		t.Errorf("unexpected SCPI errors %+v", vd.Errors)
+	}
+
+	vd, err = ReadVDFileFromBytes([]byte(`interm = "LF"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vd.Errors != nil {
+		t.Errorf("exp no error queue got %+v", vd.Errors)
+	}
+
+	if _, err := ReadVDFileFromBytes([]byte("[errors]\nsize = -1")); err == nil {
+		t.Error("exp error for negative queue size")
+	}
+}
+
+func TestFraming(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		config  Config
+		expErr  bool
+		framing string
+	}{
+		{"default", Config{}, false, FramingTerminator},
+		{"fixed", Config{Framing: FramingFixed, FrameLen: 8}, false, FramingFixed},
+		{"fixed without length", Config{Framing: FramingFixed}, true, ""},
+		{"idle", Config{Framing: FramingIdle, FrameGap: "50ms"}, false, FramingIdle},
+		{"idle without gap", Config{Framing: FramingIdle}, true, ""},
+		{"regex", Config{Framing: FramingRegex, FrameRegex: `\x02(.*?)\x03`}, false, FramingRegex},
+		{"wrong regex", Config{Framing: FramingRegex, FrameRegex: `(`}, true, ""},
+		{"unknown", Config{Framing: "magic"}, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vd, err := ReadVDFileFromConfig(tt.config)
+			if (err != nil) != tt.expErr {
+				t.Fatalf("exp error %t got %v", tt.expErr, err)
+			}
+			if err == nil && vd.Framing != tt.framing {
+				t.Errorf("exp framing %s got %s", tt.framing, vd.Framing)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+// vdtest pkg starts the simulator inside Go tests, it listens on an ephemeral port
+// and is stopped automatically when the test finishes.
+package vdtest
@@ -0,0 +1,271 @@
+package vdtest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/e9ctrl/vd/device"
+	"github.com/e9ctrl/vd/server"
+	"github.com/e9ctrl/vd/vdfile"
+	"github.com/google/go-cmp/cmp"
+)
+
+// Time WaitForRequest and WaitForCommand wait by default
+const DefaultTimeout = time.Second
+
+// Interval of checking history while waiting for requests
+const pollInterval = 5 * time.Millisecond
+
+// Request received by the simulator
+type Request struct {
+	Time   time.Time
+	Client uint64
+	// Request without in terminator
+	Data string
+	// Name of the matched command, empty for unknown requests
+	Command string
+	// Values received in set requests
+	Values map[string]any
+	// Reason why the request was not handled
+	Error string
+}
+
+// Simulator running in the test
+type VD struct {
+	t   testing.TB
+	dev *device.StreamDevice
+	srv *server.Server
+}
+
+// Start simulator described by vdfile content on an ephemeral port of the loopback interface.
+// Simulator is stopped with the test cleanup.
+func Start(t testing.TB, vdfileContent []byte) *VD {
+	t.Helper()
+
+	vd, err := vdfile.ReadVDFileFromBytes(vdfileContent)
+	if err != nil {
+		t.Fatalf("vdtest: %v", err)
+	}
+
+	dev, err := device.NewDevice(vd)
+	if err != nil {
+		t.Fatalf("vdtest: %v", err)
+	}
+
+	srv, err := server.New(dev, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("vdtest: %v", err)
+	}
+
+	srv.Start()
+	t.Cleanup(srv.Stop)
+
+	return &VD{
+		t:   t,
+		dev: dev,
+		srv: srv,
+	}
+}
+
+// Address of the simulator in host:port form
+func (v *VD) Addr() string {
+	return v.srv.Addr().String()
+}
+
+// Device of the simulator, for operations not covered by helpers
+func (v *VD) Device() *device.StreamDevice {
+	return v.dev
+}
+
+// Read value of the parameter, test fails when parameter does not exist
+func (v *VD) Get(name string) any {
+	v.t.Helper()
+	val, err := v.dev.GetParameter(name)
+	if err != nil {
+		v.t.Fatalf("vdtest: %v", err)
+	}
+	return val
+}
+
+// Set value of the parameter, value is converted the same way as in HTTP API,
+// slices set elements of array parameters. Test fails when parameter does not exist or value is not valid.
+func (v *VD) Set(name string, value any) {
+	v.t.Helper()
+	if kind := reflect.ValueOf(value).Kind(); kind != reflect.Slice && kind != reflect.Array {
+		value = fmt.Sprint(value)
+	}
+	if err := v.dev.SetParameter(name, value); err != nil {
+		v.t.Fatalf("vdtest: set %s: %v", name, err)
+	}
+}
+
+// Read value of the parameter with the given type, test fails when types do not match
+func Get[T any](v *VD, name string) T {
+	v.t.Helper()
+	val := v.Get(name)
+	typed, ok := val.(T)
+	if !ok {
+		v.t.Fatalf("vdtest: parameter %s is %T not %T", name, val, typed)
+	}
+	return typed
+}
+
+// Delay replies to the command
+func (v *VD) SetDelay(command string, d time.Duration) {
+	v.t.Helper()
+	if err := v.dev.SetCommandDelay(command, d.String()); err != nil {
+		v.t.Fatalf("vdtest: %v", err)
+	}
+}
+
+// Reply with the message to unknown requests, empty message disables replies
+func (v *VD) SetMismatch(msg string) {
+	v.t.Helper()
+	if err := v.dev.SetMismatch(msg); err != nil {
+		v.t.Fatalf("vdtest: %v", err)
+	}
+}
+
+// Send reply of the command to the connected client without a request
+func (v *VD) Trigger(command string) {
+	v.t.Helper()
+	if err := v.dev.Trigger(command); err != nil {
+		v.t.Fatalf("vdtest: %v", err)
+	}
+}
+
+// Current state of the simulator, can be restored later with Restore
+func (v *VD) Snapshot() vdfile.State {
+	return v.dev.Snapshot()
+}
+
+// Restore state of the simulator, test fails when any of the values is invalid
+func (v *VD) Restore(state vdfile.State) {
+	v.t.Helper()
+	if err := v.dev.Restore(state); err != nil {
+		v.t.Fatalf("vdtest: %v", err)
+	}
+}
+
+// Apply preset defined in the vdfile
+func (v *VD) ApplyPreset(name string) {
+	v.t.Helper()
+	if err := v.dev.ApplyPreset(name); err != nil {
+		v.t.Fatalf("vdtest: %v", err)
+	}
+}
+
+// Close connections of all clients
+func (v *VD) DisconnectAll() {
+	v.t.Helper()
+	for _, c := range v.dev.Clients() {
+		if err := v.dev.Disconnect(c.ID); err != nil {
+			v.t.Fatalf("vdtest: %v", err)
+		}
+	}
+}
+
+// Requests received so far, only the newest device.HistorySize requests are kept
+func (v *VD) Requests() []Request {
+	entries := v.dev.History(device.HistoryFilter{})
+	reqs := make([]Request, len(entries))
+	for i, e := range entries {
+		reqs[i] = Request{
+			Time:    e.Time,
+			Client:  e.Client,
+			Data:    e.Raw,
+			Command: e.Command,
+			Values:  e.Values,
+			Error:   e.Error,
+		}
+	}
+	return reqs
+}
+
+// Forget received requests
+func (v *VD) ClearRequests() {
+	v.dev.ClearHistory()
+}
+
+// Wait for the request with the given data, without in terminator.
+// Test fails when it does not arrive within timeout, zero timeout means DefaultTimeout.
+func (v *VD) WaitForRequest(data string, timeout time.Duration) Request {
+	v.t.Helper()
+	return v.waitFor(fmt.Sprintf("request %q", data), timeout, func(r Request) bool {
+		return r.Data == data
+	})
+}
+
+// Wait for the request matching the command, test fails when it does not arrive within timeout.
+// Zero timeout means DefaultTimeout.
+func (v *VD) WaitForCommand(command string, timeout time.Duration) Request {
+	v.t.Helper()
+	return v.waitFor(fmt.Sprintf("command %s", command), timeout, func(r Request) bool {
+		return r.Command == command
+	})
+}
+
+// Check that requests matched exactly the given commands in order, unknown requests are empty names
+func (v *VD) AssertCommands(commands ...string) {
+	v.t.Helper()
+	reqs := v.Requests()
+	got := make([]string, len(reqs))
+	for i, r := range reqs {
+		got[i] = r.Command
+	}
+	if diff := cmp.Diff(commands, got); diff != "" {
+		v.t.Errorf("vdtest: unexpected commands (-want +got):\n%s", diff)
+	}
+}
+
+// Check that received requests, without in terminators, equal the given ones in order
+func (v *VD) AssertRequests(requests ...string) {
+	v.t.Helper()
+	reqs := v.Requests()
+	got := make([]string, len(reqs))
+	for i, r := range reqs {
+		got[i] = r.Data
+	}
+	if diff := cmp.Diff(requests, got); diff != "" {
+		v.t.Errorf("vdtest: unexpected requests (-want +got):\n%s", diff)
+	}
+}
+
+func (v *VD) waitFor(what string, timeout time.Duration, match func(Request) bool) Request {
+	v.t.Helper()
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		for _, r := range v.Requests() {
+			if match(r) {
+				return r
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			v.t.Fatalf("vdtest: %s not received within %s, got %s", what, timeout, v.summary())
+			return Request{}
+		}
+	}
+}
+
+func (v *VD) summary() string {
+	reqs := v.Requests()
+	if len(reqs) == 0 {
+		return "no requests"
+	}
+	data := make([]string, len(reqs))
+	for i, r := range reqs {
+		data[i] = fmt.Sprintf("%q", r.Data)
+	}
+	return strings.Join(data, ", ")
+}
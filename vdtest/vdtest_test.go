@@ -0,0 +1,184 @@
+package vdtest_test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/e9ctrl/vd/vdtest"
+)
+
+var config = []byte(`
+interm = "CR LF"
+outterm = "CR LF"
+
+[[parameter]]
+  name = "current"
+  typ = "int"
+  val = 300
+
+[[parameter]]
+  name = "mode"
+  typ = "string"
+  val = "NORM"
+  opt = "NORM|SING"
+
+[[parameter]]
+  name = "wave"
+  typ = "float64[]"
+  val = [0.0]
+
+[preset.off]
+  mismatch = "OFF"
+
+[preset.off.params]
+  current = 0
+
+[[command]]
+  name = "get_current"
+  req = "CUR?"
+  res = "CUR {%d:current}"
+
+[[command]]
+  name = "set_current"
+  req = "CUR {%d:current}"
+  res = "OK"
+`)
+
+func dial(t *testing.T, vd *vdtest.VD) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", vd.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	return conn, bufio.NewReader(conn)
+}
+
+func query(t *testing.T, conn net.Conn, r *bufio.Reader, req string) string {
+	t.Helper()
+	if _, err := conn.Write([]byte(req + "\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	res, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestStart(t *testing.T) {
+	t.Parallel()
+	vd := vdtest.Start(t, config)
+	conn, r := dial(t, vd)
+
+	if res := query(t, conn, r, "CUR?"); res != "CUR 300\r\n" {
+		t.Errorf("exp CUR 300 got %q", res)
+	}
+	if res := query(t, conn, r, "CUR 20"); res != "OK\r\n" {
+		t.Errorf("exp OK got %q", res)
+	}
+	if v := vdtest.Get[int64](vd, "current"); v != 20 {
+		t.Errorf("exp current 20 got %d", v)
+	}
+
+	vd.Set("current", 42)
+	vd.Set("mode", "SING")
+	if res := query(t, conn, r, "CUR?"); res != "CUR 42\r\n" {
+		t.Errorf("exp CUR 42 got %q", res)
+	}
+	if v := vd.Get("mode"); v != "SING" {
+		t.Errorf("exp mode SING got %v", v)
+	}
+	vd.Set("wave", []float64{1, 2.5})
+	if v := fmt.Sprint(vd.Get("wave")); v != "[1 2.5]" {
+		t.Errorf("exp wave [1 2.5] got %v", v)
+	}
+	vd.Set("wave", [3]float64{0.5, 1, 1.5})
+	if v := fmt.Sprint(vd.Get("wave")); v != "[0.5 1 1.5]" {
+		t.Errorf("exp wave [0.5 1 1.5] got %v", v)
+	}
+
+	vd.AssertRequests("CUR?", "CUR 20", "CUR?")
+	vd.AssertCommands("get_current", "set_current", "get_current")
+	if v := vd.Requests()[1].Values["current"]; v != int64(20) {
+		t.Errorf("exp value 20 in set request got %v", v)
+	}
+
+	vd.ClearRequests()
+	if reqs := vd.Requests(); len(reqs) != 0 {
+		t.Errorf("exp no requests after clear got %v", reqs)
+	}
+}
+
+func TestWaitForRequest(t *testing.T) {
+	t.Parallel()
+	vd := vdtest.Start(t, config)
+	conn, r := dial(t, vd)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		conn.Write([]byte("CUR 10\r\n"))
+		r.ReadString('\n')
+	}()
+
+	req := vd.WaitForRequest("CUR 10", 0)
+	if req.Client == 0 {
+		t.Error("exp client id in request")
+	}
+	req = vd.WaitForCommand("set_current", time.Second)
+	if req.Data != "CUR 10" {
+		t.Errorf("exp CUR 10 got %q", req.Data)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	t.Parallel()
+	vd := vdtest.Start(t, config)
+	conn, r := dial(t, vd)
+
+	vd.SetMismatch("ERR")
+	if res := query(t, conn, r, "VOLT?"); res != "ERR\r\n" {
+		t.Errorf("exp mismatch got %q", res)
+	}
+
+	vd.SetDelay("get_current", 200*time.Millisecond)
+	start := time.Now()
+	query(t, conn, r, "CUR?")
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Errorf("exp reply delayed by 200ms got %s", d)
+	}
+
+	vd.Trigger("get_current")
+	if res, _ := r.ReadString('\n'); res != "CUR 300\r\n" {
+		t.Errorf("exp triggered reply got %q", res)
+	}
+
+	vd.DisconnectAll()
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Error("exp connection closed")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+	vd := vdtest.Start(t, config)
+	conn, r := dial(t, vd)
+
+	initial := vd.Snapshot()
+	vd.ApplyPreset("off")
+	if res := query(t, conn, r, "CUR?"); res != "CUR 0\r\n" {
+		t.Errorf("exp CUR 0 got %q", res)
+	}
+	if res := query(t, conn, r, "VOLT?"); res != "OFF\r\n" {
+		t.Errorf("exp OFF got %q", res)
+	}
+
+	vd.Restore(initial)
+	if res := query(t, conn, r, "CUR?"); res != "CUR 300\r\n" {
+		t.Errorf("exp CUR 300 got %q", res)
+	}
+}